@@ -108,6 +108,7 @@ func main() {
 	if *bookFile != "" && *bookFormat != "" {
 		config.Settings.Search.BookFile = *bookFile
 		config.Settings.Search.BookFormat = *bookFormat
+		config.Settings.Search.BookFiles = nil
 	}
 
 	// resetting log level auf standard log - required  as most packages include
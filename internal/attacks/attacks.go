@@ -150,9 +150,9 @@ func (a *Attacks) nonPawnAttacks(p *position.Position) {
 // pawnAttacks calculate all attacks for pawns.
 func (a *Attacks) pawnAttacks(p *position.Position) {
 	a.Pawns[White] = ShiftBitboard(p.PiecesBb(White, Pawn), Northwest) | ShiftBitboard(p.PiecesBb(White, Pawn), Northeast)
-	a.Pawns[Black] = ShiftBitboard(p.PiecesBb(Black, Pawn), Northwest) | ShiftBitboard(p.PiecesBb(Black, Pawn), Northeast)
+	a.Pawns[Black] = ShiftBitboard(p.PiecesBb(Black, Pawn), Southwest) | ShiftBitboard(p.PiecesBb(Black, Pawn), Southeast)
 	a.PawnsDouble[White] = ShiftBitboard(p.PiecesBb(White, Pawn), Northwest) & ShiftBitboard(p.PiecesBb(White, Pawn), Northeast)
-	a.PawnsDouble[Black] = ShiftBitboard(p.PiecesBb(Black, Pawn), Northwest) & ShiftBitboard(p.PiecesBb(Black, Pawn), Northeast)
+	a.PawnsDouble[Black] = ShiftBitboard(p.PiecesBb(Black, Pawn), Southwest) & ShiftBitboard(p.PiecesBb(Black, Pawn), Southeast)
 }
 
 // AttacksTo determines all attacks to the given square for the given color.
@@ -168,24 +168,8 @@ func AttacksTo(p *position.Position, square Square, color Color) Bitboard {
 		}
 	}
 
-	occupiedAll := p.OccupiedAll()
-
-	// this uses a reverse approach - it uses the target square as from square
-	// to generate attacks for each type and then intersects the result with
-	// the piece bitboard.
-
-	//      Pawns
-	return (GetPawnAttacks(color.Flip(), square) & p.PiecesBb(color, Pawn)) |
-		// Knight
-		(GetAttacksBb(Knight, square, occupiedAll) & p.PiecesBb(color, Knight)) |
-		// King
-		(GetAttacksBb(King, square, occupiedAll) & p.PiecesBb(color, King)) |
-		// Sliding rooks and queens
-		(GetAttacksBb(Rook, square, occupiedAll) & (p.PiecesBb(color, Rook) | p.PiecesBb(color, Queen))) |
-		// Sliding bishops and queens
-		(GetAttacksBb(Bishop, square, occupiedAll) & (p.PiecesBb(color, Bishop) | p.PiecesBb(color, Queen))) |
-		// consider en passant attacks
-		epAttacks
+	// direct attacks plus en passant attacks
+	return p.AttackersTo(square, color) | epAttacks
 }
 
 // RevealedAttacks returns sliding attacks after a piece has been removed to reveal new attacks.
@@ -70,6 +70,10 @@ func TestAttacks(t *testing.T) {
 	assert.EqualValues(t, SqF1.Bb()|SqG1.Bb(), a.From[White][SqH1]&^p.OccupiedBb(White))
 	assert.EqualValues(t, SqD8.Bb()|SqE7.Bb()|SqF8.Bb(), a.From[Black][SqE8]&^p.OccupiedBb(Black))
 	assert.EqualValues(t, SqC6.Bb()|SqH5.Bb(), a.To[Black][SqE5]&p.OccupiedBb(Black))
+	// black pawns attack towards the first rank
+	assert.True(t, a.Pawns[Black].Has(SqD4) && a.Pawns[Black].Has(SqF4))
+	assert.False(t, a.Pawns[Black].Has(SqD5))
+	assert.True(t, a.Pawns[White].Has(SqD5) && a.Pawns[White].Has(SqF5))
 }
 
 func TestCompareWithPseudo(t *testing.T) {
@@ -24,15 +24,18 @@
 // SOFTWARE.
 //
 
-package search
+package attacks
 
 import (
-	"github.com/frankkopp/FrankyGo/internal/attacks"
 	"github.com/frankkopp/FrankyGo/internal/position"
 	. "github.com/frankkopp/FrankyGo/internal/types"
 )
 
-func see(p *position.Position, move Move) Value {
+// See (static exchange evaluation) determines the material gain
+// or loss of the given capture when all attackers and defenders
+// of the target square would capture on it in the order of the
+// least valuable piece first.
+func See(p *position.Position, move Move) Value {
 
 	// enpassant moves are ignored in a sense that it will be winning
 	// capture and therefore should lead to no cut-offs when using see()
@@ -54,7 +57,7 @@ func see(p *position.Position, move Move) Value {
 	occupiedBitboard := p.OccupiedAll()
 
 	// get all attacks to the square as a bitboard
-	remainingAttacks := attacks.AttacksTo(p, toSquare, White) | attacks.AttacksTo(p, toSquare, Black)
+	remainingAttacks := AttacksTo(p, toSquare, White) | AttacksTo(p, toSquare, Black)
 
 	// log := myLogging.GetLog()
 	// log.Debugf("Determine gain for %s %s", p.StringFen(), move.StringUci())
@@ -86,8 +89,8 @@ func see(p *position.Position, move Move) Value {
 		occupiedBitboard.PopSquare(fromSquare) // reset bit in temporary occupancy (for x-Rays)
 
 		// reevaluate attacks to reveal attacks after removing the moving piece
-		remainingAttacks |= attacks.RevealedAttacks(p, toSquare, occupiedBitboard, White) |
-			attacks.RevealedAttacks(p, toSquare, occupiedBitboard, Black)
+		remainingAttacks |= RevealedAttacks(p, toSquare, occupiedBitboard, White) |
+			RevealedAttacks(p, toSquare, occupiedBitboard, Black)
 
 		// determine next capture
 		fromSquare = getLeastValuablePiece(p, remainingAttacks, nextPlayer)
@@ -24,7 +24,7 @@
 // SOFTWARE.
 //
 
-package search
+package attacks
 
 import (
 	"testing"
@@ -32,16 +32,14 @@ import (
 
 	"github.com/stretchr/testify/assert"
 
-	"github.com/frankkopp/FrankyGo/internal/attacks"
 	"github.com/frankkopp/FrankyGo/internal/config"
-	"github.com/frankkopp/FrankyGo/internal/movegen"
 	"github.com/frankkopp/FrankyGo/internal/position"
 	. "github.com/frankkopp/FrankyGo/internal/types"
 )
 
 func TestLeastValuablePiece(t *testing.T) {
 	p := position.NewPosition("r3k2r/1ppn3p/2q1q1n1/4P3/2q1Pp2/6R1/pbp2PPP/1R4K1 b kq e3")
-	attacksTo := attacks.AttacksTo(p, SqE5, Black)
+	attacksTo := AttacksTo(p, SqE5, Black)
 
 	logTest.Debug("All attackers\n", attacksTo.StringBoard())
 	logTest.Debug(attacksTo.StringGrouped())
@@ -81,32 +79,32 @@ func TestLeastValuablePiece(t *testing.T) {
 
 func TestSee(t *testing.T) {
 	p := position.NewPosition("1k1r3q/1ppn3p/p4b2/4p3/8/P2N2P1/1PP1R1BP/2K1Q3 w - -")
-	move := movegen.NewMoveGen().GetMoveFromUci(p, "d3e5")
-	seeScore := see(p, move)
+	move := CreateMove(SqD3, SqE5, Normal, PtNone)
+	seeScore := See(p, move)
 	logTest.Debug("See score:", seeScore)
 	assert.EqualValues(t, -220, seeScore)
 
 	p = position.NewPosition("1k1r4/1pp4p/p7/4p3/8/P5P1/1PP4P/2K1R3 w - -")
-	move = movegen.NewMoveGen().GetMoveFromUci(p, "e1e5")
-	seeScore = see(p, move)
+	move = CreateMove(SqE1, SqE5, Normal, PtNone)
+	seeScore = See(p, move)
 	logTest.Debug("See score:", seeScore)
 	assert.EqualValues(t, 100, seeScore)
 
 	p = position.NewPosition("5q1k/8/8/8/RRQ2nrr/8/8/K7 w - -")
-	move = movegen.NewMoveGen().GetMoveFromUci(p, "c4f4")
-	seeScore = see(p, move)
+	move = CreateMove(SqC4, SqF4, Normal, PtNone)
+	seeScore = See(p, move)
 	logTest.Debug("See score:", seeScore)
 	assert.EqualValues(t, -580, seeScore)
 
 	p = position.NewPosition("k6q/3n1n2/3b4/4p3/3P1P2/3N1N2/8/K7 w - -")
-	move = movegen.NewMoveGen().GetMoveFromUci(p, "d3e5")
-	seeScore = see(p, move)
+	move = CreateMove(SqD3, SqE5, Normal, PtNone)
+	seeScore = See(p, move)
 	logTest.Debug("See score:", seeScore)
 	assert.EqualValues(t, 100, seeScore)
 
 	p = position.NewPosition("r3k2r/1ppn3p/2q1q1n1/4P3/2q1Pp2/6R1/pbp2PPP/1R2R1K1 b kq e3")
-	move = movegen.NewMoveGen().GetMoveFromUci(p, "a2b1Q")
-	seeScore = see(p, move)
+	move = CreateMove(SqA2, SqB1, Promotion, Queen)
+	seeScore = See(p, move)
 	logTest.Debug("See score:", seeScore)
 	assert.EqualValues(t, 500, seeScore)
 }
@@ -120,10 +118,10 @@ func TestTimingSee(t *testing.T) {
 	// go tool pprof -http=localhost:8080 FrankyGo_Test.exe cpu.pprof
 
 	config.Settings.Search.UseBook = false
-	config.Settings.Search.UseSEE = true
+	config.Settings.Search.UseQSSee = true
 
 	p := position.NewPosition("k6q/3n1n2/3b4/4p3/3P1P2/3N1N2/8/K7 w - -")
-	move := movegen.NewMoveGen().GetMoveFromUci(p, "d3e5")
+	move := CreateMove(SqD3, SqE5, Normal, PtNone)
 
 	const rounds = 5
 	const iterations uint64 = 10_000_000
@@ -133,7 +131,7 @@ func TestTimingSee(t *testing.T) {
 		out.Printf("Round %d\n", r)
 		start := time.Now()
 		for i := uint64(0); i < iterations; i++ {
-			seeScore = see(p, move)
+			seeScore = See(p, move)
 		}
 		elapsed := time.Since(start)
 		out.Printf("Test took %s for %d iterations\n", elapsed, iterations)
@@ -79,7 +79,7 @@ func Setup() {
 	// setup log level - first check cmd line, then config file, finally leave defaults
 	setupLogLvl()
 	// setup search config after reading from configuration file if necessary
-	setupSearch()
+	setupSearch(path)
 	// setup eval config after reading from configuration file if necessary
 	setupEval()
 	initialized = true
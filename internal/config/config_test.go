@@ -28,6 +28,7 @@ package config
 
 import (
 	"fmt"
+	"io/ioutil"
 	"os"
 	"path"
 	"runtime"
@@ -60,3 +61,43 @@ func Test(t *testing.T) {
 	Setup()
 	fmt.Println(Settings.String())
 }
+
+func TestEvalMode(t *testing.T) {
+	var mode EvalMode
+	if err := mode.UnmarshalText([]byte("material")); err != nil || mode != EvalMaterial {
+		t.Errorf("expected %s but got %s (%v)", EvalMaterial, mode, err)
+	}
+	if err := mode.UnmarshalText([]byte("fancy")); err == nil || mode != EvalMaterial {
+		t.Errorf("expected an error and %s but got %s (%v)", EvalMaterial, mode, err)
+	}
+	if mode, err := ParseEvalMode("classical"); err != nil || mode != EvalClassical {
+		t.Errorf("expected %s but got %s (%v)", EvalClassical, mode, err)
+	}
+}
+
+func TestUseSeeAlias(t *testing.T) {
+	file := path.Join(os.TempDir(), "frankygo_usesee_test.toml")
+	defer os.Remove(file)
+	old := Settings.Search.UseQSSee
+	defer func() { Settings.Search.UseQSSee = old }()
+
+	// old name only
+	Settings.Search.UseQSSee = true
+	if err := ioutil.WriteFile(file, []byte("[search]\nUseSee = false\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	setupSearch(file)
+	if Settings.Search.UseQSSee {
+		t.Errorf("UseSee = false was not applied to UseQSSee")
+	}
+
+	// new name wins
+	Settings.Search.UseQSSee = true
+	if err := ioutil.WriteFile(file, []byte("[search]\nUseSee = false\nUseQSSee = true\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	setupSearch(file)
+	if !Settings.Search.UseQSSee {
+		t.Errorf("UseSee overwrote UseQSSee")
+	}
+}
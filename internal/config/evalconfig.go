@@ -26,7 +26,54 @@
 
 package config
 
+import (
+	"fmt"
+)
+
+// EvalMode selects the evaluation function. In config files and UCI
+// options it is given by its name.
+type EvalMode int
+
+// EvalMode values
+const (
+	// EvalClassical uses all configured evaluation terms
+	EvalClassical EvalMode = iota
+	// EvalMaterial only uses material and piece square tables
+	EvalMaterial
+)
+
+var evalModeNames = [...]string{"classical", "material"}
+
+// ParseEvalMode returns the EvalMode with the given name.
+func ParseEvalMode(name string) (EvalMode, error) {
+	for m, n := range evalModeNames {
+		if n == name {
+			return EvalMode(m), nil
+		}
+	}
+	return EvalClassical, fmt.Errorf("invalid eval mode: %s", name)
+}
+
+// String returns the name of the EvalMode.
+func (m EvalMode) String() string {
+	return evalModeNames[m]
+}
+
+// UnmarshalText reads the EvalMode by its name from a config file.
+func (m *EvalMode) UnmarshalText(text []byte) error {
+	mode, err := ParseEvalMode(string(text))
+	if err != nil {
+		return err
+	}
+	*m = mode
+	return nil
+}
+
 type evalConfiguration struct {
+	// evaluation function: "classical" uses all configured terms,
+	// "material" only material and piece square tables
+	Mode EvalMode
+
 	UsePawnCache  bool
 	PawnCacheSize int
 
@@ -55,10 +102,60 @@ type evalConfiguration struct {
 	UseKingEval       bool
 	KingDangerMalus   int
 	KingDefenderBonus int
+
+	// king pawn shield - own pawns in front of the castled king and
+	// enemy pawns storming towards it
+	UseKingShield   bool
+	KingShieldBonus int
+	KingStormMalus  int
+
+	UseEndgameRecognizers bool
+	// scale down advantages of less than a rook without pawns
+	UseEndgameScaling bool
+
+	UsePawnStructure   bool
+	ConnectedPawnBonus int
+	PhalanxPawnBonus   int
+
+	// material imbalance - knights gain and rooks lose value with
+	// more own pawns, a second rook is partly redundant
+	UseImbalance       bool
+	KnightPawnBonus    int
+	RookPawnMalus      int
+	RedundantRookMalus int
+
+	// king tropism - queens and knights close to the enemy king
+	UseKingTropism     bool
+	QueenTropismBonus  int
+	KnightTropismBonus int
+
+	// space - safe squares behind own pawns in the center files
+	UseSpace   bool
+	SpaceBonus int
+
+	// threats - attacks on undefended enemy pieces and on enemy pieces
+	// of a higher value than the attacker
+	UseThreats        bool
+	HangingPieceBonus int
+	ThreatBonus       int
+
+	// trapped pieces - knights and bishops in the enemy half with at most
+	// one safe square (needs UseAttacksInEval). Rooks trapped by their own
+	// king are covered by RookTrappedMalus.
+	UseTrappedPieces  bool
+	TrappedPieceMalus int
+
+	// batteries - rooks doubled on a file without own pawns and queens
+	// lined up with a rook on such a file or on the enemy king's or 7th rank
+	UseBatteries          bool
+	RookBatteryBonus      int
+	QueenRookBatteryBonus int
 }
 
 // sets defaults which might be overwritten by config file.
 func init() {
+	Settings.Eval.Mode = EvalClassical
+
 	Settings.Eval.UsePawnCache = false // not implemented yet
 	Settings.Eval.PawnCacheSize = 64   // not implemented yet
 
@@ -88,6 +185,40 @@ func init() {
 	Settings.Eval.KingDangerMalus = 50   // number of number of attacker - defender times malus if attacker > defender
 	Settings.Eval.KingDefenderBonus = 10 // number of number of defender - attacker times bonus if attacker <= defender
 
+	Settings.Eval.UseKingShield = false
+	Settings.Eval.KingShieldBonus = 10 // per shield pawn on the 2nd rank (half on the 3rd) - mid game only
+	Settings.Eval.KingStormMalus = 8   // per enemy pawn on the king files times 3, 2, 1 on the 3rd, 4th, 5th rank - mid game only
+
+	Settings.Eval.UseEndgameRecognizers = false
+	Settings.Eval.UseEndgameScaling = false
+
+	Settings.Eval.UsePawnStructure = false
+	Settings.Eval.ConnectedPawnBonus = 3 // per pawn defended by a pawn and times relative rank
+	Settings.Eval.PhalanxPawnBonus = 2   // per pawn with a neighbour pawn on the same rank and times relative rank
+
+	Settings.Eval.UseImbalance = false
+	Settings.Eval.KnightPawnBonus = 6     // per knight and own pawn more (or less) than 5
+	Settings.Eval.RookPawnMalus = 12      // per rook and own pawn more (or less) than 5
+	Settings.Eval.RedundantRookMalus = 20 // for the second rook
+
+	Settings.Eval.UseKingTropism = false
+	Settings.Eval.QueenTropismBonus = 5  // per queen and square closer than 7 to the enemy king and times game phase
+	Settings.Eval.KnightTropismBonus = 3 // per knight and square closer than 7 to the enemy king and times game phase
+
+	Settings.Eval.UseSpace = false
+	Settings.Eval.SpaceBonus = 4 // per safe square behind own pawns in the center files and times game phase
+
+	Settings.Eval.UseThreats = false
+	Settings.Eval.HangingPieceBonus = 30 // per attacked and undefended enemy piece
+	Settings.Eval.ThreatBonus = 20       // per defended enemy piece attacked by a lower valued piece
+
+	Settings.Eval.UseTrappedPieces = false
+	Settings.Eval.TrappedPieceMalus = 100 // per trapped piece
+
+	Settings.Eval.UseBatteries = false
+	Settings.Eval.RookBatteryBonus = 20      // per pair of doubled rooks
+	Settings.Eval.QueenRookBatteryBonus = 15 // per queen and rook pair and times game phase
+
 }
 
 // set defaults for configurations here in case a configuration
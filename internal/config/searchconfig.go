@@ -26,6 +26,10 @@
 
 package config
 
+import (
+	"github.com/BurntSushi/toml"
+)
+
 // searchConfiguration is a data structure to hold the configuration of an
 // instance of a search.
 type searchConfiguration struct {
@@ -34,26 +38,125 @@ type searchConfiguration struct {
 	BookPath   string
 	BookFile   string
 	BookFormat string
+	BookMaxPly int // the book is only used for the first n plies of a game (0 = no limit)
+	// Several book files in order of priority. When set these are used
+	// instead of BookFile. The first book having a move for a position
+	// is used.
+	BookFiles []string
+	// Probability to skip the most played book move in favor of one of
+	// the other book moves (0.0 = never, 1.0 = always)
+	BookVariety float64
 
 	// Ponder
 	UsePonder bool
 
+	// Number of search threads
+	Threads int
+
+	// Estimated moves to go when no movestogo is given (sudden death).
+	// Interpolated by game phase from the opening to the end game estimate.
+	MovesToGoEstimate    int
+	MovesToGoEstimateEnd int
+
+	// Spend less time on the first n full moves of a game (0 = off). The
+	// time per move grows from half of the normal time at move 1 to the
+	// normal time at move n. The time saved remains for later moves.
+	OpeningSpeedup int
+
+	// Minimum interval in milliseconds between the periodic info updates
+	// during an iteration. Each completed iteration is always reported.
+	InfoInterval int
+
+	// Send the nodes searched per root move as info strings after each iteration
+	ReportRootMoveNodes bool
+
+	// Stop the search after the first iteration when there is only
+	// one legal move to play
+	UseOnlyMove bool
+
+	// Maximum number of pv moves reported to the UCI ui (0 = no limit).
+	// The search itself is not limited.
+	MaxPvLength int
+
+	// Report win/draw/loss probabilities with the score (UCI_ShowWDL).
+	// The probability to win is 50% at the midpoint in centipawns which
+	// is interpolated by game phase from the opening to the end game.
+	// The spread defines how fast the probabilities change.
+	ShowWDL        bool
+	WdlMidpoint    int
+	WdlMidpointEnd int
+	WdlSpread      int
+
+	// Report the best reply of the opponent to each root move after the
+	// search as refutation (UCI_ShowRefutations)
+	ShowRefutations bool
+
+	// Root move noise in centipawns to vary play between games (0 = off)
+	RootMoveNoise int
+
+	// When the best value of the last iteration is at least the given
+	// margin root moves which allow a repetition get a small penalty to
+	// prefer progress in winning positions
+	UseAntiRepetition    bool
+	AntiRepetitionMargin int
+
+	// Handicap to limit the playing strength by capping the search effort
+	// (0 = off) and by playing a random legal move with the given
+	// probability (0.0 = never, 1.0 = always). Infinite (analysis)
+	// searches are not handicapped.
+	MaxDepthPerMove    int
+	MaxNodesPerMove    uint64
+	BlunderProbability float64
+
+	// Debug: limits the nodes per second by sleeping to make time
+	// management tests independent of the machine speed (0 = off)
+	MaxNps uint64
+
+	// Safety check: verify that nodes without any generated move really have
+	// no legal move. On failure the fen is logged and the node returns its
+	// static evaluation instead of a mate or stalemate value.
+	// Always active when compiled with the debug build tag.
+	CheckNoMoves bool
+
+	// Evaluation free search for "go mate" - all non mate leaves are a draw
+	MateSearchMode bool
+
+	// Contempt in centipawns - draws are valued as -contempt for the engine
+	// (0 = off). Optionally scaled down by game phase to 0 in the end game.
+	ContemptMax      int
+	UsePhaseContempt bool
+
+	// Value of a draw in centipawns from the view of the side to move
+	// (0 = draws are valued as ValueDraw). Contempt is applied on top.
+	DrawValue int
+
 	// Quiescence search
 	UseQuiescence   bool
 	UseQSStandpat   bool
-	UseSEE          bool
 	UsePromNonQuiet bool
+	// with UseQSSee captures in quiescence search are searched when their
+	// SEE value is above -QSSeeMargin (0 = only winning captures).
+	// Without SEE a simple piece value comparison is used.
+	UseQSSee    bool
+	QSSeeMargin int
+	// after the given number of captures in a quiescence node all
+	// further captures (apart from promotions) are skipped
+	UseQSCaptureLimit bool
+	QSCaptureLimit    int
 
 	// main search algorithm
 	UsePVS        bool
-	UseAspiration bool // not yet implemented
+	UseAspiration bool
 	UseMTDf       bool // not yet implemented
 
 	// Move ordering
 	UseIID            bool
 	UseKiller         bool
 	UseHistoryCounter bool
+	UseHistoryGravity bool
 	UseCounterMoves   bool
+	UseFollowUpMoves  bool // follow-up moves to our own previous move
+	UseSEEOrdering    bool
 	IIDDepth          int
 	IIDReduction      int
 
@@ -64,21 +167,34 @@ type searchConfiguration struct {
 	UseTTValue bool
 	UseQSTT    bool
 	UseEvalTT  bool
+	// pre-touch the memory of the tt when caches are cleared
+	WarmUpCaches bool
 
 	// Prunings pre move gen
-	UseMDP       bool
-	UseRazoring  bool
-	RazorMargin  int
-	UseRFP       bool
-	UseNullMove  bool
-	NmpDepth     int
-	NmpReduction int
+	UseMDP      bool
+	UseRazoring bool
+	RazorMargin int
+	RazorDepth  int // razoring drops into quiescence search up to this depth (above 1 only on a fail low)
+	UseRFP      bool
+	// razoring and RFP are skipped when the TT has a move from a search
+	// of at least the given depth (0 = off)
+	NoPruneTTMoveDepth int
+	UseNullMove        bool
+	NmpDepth           int
+	NmpReduction       int
+	// null move needs a higher minimum depth (0 = no null move) when the
+	// non pawn material is below the given value (0 = off)
+	NmpEndgameMaterial int
+	NmpEndgameDepth    int
 
 	// extensions of search depth
 	UseExt         bool
 	UseExtAddDepth bool
 	UseCheckExt    bool
 	UseThreatExt   bool
+	// pawn pushes to the 7th rank and passed pawn pushes to the 6th rank
+	// are extended by a full ply (there are no fractional extensions)
+	UsePassedPawnExt bool
 
 	// prunings after move generation but before making move
 	UseFP            bool
@@ -87,6 +203,14 @@ type searchConfiguration struct {
 	UseLmr           bool
 	LmrDepth         int
 	LmrMovesSearched int
+	// smaller reductions for moves with a history count of at least
+	// LmrHistoryThreshold (0 = off) and when the static eval is improving
+	LmrHistoryThreshold int
+	LmrImproving        bool
+	// larger reductions in expected all nodes than in expected cut nodes
+	LmrNodeType bool
+	// captures losing material by SEE are pruned close to the leaves
+	UseSEEPruning bool
 }
 
 // defaults which might be overwritten by config file.
@@ -95,12 +219,60 @@ func init() {
 	Settings.Search.BookPath = "./assets/books"
 	Settings.Search.BookFile = "book.txt"
 	Settings.Search.BookFormat = "Simple"
+	Settings.Search.BookMaxPly = 0
+	Settings.Search.BookFiles = nil
+	Settings.Search.BookVariety = 0.0
 
 	Settings.Search.UsePonder = true
 
+	Settings.Search.Threads = 1
+
+	Settings.Search.MovesToGoEstimate = 40
+	Settings.Search.MovesToGoEstimateEnd = 15
+
+	Settings.Search.OpeningSpeedup = 0
+
+	Settings.Search.InfoInterval = 1000
+
+	Settings.Search.ReportRootMoveNodes = false
+
+	Settings.Search.UseOnlyMove = true
+
+	Settings.Search.MaxPvLength = 20
+
+	Settings.Search.ShowWDL = false
+	Settings.Search.WdlMidpoint = 300
+	Settings.Search.WdlMidpointEnd = 200
+	Settings.Search.WdlSpread = 70
+
+	Settings.Search.ShowRefutations = false
+
+	Settings.Search.RootMoveNoise = 0
+
+	Settings.Search.UseAntiRepetition = false
+	Settings.Search.AntiRepetitionMargin = 300
+
+	Settings.Search.MaxDepthPerMove = 0
+	Settings.Search.MaxNodesPerMove = 0
+	Settings.Search.BlunderProbability = 0.0
+
+	Settings.Search.MaxNps = 0
+
+	Settings.Search.CheckNoMoves = false
+
+	Settings.Search.MateSearchMode = false
+
+	Settings.Search.ContemptMax = 0
+	Settings.Search.UsePhaseContempt = false
+
+	Settings.Search.DrawValue = 0
+
 	Settings.Search.UseQuiescence = true
 	Settings.Search.UseQSStandpat = true
-	Settings.Search.UseSEE = true
+	Settings.Search.UseQSSee = true
+	Settings.Search.QSSeeMargin = 0
+	Settings.Search.UseQSCaptureLimit = false
+	Settings.Search.QSCaptureLimit = 4
 	Settings.Search.UsePromNonQuiet = true
 
 	Settings.Search.UsePVS = true
@@ -110,7 +282,10 @@ func init() {
 	Settings.Search.UseIID = true
 	Settings.Search.UseKiller = true
 	Settings.Search.UseHistoryCounter = true
+	Settings.Search.UseHistoryGravity = false
 	Settings.Search.UseCounterMoves = true
+	Settings.Search.UseFollowUpMoves = false
+	Settings.Search.UseSEEOrdering = false
 	Settings.Search.IIDDepth = 6
 	Settings.Search.IIDReduction = 2
 
@@ -120,19 +295,25 @@ func init() {
 	Settings.Search.UseTTValue = true
 	Settings.Search.UseQSTT = true
 	Settings.Search.UseEvalTT = false
+	Settings.Search.WarmUpCaches = false
 
 	Settings.Search.UseMDP = true
 	Settings.Search.UseRazoring = true
 	Settings.Search.RazorMargin = 531
+	Settings.Search.RazorDepth = 1
 	Settings.Search.UseRFP = true
+	Settings.Search.NoPruneTTMoveDepth = 0
 	Settings.Search.UseNullMove = true
 	Settings.Search.NmpDepth = 3
 	Settings.Search.NmpReduction = 2
+	Settings.Search.NmpEndgameMaterial = 0
+	Settings.Search.NmpEndgameDepth = 6
 
 	Settings.Search.UseExt = true
 	Settings.Search.UseExtAddDepth = true
 	Settings.Search.UseCheckExt = true
 	Settings.Search.UseThreatExt = false
+	Settings.Search.UsePassedPawnExt = false
 
 	Settings.Search.UseFP = true
 	Settings.Search.UseQFP = true
@@ -140,10 +321,27 @@ func init() {
 	Settings.Search.UseLmr = true
 	Settings.Search.LmrDepth = 3
 	Settings.Search.LmrMovesSearched = 3
+	Settings.Search.LmrHistoryThreshold = 0
+	Settings.Search.LmrImproving = false
+	Settings.Search.LmrNodeType = false
+	Settings.Search.UseSEEPruning = false
 }
 
 // set defaults for configurations here in case a configuration
 // is not available from the config file.
-func setupSearch() {
-
+func setupSearch(path string) {
+	// UseSee is the old name of UseQSSee and is still accepted
+	// if the config file does not use the new name
+	var deprecated struct {
+		Search struct {
+			UseSee *bool
+		}
+	}
+	md, err := toml.DecodeFile(path, &deprecated)
+	if err != nil {
+		return
+	}
+	if deprecated.Search.UseSee != nil && !md.IsDefined("search", "UseQSSee") {
+		Settings.Search.UseQSSee = *deprecated.Search.UseSee
+	}
 }
@@ -0,0 +1,177 @@
+//
+// FrankyGo - UCI chess engine in GO for learning purposes
+//
+// MIT License
+//
+// Copyright (c) 2018-2020 Frank Kopp
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in all
+// copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE
+// SOFTWARE.
+//
+
+package evaluator
+
+import (
+	"github.com/frankkopp/FrankyGo/internal/position"
+	. "github.com/frankkopp/FrankyGo/internal/types"
+)
+
+// scale factors in percent for evaluations of recognized end games
+const (
+	scaleNormal   = 100
+	scaleDraw     = 0
+	scaleDrawish  = 12
+	scalePawnless = 25
+)
+
+// endgameScale recognizes a few theoretical end games and returns
+// a scale factor in percent for the evaluation of the position.
+// scaleDraw (0) means the position is a known draw and scaleNormal
+// (100) means no end game has been recognized.
+// The end games are recognized by the piece counts of both sides.
+func endgameScale(p *position.Position) int {
+	// only a few pieces on the board
+	if p.OccupiedAll().PopCount() > 6 {
+		return scaleNormal
+	}
+	for _, strong := range []Color{White, Black} {
+		weak := strong.Flip()
+		if onlyKing(p, weak) &&
+			p.Count(strong, Bishop) == 1 &&
+			p.Count(strong, Pawn) > 0 &&
+			p.Count(strong, Knight)+p.Count(strong, Rook)+p.Count(strong, Queen) == 0 {
+			return wrongBishopScale(p, strong)
+		}
+		if p.Count(weak, Pawn) == 1 && onlyPawns(p, weak) &&
+			p.Count(strong, Queen) == 1 &&
+			p.Count(strong, Pawn)+p.Count(strong, Knight)+p.Count(strong, Bishop)+p.Count(strong, Rook) == 0 {
+			return kqkpScale(p, strong)
+		}
+		if p.Count(weak, Pawn) == 1 && onlyPawns(p, weak) &&
+			p.Count(strong, Rook) == 1 &&
+			p.Count(strong, Pawn)+p.Count(strong, Knight)+p.Count(strong, Bishop)+p.Count(strong, Queen) == 0 {
+			return krkpScale(p, strong)
+		}
+	}
+	return scaleNormal
+}
+
+// pawnlessScale scales down the advantage of a side without pawns when
+// its non pawn material advantage is less than a rook as this is usually
+// not enough to win (e.g. rook against bishop or knight against pawns).
+// A queen against other pieces is an exception as it usually wins.
+func pawnlessScale(p *position.Position) int {
+	strong := White
+	if p.Material(Black) > p.Material(White) {
+		strong = Black
+	}
+	weak := strong.Flip()
+	if p.Material(strong) == p.Material(weak) ||
+		p.Count(strong, Pawn) > 0 ||
+		p.MaterialNonPawn(strong)-p.MaterialNonPawn(weak) >= Rook.ValueOf() ||
+		p.Count(strong, Queen) > p.Count(weak, Queen) {
+		return scaleNormal
+	}
+	return scalePawnless
+}
+
+// wrongBishopScale recognizes the draw of bishop and rook pawns against
+// the lone king when the bishop does not control the promotion square
+// and the defending king has reached the corner.
+func wrongBishopScale(p *position.Position, strong Color) int {
+	pawns := p.PiecesBb(strong, Pawn)
+	var file File
+	switch {
+	case pawns&^FileA_Bb == BbZero:
+		file = FileA
+	case pawns&^FileH_Bb == BbZero:
+		file = FileH
+	default:
+		return scaleNormal
+	}
+	promotionSquare := SquareOf(file, Rank8)
+	if strong == Black {
+		promotionSquare = SquareOf(file, Rank1)
+	}
+	// the bishop controls the promotion square
+	if p.PiecesBb(strong, Bishop)&SquaresBb(White) != BbZero ==
+		(promotionSquare.Bb()&SquaresBb(White) != BbZero) {
+		return scaleNormal
+	}
+	if SquareDistance(p.KingSquare(strong.Flip()), promotionSquare) <= 1 {
+		return scaleDraw
+	}
+	return scaleNormal
+}
+
+// kqkpScale recognizes the often drawn queen against a rook or bishop
+// pawn on the 7th rank supported by its king when the attacking king is
+// too far away to help.
+func kqkpScale(p *position.Position, strong Color) int {
+	weak := strong.Flip()
+	pawnSquare := p.PiecesBb(weak, Pawn).Lsb()
+	if relativeRank(weak, pawnSquare) != 6 {
+		return scaleNormal
+	}
+	switch pawnSquare.FileOf() {
+	case FileA, FileC, FileF, FileH:
+	default:
+		return scaleNormal
+	}
+	if SquareDistance(p.KingSquare(weak), pawnSquare) <= 1 &&
+		SquareDistance(p.KingSquare(strong), pawnSquare) > 3 {
+		return scaleDrawish
+	}
+	return scaleNormal
+}
+
+// krkpScale recognizes the often drawn rook against an advanced pawn
+// supported by its king. The rook side only wins when its king can
+// reach the promotion square in time. The race is decided by the kings'
+// distances to the promotion square taking the side to move into account.
+func krkpScale(p *position.Position, strong Color) int {
+	weak := strong.Flip()
+	pawnSquare := p.PiecesBb(weak, Pawn).Lsb()
+	if relativeRank(weak, pawnSquare) < 4 ||
+		SquareDistance(p.KingSquare(weak), pawnSquare) > 1 {
+		return scaleNormal
+	}
+	promotionSquare := SquareOf(pawnSquare.FileOf(), Rank1)
+	if weak == White {
+		promotionSquare = SquareOf(pawnSquare.FileOf(), Rank8)
+	}
+	tempo := 0
+	if p.NextPlayer() == strong {
+		tempo = 1
+	}
+	if SquareDistance(p.KingSquare(strong), promotionSquare)-tempo >
+		SquareDistance(p.KingSquare(weak), promotionSquare)+1 {
+		return scaleDrawish
+	}
+	return scaleNormal
+}
+
+// onlyKing returns true if the given color has no pieces other than the king
+func onlyKing(p *position.Position, c Color) bool {
+	return p.OccupiedBb(c) == p.PiecesBb(c, King)
+}
+
+// onlyPawns returns true if the given color has no pieces other than king and pawns
+func onlyPawns(p *position.Position, c Color) bool {
+	return p.OccupiedBb(c) == p.PiecesBb(c, King)|p.PiecesBb(c, Pawn)
+}
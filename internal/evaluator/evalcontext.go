@@ -0,0 +1,81 @@
+//
+// FrankyGo - UCI chess engine in GO for learning purposes
+//
+// MIT License
+//
+// Copyright (c) 2018-2020 Frank Kopp
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in all
+// copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE
+// SOFTWARE.
+//
+
+package evaluator
+
+import (
+	attacks2 "github.com/frankkopp/FrankyGo/internal/attacks"
+	. "github.com/frankkopp/FrankyGo/internal/config"
+	"github.com/frankkopp/FrankyGo/internal/position"
+	. "github.com/frankkopp/FrankyGo/internal/types"
+)
+
+// EvalContext holds the attack maps of a position which are needed by
+// several evaluation terms. It is built once at the start of an evaluation
+// and passed to each term function so the terms do not compute the same
+// attacks again and all terms see the same attacks.
+type EvalContext struct {
+	// squares attacked by the pawns of each color
+	PawnAttacks [ColorLength]Bitboard
+	// squares around the king of each color
+	KingRing [ColorLength]Bitboard
+	// attacks of all pieces - only computed with UseAttacksInEval
+	// and only after the lazy evaluation did not return early
+	Attacks *attacks2.Attacks
+}
+
+// newEvalContext creates a new EvalContext. Attacks are allocated once
+// and are reused for every evaluation.
+func newEvalContext() EvalContext {
+	return EvalContext{
+		Attacks: attacks2.NewAttacks(),
+	}
+}
+
+// build computes the pawn attacks and king rings of the given position
+// and resets the attacks. Pawn attacks and king rings are cheap to compute
+// and are always available.
+func (ctx *EvalContext) build(p *position.Position) {
+	for c := White; c <= Black; c++ {
+		pawns := p.PiecesBb(c, Pawn)
+		if c == White {
+			ctx.PawnAttacks[c] = ShiftBitboard(pawns, Northwest) | ShiftBitboard(pawns, Northeast)
+		} else {
+			ctx.PawnAttacks[c] = ShiftBitboard(pawns, Southwest) | ShiftBitboard(pawns, Southeast)
+		}
+		ctx.KingRing[c] = p.KingZone(c) &^ p.KingSquare(c).Bb()
+	}
+	if Settings.Eval.UseAttacksInEval {
+		ctx.Attacks.Clear()
+	}
+}
+
+// computeAttacks computes the attacks of all pieces of the given position.
+// This is expensive and therefore only done when the evaluation terms
+// using attacks are actually evaluated.
+func (ctx *EvalContext) computeAttacks(p *position.Position) {
+	ctx.Attacks.Compute(p)
+}
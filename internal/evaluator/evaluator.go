@@ -35,7 +35,6 @@ import (
 	"golang.org/x/text/language"
 	"golang.org/x/text/message"
 
-	attacks2 "github.com/frankkopp/FrankyGo/internal/attacks"
 	. "github.com/frankkopp/FrankyGo/internal/config"
 	myLogging "github.com/frankkopp/FrankyGo/internal/logging"
 	"github.com/frankkopp/FrankyGo/internal/position"
@@ -58,12 +57,17 @@ type Evaluator struct {
 	them            Color
 	ourKing         Square
 	theirKing       Square
-	kingRing        [ColorLength]Bitboard
 	ourPieces       Bitboard
 
 	score Score
+	scale int // in percent - for recognized end games
 
-	attacks *attacks2.Attacks
+	// contempt for the side the engine plays (root color)
+	rootColor Color
+	contempt  Value
+
+	// attack maps shared by the evaluation terms
+	ctx EvalContext
 }
 
 // to avoid object creation and memory allocation
@@ -84,8 +88,8 @@ func init() {
 // NewEvaluator creates a new instance of an Evaluator.
 func NewEvaluator() *Evaluator {
 	return &Evaluator{
-		log:     myLogging.GetLog(),
-		attacks: attacks2.NewAttacks(),
+		log: myLogging.GetLog(),
+		ctx: newEvalContext(),
 	}
 }
 
@@ -100,18 +104,15 @@ func (e *Evaluator) InitEval(p *position.Position) {
 	e.them = e.us.Flip()
 	e.ourKing = e.position.KingSquare(e.us)
 	e.theirKing = e.position.KingSquare(e.them)
-	e.kingRing[e.us] = GetAttacksBb(King, e.ourKing, BbZero)
-	e.kingRing[e.them] = GetAttacksBb(King, e.theirKing, BbZero)
 	e.ourPieces = e.position.OccupiedBb(e.us)
 
 	// reset all values
 	e.score.MidGameValue = 0
 	e.score.EndGameValue = 0
+	e.scale = scaleNormal
 
-	// reset attacks
-	if Settings.Eval.UseAttacksInEval {
-		e.attacks.Clear()
-	}
+	// pawn attacks and king rings - resets the attacks
+	e.ctx.build(p)
 }
 
 // Evaluate calculates a value for a chess positions by
@@ -126,6 +127,28 @@ func (e *Evaluator) Evaluate(position *position.Position) Value {
 	return e.evaluate()
 }
 
+// SetContempt sets the color the engine plays and the contempt used
+// for draw values. A contempt of 0 turns contempt off.
+func (e *Evaluator) SetContempt(rootColor Color, contempt Value) {
+	e.rootColor = rootColor
+	e.contempt = contempt
+}
+
+// DrawValue returns the value of a draw from the view of the next
+// player of the given position. The configured DrawValue is the value
+// of a draw for the next player. With contempt a draw is worse for the
+// side the engine plays (root color) and better for the opponent.
+func (e *Evaluator) DrawValue(position *position.Position) Value {
+	value := ValueDraw + Value(Settings.Search.DrawValue)
+	if e.contempt == 0 {
+		return value
+	}
+	if position.NextPlayer() == e.rootColor {
+		return value - e.contempt
+	}
+	return value + e.contempt
+}
+
 // value adds up the mid and end games scores after multiplying
 // them with the game phase factor.
 func (e *Evaluator) value() Value {
@@ -136,8 +159,24 @@ func (e *Evaluator) value() Value {
 // This assumes that InitEval() has been called beforehand.
 func (e *Evaluator) evaluate() Value {
 	// if not enough material on the board to achieve a mate it is a draw
+	// (the configured draw value is from the view of the next player)
 	if e.position.HasInsufficientMaterial() {
-		return ValueDraw
+		return e.DrawValue(e.position)
+	}
+
+	// known draws and drawish end games
+	if Settings.Eval.UseEndgameRecognizers && Settings.Eval.Mode != EvalMaterial {
+		e.scale = endgameScale(e.position)
+		if e.scale == scaleDraw {
+			return e.DrawValue(e.position)
+		}
+	}
+
+	// pawnless advantages of less than a rook are drawish
+	if Settings.Eval.UseEndgameScaling && Settings.Eval.Mode != EvalMaterial {
+		if scale := pawnlessScale(e.position); scale < e.scale {
+			e.scale = scale
+		}
 	}
 
 	// Each position is evaluated from the view of the white
@@ -154,6 +193,11 @@ func (e *Evaluator) evaluate() Value {
 	e.score.MidGameValue += int(e.position.PsqMidValue(White) - e.position.PsqMidValue(Black))
 	e.score.EndGameValue += int(e.position.PsqEndValue(White) - e.position.PsqEndValue(Black))
 
+	// fast material only evaluation for debugging and speed experiments
+	if Settings.Eval.Mode == EvalMaterial {
+		return e.finalEval(e.value())
+	}
+
 	// early exit
 	// arbitrary threshold - in early phases (game phase = 1.0) this is doubled
 	// in late phases it stands as it is
@@ -173,34 +217,79 @@ func (e *Evaluator) evaluate() Value {
 	// possible. If we could use it in search as well we could move
 	// creating this to an earlier point in time in the search
 	if Settings.Eval.UseAttacksInEval {
-		e.attacks.Compute(e.position)
+		e.ctx.computeAttacks(e.position)
 	}
 
 	// evaluate pawns
-	// TODO
+	if Settings.Eval.UsePawnStructure {
+		e.score.Add(*e.evalPawns(&e.ctx, White))
+		e.score.Sub(*e.evalPawns(&e.ctx, Black))
+	}
+
+	// material imbalance
+	if Settings.Eval.UseImbalance {
+		e.score.Add(*e.evalImbalance(&e.ctx, White))
+		e.score.Sub(*e.evalImbalance(&e.ctx, Black))
+	}
+
+	// space in the center
+	if Settings.Eval.UseSpace {
+		e.score.Add(*e.evalSpace(&e.ctx, White))
+		e.score.Sub(*e.evalSpace(&e.ctx, Black))
+	}
 
 	// evaluate pieces - builds attacks and mobility
 	if Settings.Eval.UseAdvancedPieceEval {
-		e.score.Add(*e.evalPiece(White, Knight))
-		e.score.Sub(*e.evalPiece(Black, Knight))
-		e.score.Add(*e.evalPiece(White, Bishop))
-		e.score.Sub(*e.evalPiece(Black, Bishop))
-		e.score.Add(*e.evalPiece(White, Rook))
-		e.score.Sub(*e.evalPiece(Black, Rook))
-		e.score.Add(*e.evalPiece(White, Queen))
-		e.score.Sub(*e.evalPiece(Black, Queen))
+		e.score.Add(*e.evalPiece(&e.ctx, White, Knight))
+		e.score.Sub(*e.evalPiece(&e.ctx, Black, Knight))
+		e.score.Add(*e.evalPiece(&e.ctx, White, Bishop))
+		e.score.Sub(*e.evalPiece(&e.ctx, Black, Bishop))
+		e.score.Add(*e.evalPiece(&e.ctx, White, Rook))
+		e.score.Sub(*e.evalPiece(&e.ctx, Black, Rook))
+		e.score.Add(*e.evalPiece(&e.ctx, White, Queen))
+		e.score.Sub(*e.evalPiece(&e.ctx, Black, Queen))
 	}
 
 	// mobility
 	if Settings.Eval.UseAttacksInEval && Settings.Eval.UseMobility {
-		e.score.MidGameValue += (e.attacks.Mobility[White] - e.attacks.Mobility[Black]) * Settings.Eval.MobilityBonus
+		e.score.MidGameValue += (e.ctx.Attacks.Mobility[White] - e.ctx.Attacks.Mobility[Black]) * Settings.Eval.MobilityBonus
 		e.score.EndGameValue += e.score.MidGameValue
 	}
 
+	// threats against enemy pieces
+	if Settings.Eval.UseThreats {
+		e.score.Add(*e.evalThreats(&e.ctx, White))
+		e.score.Sub(*e.evalThreats(&e.ctx, Black))
+	}
+
+	// trapped pieces
+	if Settings.Eval.UseAttacksInEval && Settings.Eval.UseTrappedPieces {
+		e.score.Add(*e.evalTrappedPieces(&e.ctx, White))
+		e.score.Sub(*e.evalTrappedPieces(&e.ctx, Black))
+	}
+
+	// rook and queen batteries
+	if Settings.Eval.UseBatteries {
+		e.score.Add(*e.evalBatteries(&e.ctx, White))
+		e.score.Sub(*e.evalBatteries(&e.ctx, Black))
+	}
+
 	// evaluate king
 	if Settings.Eval.UseKingEval {
-		e.score.Add(*e.evalKing(White))
-		e.score.Sub(*e.evalKing(Black))
+		e.score.Add(*e.evalKing(&e.ctx, White))
+		e.score.Sub(*e.evalKing(&e.ctx, Black))
+	}
+
+	// king pawn shield and pawn storm
+	if Settings.Eval.UseKingShield {
+		e.score.Add(*e.evalKingShield(&e.ctx, White))
+		e.score.Sub(*e.evalKingShield(&e.ctx, Black))
+	}
+
+	// king tropism
+	if Settings.Eval.UseKingTropism {
+		e.score.Add(*e.evalKingTropism(&e.ctx, White))
+		e.score.Sub(*e.evalKingTropism(&e.ctx, Black))
 	}
 
 	// TEMPO Bonus for the side to move (helps with evaluation alternation -
@@ -217,23 +306,119 @@ func (e *Evaluator) evaluate() Value {
 // finalEval returns the value which is calculated always from the view of
 // white from the view of the next player of the position.
 func (e *Evaluator) finalEval(value Value) Value {
+	// scale down evaluations of recognized end games
+	if e.scale != scaleNormal {
+		value = Value(int(value) * e.scale / scaleNormal)
+	}
+	// a static evaluation must never reach the mate value range as this
+	// would be mistaken for a mate by the search and the tt
+	if value >= ValueCheckMateThreshold {
+		value = ValueCheckMateThreshold - 1
+	} else if value <= -ValueCheckMateThreshold {
+		value = -ValueCheckMateThreshold + 1
+	}
 	// we can use the Direction factor to avoid an if statement
 	// Direction returns positive 1 for White and negative 1 (-1) for Black
 	return value * Value(e.position.NextPlayer().Direction())
 }
 
-func (e *Evaluator) evalKing(c Color) *Score {
+// evalPawns evaluates the pawn structure of the given color.
+// Connected pawns (defended by another pawn) and phalanx pawns (with a
+// pawn of the same color next to it on the same rank) get a bonus which
+// grows with the relative rank of the pawn. Connected pawns are more
+// valuable in the end game, phalanx pawns in the middle game.
+func (e *Evaluator) evalPawns(ctx *EvalContext, c Color) *Score {
+	tmpScore.MidGameValue = 0
+	tmpScore.EndGameValue = 0
+	us := c
+
+	pawns := e.position.PiecesBb(us, Pawn)
+	connected := pawns & ctx.PawnAttacks[us]
+	phalanx := pawns & (ShiftBitboard(pawns, East) | ShiftBitboard(pawns, West))
+
+	for connected != BbZero {
+		bonus := Settings.Eval.ConnectedPawnBonus * relativeRank(us, connected.PopLsb())
+		tmpScore.MidGameValue += bonus
+		tmpScore.EndGameValue += bonus + bonus/2
+	}
+	for phalanx != BbZero {
+		bonus := Settings.Eval.PhalanxPawnBonus * relativeRank(us, phalanx.PopLsb())
+		tmpScore.MidGameValue += bonus
+		tmpScore.EndGameValue += bonus / 2
+	}
+	return &tmpScore
+}
+
+// evalImbalance evaluates the material of the given color beyond the
+// simple sum of piece values. Knights get more valuable with more own
+// pawns on the board and rooks less valuable as they need open files.
+// A second rook is partly redundant.
+func (e *Evaluator) evalImbalance(ctx *EvalContext, c Color) *Score {
+	tmpScore.MidGameValue = 0
+	tmpScore.EndGameValue = 0
+	us := c
+
+	pawnsAbove5 := e.position.Count(us, Pawn) - 5
+	tmpScore.MidGameValue += e.position.Count(us, Knight) * pawnsAbove5 * Settings.Eval.KnightPawnBonus
+	tmpScore.MidGameValue -= e.position.Count(us, Rook) * pawnsAbove5 * Settings.Eval.RookPawnMalus
+	if e.position.Count(us, Rook) > 1 {
+		tmpScore.MidGameValue -= Settings.Eval.RedundantRookMalus
+	}
+	tmpScore.EndGameValue = tmpScore.MidGameValue
+	return &tmpScore
+}
+
+// evalSpace evaluates the space the given color controls in the center.
+// Squares on the center files (c-f) in our own half which are behind one of
+// our pawns and not attacked by an enemy pawn are counted. Space is mainly
+// important in the opening and middle game.
+func (e *Evaluator) evalSpace(ctx *EvalContext, c Color) *Score {
+	tmpScore.MidGameValue = 0
+	tmpScore.EndGameValue = 0
+	us := c
+	them := us.Flip()
+
+	ourPawns := e.position.PiecesBb(us, Pawn)
+	var spaceMask, behind Bitboard
+	if us == White {
+		spaceMask = (FileC_Bb | FileD_Bb | FileE_Bb | FileF_Bb) & (Rank2_Bb | Rank3_Bb | Rank4_Bb)
+		behind = ShiftBitboard(ourPawns, South)
+		behind |= ShiftBitboard(behind, South)
+		behind |= ShiftBitboard(behind, South)
+	} else {
+		spaceMask = (FileC_Bb | FileD_Bb | FileE_Bb | FileF_Bb) & (Rank7_Bb | Rank6_Bb | Rank5_Bb)
+		behind = ShiftBitboard(ourPawns, North)
+		behind |= ShiftBitboard(behind, North)
+		behind |= ShiftBitboard(behind, North)
+	}
+	safe := spaceMask & behind &^ ourPawns &^ ctx.PawnAttacks[them]
+
+	tmpScore.MidGameValue = safe.PopCount() * Settings.Eval.SpaceBonus
+	// tmpScore.EndGameValue += 0
+	return &tmpScore
+}
+
+// relativeRank returns the rank of the square seen from the given
+// color's side of the board (0 for the first rank, 7 for the last)
+func relativeRank(c Color, sq Square) int {
+	if c == White {
+		return int(sq.RankOf())
+	}
+	return int(Rank8 - sq.RankOf())
+}
+
+func (e *Evaluator) evalKing(ctx *EvalContext, c Color) *Score {
 	tmpScore.MidGameValue = 0
 	tmpScore.EndGameValue = 0
 	us := c
 	them := us.Flip()
 
-	// pawn shield is done in pawns
+	// pawn shield is done in evalKingShield
 
 	if Settings.Eval.UseAttacksInEval {
 		// king safety / attacks to the king and king ring
-		enemyAttacks := e.kingRing[us] & e.attacks.All[them]
-		ourDefence := e.kingRing[us] & e.attacks.All[us]
+		enemyAttacks := ctx.KingRing[us] & ctx.Attacks.All[them]
+		ourDefence := ctx.KingRing[us] & ctx.Attacks.All[us]
 		// malus for difference between attacker and defender
 		if enemyAttacks > ourDefence {
 			tmpScore.MidGameValue -= (enemyAttacks.PopCount() - ourDefence.PopCount()) * Settings.Eval.KingDangerMalus
@@ -244,7 +429,7 @@ func (e *Evaluator) evalKing(c Color) *Score {
 		}
 
 		// king ring attacks
-		if a := e.attacks.All[us] & e.kingRing[them]; a > 0 {
+		if a := ctx.Attacks.All[us] & ctx.KingRing[them]; a > 0 {
 			tmpScore.MidGameValue += Settings.Eval.KingRingAttacksBonus
 			tmpScore.EndGameValue += Settings.Eval.KingRingAttacksBonus
 		}
@@ -252,8 +437,176 @@ func (e *Evaluator) evalKing(c Color) *Score {
 	return &tmpScore
 }
 
+// evalKingShield evaluates the pawns in front of the castled king of the
+// given color on the king file and its neighbour files. Own pawns on the
+// 2nd rank get a bonus (half the bonus on the 3rd rank) and enemy pawns
+// advancing on these files to the 5th, 4th or 3rd rank get a malus which
+// grows the closer they get. Only relevant in the middle game.
+func (e *Evaluator) evalKingShield(ctx *EvalContext, c Color) *Score {
+	tmpScore.MidGameValue = 0
+	tmpScore.EndGameValue = 0
+	us := c
+	them := us.Flip()
+	kingSq := e.position.KingSquare(us)
+
+	// only for a castled king on the 1st rank
+	if relativeRank(us, kingSq) != 0 || (kingSq.FileOf() > FileC && kingSq.FileOf() < FileF) {
+		return &tmpScore
+	}
+
+	files := kingSq.FileOf().Bb()
+	if kingSq.FileOf() > FileA {
+		files |= (kingSq.FileOf() - 1).Bb()
+	}
+	if kingSq.FileOf() < FileH {
+		files |= (kingSq.FileOf() + 1).Bb()
+	}
+
+	// shield
+	shield := e.position.PiecesBb(us, Pawn) & files
+	for shield != BbZero {
+		switch relativeRank(us, shield.PopLsb()) {
+		case 1:
+			tmpScore.MidGameValue += Settings.Eval.KingShieldBonus
+		case 2:
+			tmpScore.MidGameValue += Settings.Eval.KingShieldBonus / 2
+		}
+	}
+
+	// storm
+	storm := e.position.PiecesBb(them, Pawn) & files
+	for storm != BbZero {
+		if r := relativeRank(us, storm.PopLsb()); r >= 2 && r <= 4 {
+			tmpScore.MidGameValue -= (5 - r) * Settings.Eval.KingStormMalus
+		}
+	}
+	// tmpScore.EndGameValue += 0
+	return &tmpScore
+}
+
+// evalThreats evaluates the attacks of the given color on enemy pieces.
+// Attacked enemy pieces which are not defended are hanging and get a
+// bonus. Defended enemy pieces get a smaller bonus when they are attacked
+// by a piece of lower value as they still might be lost. Kings and pawns
+// are not counted as targets. The same classification as in
+// Position.IsQuiet is used (see Position.Threats).
+func (e *Evaluator) evalThreats(ctx *EvalContext, c Color) *Score {
+	us := c
+	them := us.Flip()
+
+	targets := e.position.OccupiedBb(them) &^ e.position.PiecesBb(them, Pawn) &^ e.position.PiecesBb(them, King)
+	hanging, threatened := e.position.Threats(targets, us)
+	bonus := hanging.PopCount()*Settings.Eval.HangingPieceBonus + threatened.PopCount()*Settings.Eval.ThreatBonus
+	tmpScore.MidGameValue = bonus
+	tmpScore.EndGameValue = bonus
+	return &tmpScore
+}
+
+// evalTrappedPieces gives a malus for pieces of the given color which have
+// at most one safe square to move to (not occupied by own pieces and not
+// attacked by enemy pawns) and can't easily escape. These are knights and
+// bishops in the enemy half (e.g. a bishop on h7 after capturing a pawn
+// trapped by pawns on g6 and f7). Rooks trapped by their own king are
+// handled in rookEval (RookTrappedMalus). This needs the attacks in the
+// context.
+func (e *Evaluator) evalTrappedPieces(ctx *EvalContext, c Color) *Score {
+	tmpScore.MidGameValue = 0
+	tmpScore.EndGameValue = 0
+	us := c
+	them := us.Flip()
+
+	pieces := e.position.PiecesBb(us, Knight) | e.position.PiecesBb(us, Bishop)
+	for pieces != BbZero {
+		sq := pieces.PopLsb()
+		safeSquares := ctx.Attacks.From[us][sq] &^ e.position.OccupiedBb(us) &^ ctx.PawnAttacks[them]
+		if safeSquares.PopCount() <= 1 && relativeRank(us, sq) >= 4 {
+			tmpScore.MidGameValue -= Settings.Eval.TrappedPieceMalus
+			tmpScore.EndGameValue -= Settings.Eval.TrappedPieceMalus
+		}
+	}
+	return &tmpScore
+}
+
+// evalBatteries gives a bonus for pairs of rooks doubled on a file without
+// own pawns and for pairs of queen and rook lined up on such a file or on
+// the rank of the enemy king or the 7th rank. There must not be any other
+// piece between the two pieces.
+func (e *Evaluator) evalBatteries(ctx *EvalContext, c Color) *Score {
+	tmpScore.MidGameValue = 0
+	tmpScore.EndGameValue = 0
+	us := c
+	them := us.Flip()
+	occupied := e.position.OccupiedAll()
+	ourPawns := e.position.PiecesBb(us, Pawn)
+	queens := e.position.PiecesBb(us, Queen)
+	rooks := e.position.PiecesBb(us, Rook)
+
+	targetRanks := e.position.KingSquare(them).RankOf().Bb()
+	if us == White {
+		targetRanks |= Rank7_Bb
+	} else {
+		targetRanks |= Rank2_Bb
+	}
+
+	for rooks != BbZero {
+		sq := rooks.PopLsb()
+		// each pair of rooks is only counted once
+		others := rooks
+		for others != BbZero {
+			if isBattery(sq, others.PopLsb(), occupied, ourPawns, BbZero) {
+				tmpScore.MidGameValue += Settings.Eval.RookBatteryBonus
+				tmpScore.EndGameValue += Settings.Eval.RookBatteryBonus
+			}
+		}
+		ourQueens := queens
+		for ourQueens != BbZero {
+			if isBattery(sq, ourQueens.PopLsb(), occupied, ourPawns, targetRanks) {
+				tmpScore.MidGameValue += Settings.Eval.QueenRookBatteryBonus
+				// tmpScore.EndGameValue += 0
+			}
+		}
+	}
+	return &tmpScore
+}
+
+// isBattery returns true if the two squares are on a file without own
+// pawns or on one of the given ranks and there is no piece between them.
+func isBattery(sq1 Square, sq2 Square, occupied Bitboard, ourPawns Bitboard, ranks Bitboard) bool {
+	if Intermediate(sq1, sq2)&occupied != BbZero {
+		return false
+	}
+	switch {
+	case sq1.FileOf() == sq2.FileOf():
+		return sq1.FileOf().Bb()&ourPawns == BbZero
+	case sq1.RankOf() == sq2.RankOf():
+		return sq1.RankOf().Bb()&ranks != BbZero
+	}
+	return false
+}
+
+// evalKingTropism gives a bonus for queens and knights close to the
+// enemy king. This encourages attacks on the king and is only relevant
+// in the middle game.
+func (e *Evaluator) evalKingTropism(ctx *EvalContext, c Color) *Score {
+	tmpScore.MidGameValue = 0
+	tmpScore.EndGameValue = 0
+	us := c
+	theirKing := e.position.KingSquare(us.Flip())
+
+	queens := e.position.PiecesBb(us, Queen)
+	for queens != BbZero {
+		tmpScore.MidGameValue += (7 - SquareDistance(queens.PopLsb(), theirKing)) * Settings.Eval.QueenTropismBonus
+	}
+	knights := e.position.PiecesBb(us, Knight)
+	for knights != BbZero {
+		tmpScore.MidGameValue += (7 - SquareDistance(knights.PopLsb(), theirKing)) * Settings.Eval.KnightTropismBonus
+	}
+	// tmpScore.EndGameValue += 0
+	return &tmpScore
+}
+
 // evalPiece is the evaluation function for all pieces except pawns and kings.
-func (e *Evaluator) evalPiece(c Color, pieceType PieceType) *Score {
+func (e *Evaluator) evalPiece(ctx *EvalContext, c Color, pieceType PieceType) *Score {
 	tmpScore.MidGameValue = 0
 	tmpScore.EndGameValue = 0
 	us := c
@@ -267,7 +620,7 @@ func (e *Evaluator) evalPiece(c Color, pieceType PieceType) *Score {
 	switch pieceType {
 	case Bishop:
 		// bonus for pair
-		if pieceBb.PopCount() > 1 {
+		if e.position.Count(us, Bishop) > 1 {
 			tmpScore.MidGameValue += Settings.Eval.BishopPairBonus
 			tmpScore.EndGameValue += Settings.Eval.BishopPairBonus
 		}
@@ -284,7 +637,7 @@ func (e *Evaluator) evalPiece(c Color, pieceType PieceType) *Score {
 		case Bishop:
 			e.bishopEval(us, them, sq)
 		case Rook:
-			e.rookEval(sq, us)
+			e.rookEval(ctx, sq, us)
 		case Queen:
 			// none yet
 		}
@@ -293,7 +646,7 @@ func (e *Evaluator) evalPiece(c Color, pieceType PieceType) *Score {
 	return &tmpScore
 }
 
-func (e *Evaluator) rookEval(sq Square, us Color) {
+func (e *Evaluator) rookEval(ctx *EvalContext, sq Square, us Color) {
 	// same file as queen
 	if sq.FileOf().Bb()&e.position.PiecesBb(us, Queen) > 0 {
 		tmpScore.MidGameValue += Settings.Eval.RookOnQueenFileBonus
@@ -308,7 +661,7 @@ func (e *Evaluator) rookEval(sq Square, us Color) {
 
 	// trapped by king
 	// on same row as king but on the outside from king
-	if Settings.Eval.UseAttacksInEval && e.attacks.From[us][sq].PopCount() < 3 &&
+	if Settings.Eval.UseAttacksInEval && ctx.Attacks.From[us][sq].PopCount() < 3 &&
 		e.position.KingSquare(us).RankOf() == sq.RankOf() &&
 		(e.position.KingSquare(us).FileOf() < FileE) == (sq.FileOf() < e.position.KingSquare(us).FileOf()) {
 		tmpScore.MidGameValue -= Settings.Eval.RookTrappedMalus
@@ -373,11 +726,47 @@ func (e *Evaluator) Report() string {
 	report.WriteString(out.Sprintf("%s\n", e.position.StringBoard()))
 	report.WriteString(out.Sprintf("GamePhase Factor: %f\n", e.position.GamePhaseFactor()))
 	report.WriteString(out.Sprintf("(evals from the view of white player)\n", e.Evaluate(e.position)))
-	// report.WriteString(out.Sprintf("Material    : %d\n", e.material()))
-	// report.WriteString(out.Sprintf("Positional  : %d\n", e.positional()))
-	// report.WriteString(out.Sprintf("Tempo       : %d\n", e.tempo()))
+	report.WriteString(out.Sprintf("Eval Mode         : %s\n", Settings.Eval.Mode))
+	// report.WriteString(out.Sprintf("Material          : %d\n", e.material()))
+	// report.WriteString(out.Sprintf("Positional        : %d\n", e.positional()))
+	// report.WriteString(out.Sprintf("Tempo             : %d\n", e.tempo()))
+	// the material mode has no further evaluation terms
+	if Settings.Eval.Mode != EvalMaterial {
+		if Settings.Eval.UsePawnStructure {
+			report.WriteString(out.Sprintf("Pawns White       : %s\n", e.evalPawns(&e.ctx, White).String()))
+			report.WriteString(out.Sprintf("Pawns Black       : %s\n", e.evalPawns(&e.ctx, Black).String()))
+		}
+		if Settings.Eval.UseImbalance {
+			report.WriteString(out.Sprintf("Imbalance White   : %s\n", e.evalImbalance(&e.ctx, White).String()))
+			report.WriteString(out.Sprintf("Imbalance Black   : %s\n", e.evalImbalance(&e.ctx, Black).String()))
+		}
+		if Settings.Eval.UseSpace {
+			report.WriteString(out.Sprintf("Space White       : %s\n", e.evalSpace(&e.ctx, White).String()))
+			report.WriteString(out.Sprintf("Space Black       : %s\n", e.evalSpace(&e.ctx, Black).String()))
+		}
+		if Settings.Eval.UseThreats {
+			report.WriteString(out.Sprintf("Threats White     : %s\n", e.evalThreats(&e.ctx, White).String()))
+			report.WriteString(out.Sprintf("Threats Black     : %s\n", e.evalThreats(&e.ctx, Black).String()))
+		}
+		if Settings.Eval.UseAttacksInEval && Settings.Eval.UseTrappedPieces {
+			report.WriteString(out.Sprintf("Trapped White     : %s\n", e.evalTrappedPieces(&e.ctx, White).String()))
+			report.WriteString(out.Sprintf("Trapped Black     : %s\n", e.evalTrappedPieces(&e.ctx, Black).String()))
+		}
+		if Settings.Eval.UseBatteries {
+			report.WriteString(out.Sprintf("Batteries White   : %s\n", e.evalBatteries(&e.ctx, White).String()))
+			report.WriteString(out.Sprintf("Batteries Black   : %s\n", e.evalBatteries(&e.ctx, Black).String()))
+		}
+		if Settings.Eval.UseKingShield {
+			report.WriteString(out.Sprintf("King Shield White : %s\n", e.evalKingShield(&e.ctx, White).String()))
+			report.WriteString(out.Sprintf("King Shield Black : %s\n", e.evalKingShield(&e.ctx, Black).String()))
+		}
+		if Settings.Eval.UseKingTropism {
+			report.WriteString(out.Sprintf("Tropism White     : %s\n", e.evalKingTropism(&e.ctx, White).String()))
+			report.WriteString(out.Sprintf("Tropism Black     : %s\n", e.evalKingTropism(&e.ctx, Black).String()))
+		}
+	}
 	report.WriteString(out.Sprintf("-------------------------\n", e.Evaluate(e.position)))
-	report.WriteString(out.Sprintf("Eval value  : %d \n(from the view of next player = %s)\n", e.Evaluate(e.position), e.position.NextPlayer().String()))
+	report.WriteString(out.Sprintf("Eval value        : %d \n(from the view of next player = %s)\n", e.Evaluate(e.position), e.position.NextPlayer().String()))
 
 	return report.String()
 }
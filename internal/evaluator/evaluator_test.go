@@ -301,6 +301,379 @@ func TestStartPosZeroEval(t *testing.T) {
 	assert.EqualValues(t, 0, v)
 }
 
+func TestDrawValue(t *testing.T) {
+	defer func() { Settings.Search.DrawValue = 0 }()
+	Settings.Search.DrawValue = 20
+	e := NewEvaluator()
+	p := position.NewPosition("8/3k4/8/8/8/2B5/4K3/8 w - -")
+	assert.EqualValues(t, 20, e.Evaluate(p))
+
+	// with contempt a draw is bad for the root color and good for the opponent
+	e.SetContempt(White, 30)
+	assert.EqualValues(t, -10, e.Evaluate(p))
+	p = position.NewPosition("8/3k4/8/8/8/2B5/4K3/8 b - -")
+	assert.EqualValues(t, 50, e.Evaluate(p))
+	assert.EqualValues(t, e.DrawValue(p), e.Evaluate(p))
+}
+
+func TestEvalBelowMateThreshold(t *testing.T) {
+	e := NewEvaluator()
+	fens := []string{
+		"QQQQQQQQ/QQQQQQQQ/8/8/8/8/8/K6k w - -",
+		"QQQQQQQQ/QQQQQQQQ/8/8/8/8/8/K6k b - -",
+		"qqqqqqqq/qqqqqqqq/8/8/8/8/8/K6k w - -",
+		"qqqqqqqq/qqqqqqqq/8/8/8/8/8/K6k b - -",
+	}
+	for _, fen := range fens {
+		p := position.NewPosition(fen)
+		v := e.Evaluate(p)
+		assert.Less(t, int(v), int(ValueCheckMateThreshold), fen)
+		assert.Greater(t, int(v), int(-ValueCheckMateThreshold), fen)
+		assert.False(t, v.IsCheckMateValue(), fen)
+	}
+}
+
+func TestEvalPawnStructure(t *testing.T) {
+	e := NewEvaluator()
+	tests := []struct {
+		fen   string
+		color Color
+		mid   int
+		end   int
+	}{
+		// connected - d4 defended by c3 and e3
+		{"4k3/8/8/8/3P4/2P1P3/8/4K3 w - -", White, 9, 13},
+		{"4k3/8/2p1p3/3p4/8/8/8/4K3 b - -", Black, 9, 13},
+		// phalanx - c3, d3, e3
+		{"4k3/8/8/8/8/2PPP3/8/4K3 w - -", White, 12, 6},
+		{"4k3/8/2ppp3/8/8/8/8/4K3 w - -", Black, 12, 6},
+		// isolated
+		{"4k3/8/8/8/8/P1P1P3/8/4K3 w - -", White, 0, 0},
+		{"4k3/8/p1p1p3/8/8/8/8/4K3 w - -", Black, 0, 0},
+	}
+	for _, test := range tests {
+		e.InitEval(position.NewPosition(test.fen))
+		score := e.evalPawns(&e.ctx, test.color)
+		assert.EqualValues(t, test.mid, score.MidGameValue, test.fen)
+		assert.EqualValues(t, test.end, score.EndGameValue, test.fen)
+	}
+
+	// connected pawns are better than isolated pawns
+	defer func() { Settings.Eval.UsePawnStructure = false }()
+	Settings.Eval.Tempo = 0
+	p := position.NewPosition("4k3/p1p1p3/8/8/8/8/2PPP3/4K3 w - -")
+	Settings.Eval.UsePawnStructure = false
+	without := e.Evaluate(p)
+	Settings.Eval.UsePawnStructure = true
+	with := e.Evaluate(p)
+	assert.Greater(t, int(with), int(without))
+	assert.EqualValues(t, 0, e.Evaluate(position.NewPosition("4k3/2ppp3/8/8/8/8/2PPP3/4K3 w - -")))
+}
+
+// evalTermTest is a test case for an evaluation term with the expected
+// mid and end game score of the term for the given color.
+type evalTermTest struct {
+	fen   string
+	color Color
+	mid   int
+	end   int
+}
+
+// testEvalTerm checks the scores of an evaluation term against the given
+// test cases. Each position is evaluated with and without the term which
+// is switched on and off by the given setting. The term must change the
+// evaluation by its score for white minus its score for black (up to
+// rounding) and must be in the evaluation report with the given label.
+func testEvalTerm(t *testing.T, term func(*Evaluator, *EvalContext, Color) *Score, use *bool, label string, tests []evalTermTest) {
+	defer func(useLazyEval bool, useAttacksInEval bool, useTerm bool) {
+		Settings.Eval.UseLazyEval = useLazyEval
+		Settings.Eval.UseAttacksInEval = useAttacksInEval
+		*use = useTerm
+	}(Settings.Eval.UseLazyEval, Settings.Eval.UseAttacksInEval, *use)
+	Settings.Eval.UseLazyEval = false
+	Settings.Eval.UseAttacksInEval = true
+	e := NewEvaluator()
+	for _, test := range tests {
+		p := position.NewPosition(test.fen)
+		*use = false
+		without := e.Evaluate(p)
+		*use = true
+		with := e.Evaluate(p)
+
+		score := *term(e, &e.ctx, test.color)
+		assert.EqualValues(t, test.mid, score.MidGameValue, test.fen)
+		assert.EqualValues(t, test.end, score.EndGameValue, test.fen)
+
+		// the term is added for white and subtracted for black
+		diff := *term(e, &e.ctx, White)
+		diff.Sub(*term(e, &e.ctx, Black))
+		expected := int(diff.ValueFromScore(e.gamePhaseFactor)) * e.scale / scaleNormal * p.NextPlayer().Direction()
+		assert.InDelta(t, expected, int(with-without), 2, test.fen)
+
+		assert.Contains(t, e.Report(), label+" White", test.fen)
+	}
+}
+
+func TestEvalImbalance(t *testing.T) {
+	testEvalTerm(t, (*Evaluator).evalImbalance, &Settings.Eval.UseImbalance, "Imbalance", []evalTermTest{
+		// knight with many pawns gains (2 pawns more than 5 * 6)
+		{"4k3/8/8/8/8/8/PPPPPPP1/1N2K3 w - -", White, 12, 12},
+		// knight with few pawns loses
+		{"4k3/8/8/8/8/8/PP6/1N2K3 w - -", White, -18, -18},
+		// rook with many pawns loses
+		{"4k3/pppppppp/8/8/8/8/8/4K3 w - -", Black, 0, 0},
+		{"r3k3/pppppppp/8/8/8/8/8/4K3 w - -", Black, -36, -36},
+		// redundant rooks (only 5 pawns)
+		{"4k3/8/8/8/8/8/PPPPP3/R3K2R w - -", White, -20, -20},
+		{"r3k2r/ppppp3/8/8/8/8/8/4K3 w - -", Black, -20, -20},
+		// start position with 8 pawns, two knights and two rooks
+		{position.StartFen, White, 2*3*6 - 2*3*12 - 20, 2*3*6 - 2*3*12 - 20},
+		// knights against rooks with 8 pawns each
+		{"r3k2r/pppppppp/8/8/8/8/PPPPPPPP/1N2K1N1 w - -", White, 2 * 3 * 6, 2 * 3 * 6},
+		{"r3k2r/pppppppp/8/8/8/8/PPPPPPPP/1N2K1N1 w - -", Black, -2*3*12 - 20, -2*3*12 - 20},
+	})
+}
+
+func TestEvalKingTropism(t *testing.T) {
+	testEvalTerm(t, (*Evaluator).evalKingTropism, &Settings.Eval.UseKingTropism, "Tropism", []evalTermTest{
+		// queen and knight close to the enemy king vs far away
+		{"6k1/5ppp/5N2/6Q1/8/8/8/K7 w - -", White, 4*5 + 5*3, 0},
+		{"6k1/5ppp/8/8/8/8/8/KNQ5 w - -", White, 0, 0},
+		// black pieces close to the white king
+		{"k7/8/8/8/8/2n5/1q6/K7 b - -", Black, 6*5 + 5*3, 0},
+		{"k7/8/8/8/8/2n5/1q6/K7 b - -", White, 0, 0},
+	})
+}
+
+func TestEvalSpace(t *testing.T) {
+	bonus := Settings.Eval.SpaceBonus
+	testEvalTerm(t, (*Evaluator).evalSpace, &Settings.Eval.UseSpace, "Space", []evalTermTest{
+		// advanced central pawns vs pawns on their start squares
+		{"4k3/pppppppp/8/8/2PPPP2/8/PP4PP/4K3 w - -", White, 8 * bonus, 0},
+		{"4k3/pppppppp/8/8/8/8/PPPPPPPP/4K3 w - -", White, 0, 0},
+		// squares attacked by enemy pawns are not safe
+		{"4k3/pp4pp/8/8/2PPPP2/1p6/PP4PP/4K3 w - -", White, 7 * bonus, 0},
+		// black space behind advanced pawns
+		{"4k3/pp4pp/8/2pppp2/8/8/PPPPPPPP/4K3 b - -", Black, 8 * bonus, 0},
+		{"4k3/pp4pp/8/2pppp2/8/8/PPPPPPPP/4K3 b - -", White, 0, 0},
+	})
+}
+
+func TestEvalThreats(t *testing.T) {
+	hanging := Settings.Eval.HangingPieceBonus
+	threat := Settings.Eval.ThreatBonus
+	testEvalTerm(t, (*Evaluator).evalThreats, &Settings.Eval.UseThreats, "Threats", []evalTermTest{
+		// knight attacks the undefended rook
+		{"4k3/pp6/8/3r4/8/4N3/PP6/4K3 w - -", White, hanging, hanging},
+		{"4k3/pp6/8/3r4/8/4N3/PP6/4K3 w - -", Black, 0, 0},
+		// pawn attacks the defended knight
+		{"4k3/8/4p3/3n4/4P3/8/8/4K3 w - -", White, threat, threat},
+		{"4k3/8/4p3/3n4/4P3/8/8/4K3 w - -", Black, 0, 0},
+		// rook attacks the defended rook - no threat
+		{"4k3/8/4p3/3r4/8/8/8/3RK3 w - -", White, 0, 0},
+	})
+}
+
+func TestEvalBatteries(t *testing.T) {
+	rooks := Settings.Eval.RookBatteryBonus
+	queenRook := Settings.Eval.QueenRookBatteryBonus
+	testEvalTerm(t, (*Evaluator).evalBatteries, &Settings.Eval.UseBatteries, "Batteries", []evalTermTest{
+		// rooks doubled on the open d file
+		{"4k3/pp3ppp/8/8/8/8/PP1R1PPP/3R2K1 w - -", White, rooks, rooks},
+		{"4k3/pp3ppp/8/8/8/8/PP1R1PPP/3R2K1 w - -", Black, 0, 0},
+		// own pawn on the file
+		{"4k3/pp3ppp/8/8/8/3P4/PP1R1PPP/3R2K1 w - -", White, 0, 0},
+		// a piece between the rooks
+		{"4k3/pp3ppp/8/8/3R4/8/PP1N1PPP/3R2K1 w - -", White, 0, 0},
+		// queen and rook on the 7th rank
+		{"4k3/QR3ppp/8/8/8/8/5PPP/6K1 w - -", White, queenRook, 0},
+		// queen and rook on the open d file
+		{"3qk3/pp4pp/8/8/8/8/PP1r2PP/3R1K2 b - -", Black, queenRook, 0},
+		// queen and rook on a rank not being the 7th or the king's rank
+		{"4k3/pp3ppp/8/8/8/3QR3/PP3PPP/6K1 w - -", White, 0, 0},
+	})
+}
+
+func TestEvalTrappedPieces(t *testing.T) {
+	malus := Settings.Eval.TrappedPieceMalus
+	testEvalTerm(t, (*Evaluator).evalTrappedPieces, &Settings.Eval.UseTrappedPieces, "Trapped", []evalTermTest{
+		// bishop on h7 trapped by the pawns on g6 and f7
+		{"4k3/5p1B/6p1/8/8/8/PP6/4K3 w - -", White, -malus, -malus},
+		{"4k3/5p1B/6p1/8/8/8/PP6/4K3 w - -", Black, 0, 0},
+		// the same for black
+		{"4k3/pp6/8/8/8/6P1/5P1b/4K3 b - -", Black, -malus, -malus},
+		{"4k3/pp6/8/8/8/6P1/5P1b/4K3 b - -", White, 0, 0},
+		// bishop which is free to move
+		{"4k3/5p2/6p1/8/8/3B4/PP6/4K3 w - -", White, 0, 0},
+		// a rook trapped by its king is only penalized in rookEval
+		{"4k3/pp6/8/8/8/8/6PP/5K1R w - -", White, 0, 0},
+		// nothing is trapped in the start position
+		{position.StartFen, White, 0, 0},
+		{position.StartFen, Black, 0, 0},
+	})
+}
+
+func TestEvalContext(t *testing.T) {
+	defer func() {
+		Settings.Eval.UseLazyEval = true
+		Settings.Eval.UseAttacksInEval = false
+	}()
+	Settings.Eval.UseLazyEval = false
+	Settings.Eval.UseAttacksInEval = true
+
+	fens := []string{
+		position.StartFen,
+		"r3k2r/p1ppqpb1/bn2pnp1/3PN3/1p2P3/2N2Q1p/PPPBBPPP/R3K2R w KQkq -",
+		"1r3rk1/1pnnq1bR/p1pp2B1/P2P1p2/1PP1pP2/2B3P1/5PK1/2Q4R w - -",
+		"6k1/5ppp/8/8/8/8/8/R5K1 b - -",
+	}
+	e := NewEvaluator()
+	for _, fen := range fens {
+		p := position.NewPosition(fen)
+		e.Evaluate(p)
+		occupied := p.OccupiedAll()
+		for c := White; c <= Black; c++ {
+			// pawn attacks and king ring
+			var pawnAttacks Bitboard
+			for pawns := p.PiecesBb(c, Pawn); pawns != BbZero; {
+				pawnAttacks |= GetPawnAttacks(c, pawns.PopLsb())
+			}
+			assert.Equal(t, pawnAttacks, e.ctx.PawnAttacks[c], fen)
+			assert.Equal(t, GetAttacksBb(King, p.KingSquare(c), BbZero), e.ctx.KingRing[c], fen)
+
+			// attacks of all other pieces (without pawns)
+			var all Bitboard
+			for _, pt := range []PieceType{King, Knight, Bishop, Rook, Queen} {
+				for pieces := p.PiecesBb(c, pt); pieces != BbZero; {
+					sq := pieces.PopLsb()
+					attacks := GetAttacksBb(pt, sq, occupied)
+					assert.Equal(t, attacks, e.ctx.Attacks.From[c][sq], fen)
+					all |= attacks
+				}
+			}
+			assert.Equal(t, all, e.ctx.Attacks.All[c], fen)
+			assert.Equal(t, pawnAttacks, e.ctx.Attacks.Pawns[c], fen)
+		}
+	}
+}
+
+func TestEvalModeMaterial(t *testing.T) {
+	defer func() {
+		Settings.Eval.Mode = EvalClassical
+		Settings.Eval.UsePawnStructure = false
+		Settings.Eval.UseImbalance = false
+		Settings.Eval.UseSpace = false
+		Settings.Eval.UseKingTropism = false
+	}()
+	Settings.Eval.UseLazyEval = false
+	Settings.Eval.UsePawnStructure = true
+	Settings.Eval.UseImbalance = true
+	Settings.Eval.UseSpace = true
+	Settings.Eval.UseKingTropism = true
+
+	e := NewEvaluator()
+	p := position.NewPosition("r1bqkb1r/pppp1ppp/2n2n2/4p3/2B1P3/5N2/PPPP1PPP/RNBQK2R w KQkq -")
+	classical := e.Evaluate(p)
+	assert.Contains(t, e.Report(), "Pawns White")
+
+	// material mode only uses material and piece square tables
+	Settings.Eval.Mode = EvalMaterial
+	e.InitEval(p)
+	e.score.MidGameValue = int(p.Material(White)-p.Material(Black)) + int(p.PsqMidValue(White)-p.PsqMidValue(Black))
+	e.score.EndGameValue = int(p.Material(White)-p.Material(Black)) + int(p.PsqEndValue(White)-p.PsqEndValue(Black))
+	expected := e.finalEval(e.value())
+	material := e.Evaluate(p)
+	assert.Equal(t, expected, material)
+	assert.NotEqual(t, classical, material)
+
+	// the report has no positional terms in material mode
+	report := e.Report()
+	assert.Contains(t, report, "Eval Mode         : material")
+	assert.NotContains(t, report, "Pawns White")
+	assert.NotContains(t, report, "Imbalance White")
+	assert.NotContains(t, report, "Space White")
+	assert.NotContains(t, report, "Tropism White")
+}
+
+func TestEndgameRecognizers(t *testing.T) {
+	defer func() { Settings.Eval.UseEndgameRecognizers = false }()
+	e := NewEvaluator()
+	tests := []struct {
+		fen   string
+		scale int
+	}{
+		// wrong bishop rook pawn with the defending king in the corner
+		{"k7/8/8/8/P7/8/8/2B1K3 w - -", scaleDraw},
+		{"1k6/8/8/8/P7/8/P7/2B1K3 b - -", scaleDraw},
+		{"2b1k3/8/8/8/p7/8/8/K7 b - -", scaleDraw},
+		// right bishop, defending king too far away or not a rook pawn
+		{"k7/8/8/8/P7/8/8/1B2K3 w - -", scaleNormal},
+		{"8/8/8/8/P7/8/8/2B1K2k w - -", scaleNormal},
+		{"k7/8/8/8/1P6/8/8/2B1K3 w - -", scaleNormal},
+		// queen against rook or bishop pawn on the 7th rank
+		{"7K/8/8/8/8/8/pk6/6Q1 w - -", scaleDrawish},
+		{"6q1/2PK4/8/8/8/8/8/k7 b - -", scaleDrawish},
+		// queen against center pawn or with the king close enough
+		{"7K/8/8/8/8/8/3pk3/6Q1 w - -", scaleNormal},
+		{"8/8/8/8/8/2K5/pk6/6Q1 w - -", scaleNormal},
+		// rook against an advanced pawn supported by its king
+		{"K7/8/8/8/8/3kp3/8/7R w - -", scaleDrawish},
+		{"7r/8/3PK3/8/8/8/8/k7 b - -", scaleDrawish},
+		{"K7/8/8/8/3kp3/8/8/7R w - -", scaleDrawish},
+		// rook king wins the race or the pawn is not supported
+		{"8/8/8/8/8/3kp3/8/4K2R w - -", scaleNormal},
+		{"K7/8/k7/8/8/4p3/8/7R w - -", scaleNormal},
+		{"8/8/8/2K5/8/3kp3/8/7R w - -", scaleNormal},
+		// other material
+		{"k7/8/8/8/P7/8/8/2N1K3 w - -", scaleNormal},
+		{position.StartFen, scaleNormal},
+	}
+	for _, test := range tests {
+		p := position.NewPosition(test.fen)
+		assert.EqualValues(t, test.scale, endgameScale(p), test.fen)
+
+		Settings.Eval.UseEndgameRecognizers = false
+		without := e.Evaluate(p)
+		Settings.Eval.UseEndgameRecognizers = true
+		with := e.Evaluate(p)
+		assert.EqualValues(t, int(without)*test.scale/scaleNormal, with, test.fen)
+	}
+}
+
+func TestEndgameScaling(t *testing.T) {
+	defer func() { Settings.Eval.UseEndgameScaling = false }()
+	e := NewEvaluator()
+	tests := []struct {
+		fen   string
+		scale int
+	}{
+		// less than a rook ahead without pawns
+		{"4k3/8/8/8/8/8/3b4/R3K3 w - -", scalePawnless},
+		{"4k3/8/8/8/3p4/2p5/p7/4K2N w - -", scalePawnless},
+		{"4k3/8/8/8/8/8/8/r2NK3 b - -", scalePawnless},
+		// enough material to win or pawns on the strong side
+		{"4k3/8/8/8/8/8/8/1NB1K3 w - -", scaleNormal},
+		{"4k3/8/8/8/8/8/8/R3K3 w - -", scaleNormal},
+		{"4k3/3r4/8/8/8/8/8/3QK3 w - -", scaleNormal},
+		{"4k3/8/8/8/8/8/3bP3/R3K3 w - -", scaleNormal},
+		{position.StartFen, scaleNormal},
+	}
+	for _, test := range tests {
+		p := position.NewPosition(test.fen)
+		assert.EqualValues(t, test.scale, pawnlessScale(p), test.fen)
+
+		Settings.Eval.UseEndgameScaling = false
+		without := e.Evaluate(p)
+		Settings.Eval.UseEndgameScaling = true
+		with := e.Evaluate(p)
+		assert.EqualValues(t, int(without)*test.scale/scaleNormal, with, test.fen)
+	}
+
+	// KBN vs K is still a win and KB vs K a draw
+	assert.Greater(t, int(e.Evaluate(position.NewPosition("4k3/8/8/8/8/8/8/1NB1K3 w - -"))), 500)
+	assert.EqualValues(t, ValueDraw, e.Evaluate(position.NewPosition("4k3/8/8/8/8/8/8/2B1K3 w - -")))
+}
+
 func TestMirroredZeroEval(t *testing.T) {
 	Settings.Eval.Tempo = 0
 	p := position.NewPosition("r1bq1rk1/pppp1pp1/2n2n1p/1B2p3/1b2P3/2N2N1P/PPPP1PP1/R1BQ1RK1 w - -")
@@ -364,3 +737,26 @@ func TestTimingEval(t *testing.T) {
 	}
 	_ = result
 }
+
+func TestEvalKingShield(t *testing.T) {
+	bonus := Settings.Eval.KingShieldBonus
+	malus := Settings.Eval.KingStormMalus
+	testEvalTerm(t, (*Evaluator).evalKingShield, &Settings.Eval.UseKingShield, "King Shield", []evalTermTest{
+		// intact shields for both kings
+		{"6k1/5ppp/8/8/8/8/5PPP/6K1 w - -", White, 3 * bonus, 0},
+		{"6k1/5ppp/8/8/8/8/5PPP/6K1 w - -", Black, 3 * bonus, 0},
+		// advanced and missing shield pawns
+		{"6k1/5ppp/8/8/8/6P1/5P2/6K1 w - -", White, bonus + bonus/2, 0},
+		{"rq3rk1/5ppp/8/8/8/7P/5P2/RQ3RK1 w - -", White, bonus + bonus/2, 0},
+		{"rq3rk1/5ppp/8/8/8/8/5P1P/RQ3RK1 w - -", White, 2 * bonus, 0},
+		// pawn storm approaching the white king
+		{"6k1/5p2/8/7p/6p1/8/5PPP/6K1 w - -", White, 3*bonus - 2*malus - malus, 0},
+		{"6k1/5p2/8/8/8/6pp/5PP1/6K1 w - -", White, 2*bonus - 3*malus - 3*malus, 0},
+		// king not castled
+		{"4k3/3ppp2/8/8/8/8/3PPP2/4K3 w - -", White, 0, 0},
+		{"4k3/3ppp2/8/8/8/8/3PPP2/4K3 w - -", Black, 0, 0},
+		// queen side castled king at the edge of the board
+		{"1k6/ppp5/8/8/8/8/8/K7 b - -", Black, 3 * bonus, 0},
+		{"k7/pp6/8/8/8/8/8/K7 b - -", Black, 2 * bonus, 0},
+	})
+}
@@ -42,8 +42,9 @@ var out = message.NewPrinter(language.German)
 // History is a data structure updated during search to provide the move
 // generator with valuable information for move sorting.
 type History struct {
-	HistoryCount [2][64][64]int64
-	CounterMoves [64][64]Move
+	HistoryCount  [2][64][64]int64
+	CounterMoves  [64][64]Move
+	FollowUpMoves [64][64]Move
 }
 
 func (h History) String() string {
@@ -56,13 +57,79 @@ func (h History) String() string {
 				sb.WriteString(out.Sprintf("%s=%-7d ", c.String(), count))
 			}
 			m := h.CounterMoves[sf][st]
-			sb.WriteString(out.Sprintf("cm=%s\n", m.StringUci()))
+			fm := h.FollowUpMoves[sf][st]
+			sb.WriteString(out.Sprintf("cm=%s fm=%s\n", m.StringUci(), fm.StringUci()))
 		}
 	}
 	return sb.String()
 }
 
+// HistoryMax is the maximum value of a history count. When a count
+// would exceed this value all counts of the color are halved to keep
+// the relation between moves intact while avoiding overflows.
+const HistoryMax int64 = 1 << 20
+
 // NewHistory creates a new History instance.
 func NewHistory() *History {
 	return &History{}
 }
+
+// IncHistoryCount increases the history count of the given move by inc.
+// If the count would exceed HistoryMax all counts of the color are
+// rescaled (halved) and the count is capped at HistoryMax.
+func (h *History) IncHistoryCount(c Color, from Square, to Square, inc int64) {
+	count := h.HistoryCount[c][from][to] + inc
+	if count > HistoryMax {
+		h.rescale(c)
+		count = h.HistoryCount[c][from][to] + inc
+		if count > HistoryMax {
+			count = HistoryMax
+		}
+	}
+	h.HistoryCount[c][from][to] = count
+}
+
+// DecHistoryCount decreases the history count of the given move by dec.
+// The count will not become negative.
+func (h *History) DecHistoryCount(c Color, from Square, to Square, dec int64) {
+	count := h.HistoryCount[c][from][to] - dec
+	if count < 0 {
+		count = 0
+	}
+	h.HistoryCount[c][from][to] = count
+}
+
+// UpdateHistoryGravity adds the given bonus (negative for a malus) to
+// the history count of the given move. The change is damped by the
+// current count ("history gravity") so the count converges towards
+// +/-HistoryMax and never leaves this range. Moves which are repeatedly
+// good keep a high count while older results fade out.
+func (h *History) UpdateHistoryGravity(c Color, from Square, to Square, bonus int64) {
+	if bonus > HistoryMax {
+		bonus = HistoryMax
+	} else if bonus < -HistoryMax {
+		bonus = -HistoryMax
+	}
+	absBonus := bonus
+	if absBonus < 0 {
+		absBonus = -absBonus
+	}
+	count := h.HistoryCount[c][from][to]
+	h.HistoryCount[c][from][to] = count + bonus - count*absBonus/HistoryMax
+}
+
+// Clear resets all history counts, counter moves and follow-up moves.
+func (h *History) Clear() {
+	h.HistoryCount = [2][64][64]int64{}
+	h.CounterMoves = [64][64]Move{}
+	h.FollowUpMoves = [64][64]Move{}
+}
+
+// rescale halves all history counts of the given color.
+func (h *History) rescale(c Color) {
+	for sf := SqA1; sf < SqNone; sf++ {
+		for st := SqA1; st < SqNone; st++ {
+			h.HistoryCount[c][sf][st] /= 2
+		}
+	}
+}
@@ -25,3 +25,64 @@
 //
 
 package history
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+
+	. "github.com/frankkopp/FrankyGo/internal/types"
+)
+
+func TestHistoryCountSaturates(t *testing.T) {
+	h := NewHistory()
+	h.IncHistoryCount(White, SqB1, SqC3, 1_000)
+	for i := 0; i < 10_000; i++ {
+		h.IncHistoryCount(White, SqE2, SqE4, 1<<10)
+		assert.LessOrEqual(t, h.HistoryCount[White][SqE2][SqE4], HistoryMax)
+		assert.GreaterOrEqual(t, h.HistoryCount[White][SqE2][SqE4], int64(0))
+	}
+	// other moves of the color have been rescaled, the other color is untouched
+	assert.Less(t, h.HistoryCount[White][SqB1][SqC3], int64(1_000))
+	h.IncHistoryCount(Black, SqE7, SqE5, HistoryMax*4)
+	assert.EqualValues(t, HistoryMax, h.HistoryCount[Black][SqE7][SqE5])
+
+	h.DecHistoryCount(White, SqB1, SqC3, 5_000)
+	assert.EqualValues(t, 0, h.HistoryCount[White][SqB1][SqC3])
+}
+
+func TestHistoryGravity(t *testing.T) {
+	h := NewHistory()
+	for i := 0; i < 1_000; i++ {
+		h.UpdateHistoryGravity(White, SqE2, SqE4, 1<<20)
+		h.UpdateHistoryGravity(White, SqD2, SqD4, -(1 << 30))
+		assert.LessOrEqual(t, h.HistoryCount[White][SqE2][SqE4], HistoryMax)
+		assert.GreaterOrEqual(t, h.HistoryCount[White][SqD2][SqD4], -HistoryMax)
+	}
+	assert.EqualValues(t, HistoryMax, h.HistoryCount[White][SqE2][SqE4])
+	assert.EqualValues(t, -HistoryMax, h.HistoryCount[White][SqD2][SqD4])
+
+	// a move which is good more often than bad gets a higher count than
+	// a move which is good and bad equally often
+	h.Clear()
+	for i := 0; i < 100; i++ {
+		h.UpdateHistoryGravity(White, SqG1, SqF3, 1<<10)
+		h.UpdateHistoryGravity(White, SqB1, SqC3, 1<<10)
+		if i%3 == 0 {
+			h.UpdateHistoryGravity(White, SqG1, SqF3, -(1 << 10))
+		} else {
+			h.UpdateHistoryGravity(White, SqB1, SqC3, -(1 << 10))
+		}
+	}
+	assert.Greater(t, h.HistoryCount[White][SqG1][SqF3], h.HistoryCount[White][SqB1][SqC3])
+	assert.Greater(t, h.HistoryCount[White][SqG1][SqF3], int64(0))
+}
+
+func TestHistoryClear(t *testing.T) {
+	h := NewHistory()
+	h.IncHistoryCount(White, SqE2, SqE4, 100)
+	h.IncHistoryCount(Black, SqE7, SqE5, 100)
+	h.CounterMoves[SqE2][SqE4] = CreateMove(SqE7, SqE5, Normal, PtNone)
+	h.Clear()
+	assert.Equal(t, History{}, *h)
+}
@@ -56,14 +56,18 @@ type Movegen struct {
 	legalMoves       *moveslice.MoveSlice
 
 	onDemandMoves          *moveslice.MoveSlice
+	losingCaptures         *moveslice.MoveSlice
 	currentODZobrist       position.Key
 	onDemandEvasionTargets Bitboard
 	currentODStage         int8
 	takeIndex              int
+	quietSkipped           bool // quiet stages skipped by a GenNonQuiet generation
 
 	killerMoves  [2]Move
 	pvMove       Move
 	pvMovePushed bool
+	pvOnly       bool // the on demand list only holds the pushed pv move
+	pvReturned   bool // the pv move has already been returned
 	historyData  *history.History
 }
 
@@ -102,6 +106,7 @@ func NewMoveGen() *Movegen {
 		legalMoves:       moveslice.NewMoveSlice(MaxMoves),
 
 		onDemandMoves:          moveslice.NewMoveSlice(MaxMoves),
+		losingCaptures:         moveslice.NewMoveSlice(MaxMoves),
 		currentODZobrist:       0,
 		onDemandEvasionTargets: BbZero,
 		currentODStage:         odNew,
@@ -189,6 +194,110 @@ func (mg *Movegen) GenerateLegalMoves(position *position.Position, mode GenMode)
 	return mg.legalMoves
 }
 
+// GenerateMovesFor generates the legal moves of the next player's pieces
+// of the given piece type. E.g. to show the moves of a selected piece
+// type or for partial analysis.
+// Uses the same list as GenerateLegalMoves.
+func (mg *Movegen) GenerateMovesFor(position *position.Position, pt PieceType) *moveslice.MoveSlice {
+	return mg.generateLegalMovesFiltered(position, func(m Move) bool {
+		return position.GetPiece(m.From()).TypeOf() == pt
+	})
+}
+
+// GenerateMovesFrom generates the legal moves of the next player's piece
+// on the given square. The list is empty if there is no piece of the
+// next player on the square. E.g. to highlight the moves of a selected
+// piece in a GUI.
+// Uses the same list as GenerateLegalMoves.
+func (mg *Movegen) GenerateMovesFrom(position *position.Position, sq Square) *moveslice.MoveSlice {
+	return mg.generateLegalMovesFiltered(position, func(m Move) bool {
+		return m.From() == sq
+	})
+}
+
+// GenerateQuietNonChecks generates the legal quiet moves of the next player
+// which do not give check. Quiet checks are arguably not quiet for pruning
+// purposes and are left out so they can be treated separately, e.g. never
+// be pruned.
+// Uses the same list as GenerateLegalMoves.
+func (mg *Movegen) GenerateQuietNonChecks(position *position.Position) *moveslice.MoveSlice {
+	mg.legalMoves.Clear()
+	mg.GeneratePseudoLegalMoves(position, GenQuiet, false)
+	mg.pseudoLegalMoves.FilterCopy(mg.legalMoves, func(i int) bool {
+		m := mg.pseudoLegalMoves.At(i)
+		return position.IsLegalMove(m) && !position.GivesCheck(m)
+	})
+	return mg.legalMoves
+}
+
+// generateLegalMovesFiltered generates the legal moves for which the
+// given filter returns true.
+func (mg *Movegen) generateLegalMovesFiltered(position *position.Position, filter func(m Move) bool) *moveslice.MoveSlice {
+	mg.legalMoves.Clear()
+	mg.GeneratePseudoLegalMoves(position, GenAll, false)
+	mg.pseudoLegalMoves.FilterCopy(mg.legalMoves, func(i int) bool {
+		m := mg.pseudoLegalMoves.At(i)
+		return filter(m) && position.IsLegalMove(m)
+	})
+	return mg.legalMoves
+}
+
+// GenerateEvasions generates all legal moves getting the next player out of check.
+// These are king moves to safe squares, captures of a single checking piece and
+// moves blocking a single sliding checker. In contrast to the evasion parameter of
+// GeneratePseudoLegalMoves all returned moves are verified to be legal.
+// If the position is not in check an empty list is returned.
+// Uses the same list as GenerateLegalMoves.
+func (mg *Movegen) GenerateEvasions(position *position.Position) *moveslice.MoveSlice {
+	mg.legalMoves.Clear()
+	if !position.HasCheck() {
+		return mg.legalMoves
+	}
+
+	// re-use move list
+	mg.pseudoLegalMoves.Clear()
+	evasionTargets := mg.getEvasionTargets(position)
+
+	// no castling when in check
+	mg.generatePawnMoves(position, GenAll, true, evasionTargets, mg.pseudoLegalMoves)
+	mg.generateKingMoves(position, GenAll, true, evasionTargets, mg.pseudoLegalMoves)
+	mg.generateMoves(position, GenAll, true, evasionTargets, mg.pseudoLegalMoves)
+
+	// PV, Killer and history handling
+	mg.updateSortValues(position, mg.pseudoLegalMoves)
+
+	// sort moves
+	mg.pseudoLegalMoves.Sort()
+
+	// remove internal sort value
+	mg.pseudoLegalMoves.ForEach(func(i int) {
+		mg.pseudoLegalMoves.Set(i, mg.pseudoLegalMoves.At(i).MoveOf())
+	})
+
+	// filter out remaining illegal moves
+	mg.pseudoLegalMoves.FilterCopy(mg.legalMoves, func(i int) bool {
+		return position.IsLegalMove(mg.pseudoLegalMoves.At(i))
+	})
+	return mg.legalMoves
+}
+
+// ForEachLegalMove generates all legal moves for the given position and calls
+// visit for each move with the position after the move has been made. The move
+// is undone after visit returns. This is a traversal primitive for perft like
+// tools, opening trees or analysis. visit must leave the child position as it
+// was given (e.g. undo all moves it makes) and may call ForEachLegalMove again
+// with the same Movegen instance.
+// Position is not able to offer this itself as movegen depends on position.
+func (mg *Movegen) ForEachLegalMove(p *position.Position, visit func(m Move, child *position.Position)) {
+	// copy the moves as the internal list is reused by nested calls
+	moves := mg.GenerateLegalMoves(p, GenAll).Clone()
+	for _, m := range *moves {
+		p.DoMove(m)
+		visit(m, p)
+		p.UndoMove()
+	}
+}
+
 // GetNextMove is the main function for phased generation of pseudo legal moves.
 // It returns the next move for the given position and will usually be called in a
 // loop during search. As we hope for an early beta cut this will save time as not
@@ -215,6 +324,14 @@ func (mg *Movegen) GenerateLegalMoves(position *position.Position, mode GenMode)
 // possible scenarios is more expensive than to just generate the move and dismiss is later.
 // Because of beta cuts off we quite often will never have to check the full legality
 // of these moves anyway.
+//
+// The generation runs in stages (pv move, captures, quiet moves, losing captures).
+// With GenNonQuiet only the capture stages run. When all captures have been
+// returned (MoveNone) a caller can decide to continue with the quiet moves of
+// the same position by calling GetNextMove with GenQuiet or GenAll. Moves which
+// have already been returned will not be returned again. This allows e.g. a
+// captures only probe before searching all moves without generating the
+// captures twice.
 func (mg *Movegen) GetNextMove(p *position.Position, mode GenMode, evasion bool) Move {
 
 	// if the position changes during iteration the iteration
@@ -222,9 +339,13 @@ func (mg *Movegen) GetNextMove(p *position.Position, mode GenMode, evasion bool)
 	// new position.
 	if p.ZobristKey() != mg.currentODZobrist {
 		mg.onDemandMoves.Clear()
+		mg.losingCaptures.Clear()
 		mg.onDemandEvasionTargets = BbZero
 		mg.currentODStage = odNew
+		mg.quietSkipped = false
 		mg.pvMovePushed = false
+		mg.pvOnly = false
+		mg.pvReturned = false
 		mg.takeIndex = 0
 		mg.currentODZobrist = p.ZobristKey()
 	}
@@ -259,9 +380,9 @@ func (mg *Movegen) GetNextMove(p *position.Position, mode GenMode, evasion bool)
 		// Handle PvMove
 		// if we pushed a pv move and the list is not empty we
 		// check if the pv is the next move in list and skip it.
-		if mg.currentODStage != od1 &&
+		if !mg.pvOnly &&
 			mg.pvMovePushed &&
-			(*mg.onDemandMoves)[mg.takeIndex].MoveOf() == mg.pvMove.MoveOf() {
+			(*mg.onDemandMoves)[mg.takeIndex].Equals(mg.pvMove) {
 
 			// skip pv move
 			mg.takeIndex++
@@ -291,6 +412,10 @@ func (mg *Movegen) GetNextMove(p *position.Position, mode GenMode, evasion bool)
 		// and return the move
 		// (remove internal sort value)
 		move := (*mg.onDemandMoves)[mg.takeIndex].MoveOf()
+		mg.pvOnly = false
+		if move == mg.pvMove {
+			mg.pvReturned = true
+		}
 		mg.takeIndex++
 		if mg.takeIndex >= mg.onDemandMoves.Len() {
 			mg.takeIndex = 0
@@ -309,11 +434,15 @@ func (mg *Movegen) GetNextMove(p *position.Position, mode GenMode, evasion bool)
 // Also deletes Killer and PV moves.
 func (mg *Movegen) ResetOnDemand() {
 	mg.onDemandMoves.Clear()
+	mg.losingCaptures.Clear()
 	mg.onDemandEvasionTargets = BbZero
 	mg.currentODStage = odNew
+	mg.quietSkipped = false
 	mg.currentODZobrist = 0
 	mg.pvMove = MoveNone
 	mg.pvMovePushed = false
+	mg.pvOnly = false
+	mg.pvReturned = false
 	mg.takeIndex = 0
 }
 
@@ -384,8 +513,7 @@ func (mg *Movegen) HasLegalMove(position *position.Position) bool {
 			return true
 		}
 	}
-	// normal single pawn steps
-	tmpMoves &= ^us.PromotionRankBb()
+	// normal single pawn steps (includes promotions)
 	for tmpMoves != 0 {
 		toSquare := tmpMoves.PopLsb()
 		fromSquare := toSquare.To(us.Flip().MoveDirection())
@@ -419,7 +547,7 @@ func (mg *Movegen) HasLegalMove(position *position.Position) bool {
 		pieces := position.PiecesBb(us, pt)
 		for pieces != 0 {
 			fromSquare := pieces.PopLsb()
-			moves := GetAttacksBb(pt, fromSquare, occupiedBb)
+			moves := GetAttacksBb(pt, fromSquare, occupiedBb) &^ usBb
 			for moves != 0 {
 				toSquare := moves.PopLsb()
 				if position.IsLegalMove(CreateMove(fromSquare, toSquare, Normal, PtNone)) {
@@ -461,6 +589,9 @@ var regexUciMove = regexp.MustCompile("([a-h][1-8][a-h][1-8])([NBRQnbrq])?")
 // move string against them. If there is a match the actual move is returned.
 // Otherwise MoveNone is returned.
 //
+// Castling moves are also recognized in the king-captures-rook
+// notation (e.g. "e1h1" or "e1a1") as some GUIs send them this way.
+//
 // As this uses string creation and comparison this is not very efficient.
 // Use only when performance is not critical.
 func (mg *Movegen) GetMoveFromUci(posPtr *position.Position, uciMove string) Move {
@@ -485,11 +616,25 @@ func (mg *Movegen) GetMoveFromUci(posPtr *position.Position, uciMove string) Mov
 			// move found
 			return m
 		}
+		if m.MoveType() == Castling && castlingRookNotation(m) == movePart {
+			// castling given as king captures rook
+			return m
+		}
 	}
 	// move not found
 	return MoveNone
 }
 
+// castlingRookNotation returns the UCI string of a castling move
+// in the king-captures-rook notation (e.g. "e1h1" instead of "e1g1").
+func castlingRookNotation(m Move) string {
+	rookFile := FileH
+	if m.To().FileOf() == FileC {
+		rookFile = FileA
+	}
+	return m.From().String() + SquareOf(rookFile, m.To().RankOf()).String()
+}
+
 var regexSanMove = regexp.MustCompile("([NBRQK])?([a-h])?([1-8])?x?([a-h][1-8]|O-O-O|O-O)(=?([NBRQ]))?([!?+#]*)?")
 
 // GetMoveFromSan Generates all legal moves and matches the given SAN
@@ -589,6 +734,76 @@ func (mg *Movegen) GetMoveFromSan(posPtr *position.Position, sanMove string) Mov
 	return MoveNone
 }
 
+// GetSanFromMove returns the SAN notation of the given legal move on the
+// position including check and checkmate signs. It is the counterpart of
+// GetMoveFromSan.
+//
+// As this generates legal moves and makes the move on the position this
+// is not very efficient. Use only when performance is not critical.
+func (mg *Movegen) GetSanFromMove(p *position.Position, move Move) string {
+	var san strings.Builder
+	from := move.From()
+	to := move.To()
+	pieceType := p.GetPiece(from).TypeOf()
+
+	switch {
+	case move.MoveType() == Castling:
+		if to.FileOf() > from.FileOf() {
+			san.WriteString("O-O")
+		} else {
+			san.WriteString("O-O-O")
+		}
+	case pieceType == Pawn:
+		if p.IsCapturingMove(move) {
+			san.WriteString(from.FileOf().String())
+			san.WriteString("x")
+		}
+		san.WriteString(to.String())
+		if move.MoveType() == Promotion {
+			san.WriteString("=")
+			san.WriteString(move.PromotionType().Char())
+		}
+	default:
+		san.WriteString(pieceType.Char())
+		// disambiguation when other pieces of the same type can move to
+		// the same square
+		ambiguous, sameFile, sameRank := false, false, false
+		for _, m := range *mg.GenerateLegalMoves(p, GenAll) {
+			if m.To() != to || m.From() == from || p.GetPiece(m.From()).TypeOf() != pieceType {
+				continue
+			}
+			ambiguous = true
+			sameFile = sameFile || m.From().FileOf() == from.FileOf()
+			sameRank = sameRank || m.From().RankOf() == from.RankOf()
+		}
+		if ambiguous {
+			if !sameFile {
+				san.WriteString(from.FileOf().String())
+			} else if !sameRank {
+				san.WriteString(from.RankOf().String())
+			} else {
+				san.WriteString(from.String())
+			}
+		}
+		if p.IsCapturingMove(move) {
+			san.WriteString("x")
+		}
+		san.WriteString(to.String())
+	}
+
+	// check and checkmate
+	p.DoMove(move)
+	if p.HasCheck() {
+		if mg.HasLegalMove(p) {
+			san.WriteString("+")
+		} else {
+			san.WriteString("#")
+		}
+	}
+	p.UndoMove()
+	return san.String()
+}
+
 // ValidateMove validates if a move is a valid legal move on the given position
 func (mg *Movegen) ValidateMove(p *position.Position, move Move) bool {
 	if move == MoveNone {
@@ -596,7 +811,7 @@ func (mg *Movegen) ValidateMove(p *position.Position, move Move) bool {
 	}
 	ml := mg.GenerateLegalMoves(p, GenAll)
 	for _, m := range *ml {
-		if move.MoveOf() == m {
+		if move.Equals(m) {
 			return true
 		}
 	}
@@ -625,22 +840,30 @@ func (mg *Movegen) String() string {
 
 // States for the on demand move generator
 const (
-	odNew = iota
-	odPv  = iota
-	od1   = iota
-	od2   = iota
-	od3   = iota
-	od4   = iota
-	od5   = iota
-	od6   = iota
-	od7   = iota
-	od8   = iota
-	odEnd = iota
+	odNew   = iota
+	odPv    = iota
+	od1     = iota
+	od2     = iota
+	od3     = iota
+	od4     = iota
+	od5     = iota
+	od6     = iota
+	od7     = iota
+	od8     = iota
+	od9     = iota
+	odQuiet = iota
+	odEnd   = iota
 )
 
 // This calls the actual generation of moves in phases. The phases match roughly
 // the order of most promising moves first.
 func (mg *Movegen) fillOnDemandMoveList(p *position.Position, mode GenMode, evasion bool) {
+	// continue with the quiet moves when they have been skipped by
+	// an earlier generation with GenNonQuiet and are now requested
+	if mg.currentODStage == odEnd && mg.quietSkipped && mode&GenQuiet != 0 {
+		mg.quietSkipped = false
+		mg.currentODStage = odQuiet
+	}
 	for mg.onDemandMoves.Len() == 0 && mg.currentODStage < odEnd {
 		switch mg.currentODStage {
 		case odNew:
@@ -665,6 +888,7 @@ func (mg *Movegen) fillOnDemandMoveList(p *position.Position, mode GenMode, evas
 						mg.onDemandMoves.PushBack(mg.pvMove)
 					}
 				}
+				mg.pvOnly = mg.pvMovePushed
 			}
 			// decide which state we should continue with
 			// captures or non captures or both
@@ -676,10 +900,12 @@ func (mg *Movegen) fillOnDemandMoveList(p *position.Position, mode GenMode, evas
 		case od1: // capture
 			mg.generatePawnMoves(p, GenNonQuiet, evasion, mg.onDemandEvasionTargets, mg.onDemandMoves)
 			mg.updateSortValues(p, mg.onDemandMoves)
+			mg.deferLosingCaptures(p)
 			mg.currentODStage = od2
 		case od2:
 			mg.generateMoves(p, GenNonQuiet, evasion, mg.onDemandEvasionTargets, mg.onDemandMoves)
 			mg.updateSortValues(p, mg.onDemandMoves)
+			mg.deferLosingCaptures(p)
 			mg.currentODStage = od3
 		case od3:
 			mg.generateKingMoves(p, GenNonQuiet, evasion, mg.onDemandEvasionTargets, mg.onDemandMoves)
@@ -688,7 +914,8 @@ func (mg *Movegen) fillOnDemandMoveList(p *position.Position, mode GenMode, evas
 			if mode&GenQuiet != 0 {
 				mg.currentODStage = od5
 			} else {
-				mg.currentODStage = odEnd
+				mg.quietSkipped = true
+				mg.currentODStage = od9
 			}
 		case od5: // non capture
 			mg.generatePawnMoves(p, GenQuiet, evasion, mg.onDemandEvasionTargets, mg.onDemandMoves)
@@ -707,7 +934,19 @@ func (mg *Movegen) fillOnDemandMoveList(p *position.Position, mode GenMode, evas
 		case od8:
 			mg.generateKingMoves(p, GenQuiet, evasion, mg.onDemandEvasionTargets, mg.onDemandMoves)
 			mg.updateSortValues(p, mg.onDemandMoves)
+			mg.currentODStage = od9
+		case od9: // losing captures
+			*mg.onDemandMoves = append(*mg.onDemandMoves, *mg.losingCaptures...)
+			mg.losingCaptures.Clear()
 			mg.currentODStage = odEnd
+		case odQuiet: // skipped quiet moves
+			// a quiet pv move has not been returned with the captures
+			if mg.pvMove != MoveNone && !mg.pvReturned {
+				mg.pvMovePushed = true
+				mg.pvOnly = true
+				mg.onDemandMoves.PushBack(mg.pvMove)
+			}
+			mg.currentODStage = od5
 		case odEnd:
 			break
 		}
@@ -724,11 +963,11 @@ func (mg *Movegen) updateSortValues(p *position.Position, moveList *moveslice.Mo
 	for i := 0; i < len(*moveList); i++ {
 		move := &(*moveList)[i]
 		switch {
-		case move.MoveOf() == mg.pvMove: // PV move
+		case move.Equals(mg.pvMove): // PV move
 			(*move).SetValue(ValueMax)
-		case move.MoveOf() == mg.killerMoves[1]: // Killer 2
+		case move.Equals(mg.killerMoves[1]): // Killer 2
 			(*move).SetValue(-4001)
-		case move.MoveOf() == mg.killerMoves[0]: // Killer 1
+		case move.Equals(mg.killerMoves[0]): // Killer 1
 			(*move).SetValue(-4000)
 		case mg.historyData != nil: // historical search data
 
@@ -754,6 +993,14 @@ func (mg *Movegen) updateSortValues(p *position.Position, moveList *moveslice.Mo
 				value += 500
 			}
 
+			// Follow-Up Move History
+			// When we have a follow-up move to our own previous move which
+			// caused a beta cut off before we bump up its sort value
+			if ownMove := p.PreviousMove(2); ownMove != MoveNone &&
+				mg.historyData.FollowUpMoves[ownMove.From()][ownMove.To()] == move.MoveOf() {
+				value += 250
+			}
+
 			// update move sort value
 			if value > 0 { // only touch the value if it would be improved
 				preValue := move.ValueOf()
@@ -764,6 +1011,23 @@ func (mg *Movegen) updateSortValues(p *position.Position, moveList *moveslice.Mo
 	}
 }
 
+// deferLosingCaptures moves captures with a negative static exchange
+// evaluation (SEE) from the on demand move list to the list of losing
+// captures. These will be returned after all other moves (od9).
+func (mg *Movegen) deferLosingCaptures(p *position.Position) {
+	if !config.Settings.Search.UseSEEOrdering {
+		return
+	}
+	mg.onDemandMoves.Filter(func(i int) bool {
+		move := mg.onDemandMoves.At(i)
+		if p.IsCapturingMove(move) && attacks.See(p, move) < 0 {
+			mg.losingCaptures.PushBack(move)
+			return false
+		}
+		return true
+	})
+}
+
 // getEvasionTargets returns the number of attackers and a Bitboard with target
 // squares for generated moves when the position has check against the next
 // player. Most of the moves will not even be generated as they will not
@@ -953,20 +1217,27 @@ func (mg *Movegen) generateCastling(position *position.Position, mode GenMode, m
 	// castling - pseudo castling - we will not check if we are in check after the move
 	// or if we have passed an attacked square with the king or if the king has been in check
 
+	// Only castling with the king on the e file and the rooks on the h and
+	// a file is generated. Rights with other rook files (Chess960) are
+	// kept in the position but castling with them is not supported.
 	if mode&GenQuiet != 0 && position.CastlingRights() != CastlingNone {
 		cr := position.CastlingRights()
-		if nextPlayer == White { // white
-			if cr.Has(CastlingWhiteOO) && Intermediate(SqE1, SqH1)&occupiedBB == 0 {
+		if nextPlayer == White && position.KingSquare(White) == SqE1 { // white
+			if cr.Has(CastlingWhiteOO) && position.CastlingRookFile(CastlingWhiteOO) == FileH &&
+				Intermediate(SqE1, SqH1)&occupiedBB == 0 {
 				ml.PushBack(CreateMoveValue(SqE1, SqG1, Castling, PtNone, Value(-5000)))
 			}
-			if cr.Has(CastlingWhiteOOO) && Intermediate(SqE1, SqA1)&occupiedBB == 0 {
+			if cr.Has(CastlingWhiteOOO) && position.CastlingRookFile(CastlingWhiteOOO) == FileA &&
+				Intermediate(SqE1, SqA1)&occupiedBB == 0 {
 				ml.PushBack(CreateMoveValue(SqE1, SqC1, Castling, PtNone, Value(-5000)))
 			}
-		} else { // black
-			if cr.Has(CastlingBlackOO) && Intermediate(SqE8, SqH8)&occupiedBB == 0 {
+		} else if nextPlayer == Black && position.KingSquare(Black) == SqE8 { // black
+			if cr.Has(CastlingBlackOO) && position.CastlingRookFile(CastlingBlackOO) == FileH &&
+				Intermediate(SqE8, SqH8)&occupiedBB == 0 {
 				ml.PushBack(CreateMoveValue(SqE8, SqG8, Castling, PtNone, Value(-5000)))
 			}
-			if cr.Has(CastlingBlackOOO) && Intermediate(SqE8, SqA8)&occupiedBB == 0 {
+			if cr.Has(CastlingBlackOOO) && position.CastlingRookFile(CastlingBlackOOO) == FileA &&
+				Intermediate(SqE8, SqA8)&occupiedBB == 0 {
 				ml.PushBack(CreateMoveValue(SqE8, SqC8, Castling, PtNone, Value(-5000)))
 			}
 		}
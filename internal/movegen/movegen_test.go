@@ -27,9 +27,13 @@
 package movegen
 
 import (
+	"flag"
+	"io/ioutil"
+	"math/rand"
 	"os"
 	"path"
 	"runtime"
+	"strings"
 	"testing"
 	"time"
 
@@ -37,6 +41,7 @@ import (
 	"github.com/stretchr/testify/assert"
 
 	"github.com/frankkopp/FrankyGo/internal/config"
+	"github.com/frankkopp/FrankyGo/internal/history"
 	myLogging "github.com/frankkopp/FrankyGo/internal/logging"
 	"github.com/frankkopp/FrankyGo/internal/moveslice"
 	"github.com/frankkopp/FrankyGo/internal/position"
@@ -45,6 +50,8 @@ import (
 
 var logTest *logging.Logger
 
+var update = flag.Bool("update", false, "update the move ordering snapshot")
+
 // make tests run in the projects root directory.
 func init() {
 	_, filename, _, _ := runtime.Caller(0)
@@ -123,7 +130,15 @@ func TestMovegenGenerateCastling(t *testing.T) {
 	mg.generateCastling(pos, GenAll, &moves)
 	assert.Equal(t, 2, moves.Len())
 	assert.Equal(t, "e8g8 e8c8", moves.StringUci())
+	moves.Clear()
 
+	// no castling with other king or rook files (Chess960)
+	pos, _ = position.NewPositionFen("1k6/8/8/8/8/8/8/RK5R w HA -")
+	mg.generateCastling(pos, GenAll, &moves)
+	assert.Equal(t, 0, moves.Len())
+	pos, _ = position.NewPositionFen("4k3/8/8/8/8/8/8/1R2K1R1 w GB -")
+	mg.generateCastling(pos, GenAll, &moves)
+	assert.Equal(t, 0, moves.Len())
 }
 
 func TestMovegenGenerateKingMoves(t *testing.T) {
@@ -166,6 +181,40 @@ func TestMovegenGenerateMoves(t *testing.T) {
 		"b4a3 b4a5 b4c5 b4d6 b7a6 b7c8 a8b8 a8c8 a8d8 h8f8 h8g8 e7d7 e7c5 e7d6 e7e6 e7d8 e7f8", moves.StringUci())
 }
 
+func TestOnDemandSEEOrdering(t *testing.T) {
+	defer func() { config.Settings.Search.UseSEEOrdering = false }()
+	config.Settings.Search.UsePromNonQuiet = false
+	config.Settings.Search.UseSEEOrdering = true
+	mg := NewMoveGen()
+
+	// Qxd5 loses the queen for a pawn and is ordered after all quiet moves
+	// while the winning capture Nxe5 stays in front
+	pos, _ := position.NewPositionFen("4k3/8/2p5/3pp3/8/5N2/3Q4/4K3 w - -")
+	var moves = moveslice.NewMoveSlice(100)
+	for move := mg.GetNextMove(pos, GenAll, false); move != MoveNone; move = mg.GetNextMove(pos, GenAll, false) {
+		moves.PushBack(move)
+	}
+	assert.Equal(t, len(*mg.GeneratePseudoLegalMoves(pos, GenAll, false)), moves.Len())
+	assert.Equal(t, "f3e5", moves.Front().StringUci())
+	assert.Equal(t, "d2d5", moves.Back().StringUci())
+
+	// in quiescence mode losing captures are returned last
+	moves.Clear()
+	mg.ResetOnDemand()
+	for move := mg.GetNextMove(pos, GenNonQuiet, false); move != MoveNone; move = mg.GetNextMove(pos, GenNonQuiet, false) {
+		moves.PushBack(move)
+	}
+	assert.Equal(t, "f3e5 d2d5", moves.StringUci())
+
+	// the on demand generation still generates all moves
+	pos, _ = position.NewPositionFen("r3k2r/pbpNqppp/1pn2n2/1B2p3/1b2P3/2PP1N2/PP1nQPPP/R3K2R w KQkq -")
+	moves.Clear()
+	for move := mg.GetNextMove(pos, GenAll, false); move != MoveNone; move = mg.GetNextMove(pos, GenAll, false) {
+		moves.PushBack(move)
+	}
+	assert.Equal(t, 40, moves.Len())
+}
+
 func TestOnDemand(t *testing.T) {
 	config.Settings.Search.UsePromNonQuiet = false
 
@@ -308,6 +357,37 @@ func TestMovegenGeneratePseudoLegalMoves(t *testing.T) {
 	moves.Clear()
 }
 
+func TestMvvLvaOrdering(t *testing.T) {
+	defer func(promNonQuiet bool) {
+		config.Settings.Search.UsePromNonQuiet = promNonQuiet
+	}(config.Settings.Search.UsePromNonQuiet)
+	config.Settings.Search.UsePromNonQuiet = true
+	mg := NewMoveGen()
+
+	// the black rook on d5 can be captured by pawn, rook and queen and
+	// the pawn on b7 can promote with or without capturing the rook on a8
+	pos, _ := position.NewPositionFen("r6k/1P6/P7/Q2r4/2P5/8/8/3R2K1 w - -")
+
+	// the internal sort values are in descending order
+	ml := moveslice.NewMoveSlice(64)
+	mg.generatePawnMoves(pos, GenNonQuiet, false, BbZero, ml)
+	mg.generateKingMoves(pos, GenNonQuiet, false, BbZero, ml)
+	mg.generateMoves(pos, GenNonQuiet, false, BbZero, ml)
+	ml.Sort()
+	for i := 1; i < ml.Len(); i++ {
+		assert.GreaterOrEqual(t, int(ml.At(i-1).ValueOf()), int(ml.At(i).ValueOf()))
+	}
+
+	// most valuable victim first, least valuable attacker first and
+	// promotions by the value of the promoted piece (rook and bishop last)
+	moves := mg.GeneratePseudoLegalMoves(pos, GenNonQuiet, false)
+	assert.Equal(t, "b7a8Q b7b8Q b7a8N c4d5 b7b8N d1d5 a5d5 b7a8R b7a8B", moves.StringUci())
+	assert.Equal(t, ml.Len(), moves.Len())
+	for i := 0; i < ml.Len(); i++ {
+		assert.Equal(t, ml.At(i).MoveOf(), moves.At(i))
+	}
+}
+
 func TestMovegenGenerateLegalMoves(t *testing.T) {
 	config.Settings.Search.UsePromNonQuiet = false
 
@@ -366,6 +446,182 @@ func TestHasLegalMoves(t *testing.T) {
 	assert.False(t, pos.HasCheck())
 }
 
+// En passant captures remove two pawns from the same rank and can expose
+// the own king to a rook or queen on this rank although neither pawn is
+// pinned on its own.
+func TestEnPassantDiscoveredCheck(t *testing.T) {
+	mg := NewMoveGen()
+	tests := []struct {
+		fen   string
+		move  Move
+		legal bool
+	}{
+		{"8/8/8/K1Pp3r/8/8/8/7k w - d6", CreateMove(SqC5, SqD6, EnPassant, PtNone), false},
+		{"8/8/8/K1Pp3q/8/8/8/7k w - d6", CreateMove(SqC5, SqD6, EnPassant, PtNone), false},
+		{"8/8/8/K2pP2r/8/8/8/7k w - d6", CreateMove(SqE5, SqD6, EnPassant, PtNone), false},
+		{"7K/8/8/8/k2pP2R/8/8/8 b - e3", CreateMove(SqD4, SqE3, EnPassant, PtNone), false},
+		{"8/8/8/K1Pp4/8/8/8/7k w - d6", CreateMove(SqC5, SqD6, EnPassant, PtNone), true},
+		{"8/8/8/K1Ppn2r/8/8/8/7k w - d6", CreateMove(SqC5, SqD6, EnPassant, PtNone), true},
+	}
+	for _, test := range tests {
+		pos, _ := position.NewPositionFen(test.fen)
+		assert.Contains(t, *mg.GeneratePseudoLegalMoves(pos, GenAll, false), test.move, test.fen)
+		assert.EqualValues(t, test.legal, pos.IsLegalMove(test.move), test.fen)
+		assert.EqualValues(t, test.legal, mg.ValidateMove(pos, test.move), test.fen)
+		if test.legal {
+			assert.Contains(t, *mg.GenerateLegalMoves(pos, GenAll), test.move, test.fen)
+		} else {
+			assert.NotContains(t, *mg.GenerateLegalMoves(pos, GenAll), test.move, test.fen)
+		}
+		pos.DoMove(test.move)
+		assert.EqualValues(t, test.legal, pos.WasLegalMove(), test.fen)
+		pos.UndoMove()
+		assert.EqualValues(t, test.fen+" 0 1", pos.StringFen(), test.fen)
+	}
+}
+
+// HasLegalMove has its own implementation and must always agree
+// with GenerateLegalMoves.
+func TestHasLegalMoveEqualsGenerateLegalMoves(t *testing.T) {
+	mg := NewMoveGen()
+	fens := []string{
+		position.StartFen,
+		"r3k2r/p1ppqpb1/bn2pnp1/3PN3/1p2P3/2N2Q1p/PPPBBPPP/R3K2R w KQkq -",
+		"8/2p5/3p4/KP5r/1R3p1k/8/4P1P1/8 w - -",
+		"r3k2r/Pppp1ppp/1b3nbN/nP6/BBP1P3/q4N2/Pp1P2PP/R2Q1RK1 w kq - 0 1",
+		"rnbq1k1r/pp1Pbppp/2p5/8/2B5/8/PPP1NnPP/RNBQK2R w KQ - 1 8",
+		// check mate
+		"rn2kbnr/pbpp1ppp/8/1p2p1q1/4K3/3P4/PPP1PPPP/RNBQ1BNR w kq -",
+		"6k1/5ppp/8/8/8/8/8/K2r4 w - -",
+		// stale mate
+		"7k/5K2/6Q1/8/8/8/8/8 b - -",
+		"k7/8/8/8/8/1p6/1P3q2/B6K w - -",
+		// only en passant
+		"8/8/8/8/5Pp1/6P1/7k/K3BQ2 b - f3",
+		// only promotion
+		"8/4P3/8/8/8/1q6/8/K6k w - -",
+	}
+	for _, fen := range fens {
+		pos, _ := position.NewPositionFen(fen)
+		hasMoves := len(*mg.GenerateLegalMoves(pos, GenAll)) > 0
+		assert.Equal(t, hasMoves, mg.HasLegalMove(pos), fen)
+	}
+
+	// random play outs from the positions above
+	rnd := rand.New(rand.NewSource(4711))
+	for _, fen := range fens {
+		for game := 0; game < 20; game++ {
+			pos, _ := position.NewPositionFen(fen)
+			for ply := 0; ply < 200; ply++ {
+				moves := mg.GenerateLegalMoves(pos, GenAll)
+				if !assert.Equal(t, len(*moves) > 0, mg.HasLegalMove(pos), pos.StringFen()) || len(*moves) == 0 {
+					break
+				}
+				pos.DoMove(moves.At(rnd.Intn(moves.Len())))
+			}
+		}
+	}
+}
+
+func TestGenerateEvasionsEqualsLegalMoves(t *testing.T) {
+	mg := NewMoveGen()
+	fens := []string{
+		position.StartFen,
+		"r3k2r/p1ppqpb1/bn2pnp1/3PN3/1p2P3/2N2Q1p/PPPBBPPP/R3K2R w KQkq -",
+		"8/2p5/3p4/KP5r/1R3p1k/8/4P1P1/8 w - -",
+		"r3k2r/Pppp1ppp/1b3nbN/nP6/BBP1P3/q4N2/Pp1P2PP/R2Q1RK1 w kq - 0 1",
+		"r3k2r/1pp4p/2q1qNn1/3nP3/2q1Pp2/B5R1/pbp2PPP/1R4K1 b kq -",
+		"5k2/3N4/8/8/8/8/6p1/3K1R2 b - - 1 1 ",
+		// en passant capture of the checking pawn
+		"8/8/8/3k4/4Pp2/8/8/3K4 b - e3",
+		// double check
+		"4k3/8/8/8/8/5n2/8/r3K3 w - -",
+	}
+
+	// evasions must contain the same moves as the legal moves when in check
+	compare := func(pos *position.Position) bool {
+		legal := mg.GenerateLegalMoves(pos, GenAll).Clone()
+		evasions := mg.GenerateEvasions(pos).Clone()
+		if !pos.HasCheck() {
+			return assert.Equal(t, 0, evasions.Len(), pos.StringFen())
+		}
+		return assert.ElementsMatch(t, *legal, *evasions, pos.StringFen())
+	}
+
+	checks := 0
+	rnd := rand.New(rand.NewSource(4711))
+	for _, fen := range fens {
+		pos, _ := position.NewPositionFen(fen)
+		compare(pos)
+		// random play outs
+		for game := 0; game < 50; game++ {
+			pos, _ = position.NewPositionFen(fen)
+			for ply := 0; ply < 200; ply++ {
+				if pos.HasCheck() {
+					checks++
+				}
+				if !compare(pos) {
+					break
+				}
+				moves := mg.GenerateLegalMoves(pos, GenAll)
+				if moves.Len() == 0 {
+					break
+				}
+				pos.DoMove(moves.At(rnd.Intn(moves.Len())))
+			}
+		}
+	}
+	assert.True(t, checks > 0)
+	out.Printf("Positions in check compared: %d\n", checks)
+}
+
+// TestZobristCollisions plays random games from several seeds and stores
+// the fen without move counters for every zobrist key seen. Two different
+// fens sharing a key are a real collision while the same fen reached with
+// different keys means the hashing of e.g. en passant or castling rights
+// is not consistent.
+func TestZobristCollisions(t *testing.T) {
+	if testing.Short() {
+		t.Skip("skipping test in short mode.")
+	}
+	mg := NewMoveGen()
+	keyToFen := make(map[position.Key]string)
+	fenToKey := make(map[string]position.Key)
+	positions, transpositions, collisions, inconsistencies := 0, 0, 0, 0
+	for seed := int64(1); seed <= 10; seed++ {
+		rnd := rand.New(rand.NewSource(seed))
+		for game := 0; game < 1_000; game++ {
+			pos := position.NewPosition()
+			for ply := 0; ply < 200; ply++ {
+				fen := strings.Join(strings.Fields(pos.StringFen())[:4], " ")
+				key := pos.ZobristKey()
+				positions++
+				if knownFen, found := keyToFen[key]; !found {
+					keyToFen[key] = fen
+				} else if knownFen == fen {
+					transpositions++
+				} else {
+					collisions++
+					t.Errorf("zobrist collision: %s and %s share key %d", knownFen, fen, key)
+				}
+				if knownKey, found := fenToKey[fen]; !found {
+					fenToKey[fen] = key
+				} else if knownKey != key {
+					inconsistencies++
+					t.Errorf("same position with different keys: %s: %d and %d", fen, knownKey, key)
+				}
+				moves := mg.GenerateLegalMoves(pos, GenAll)
+				if moves.Len() == 0 || pos.HalfMoveClock() >= 100 {
+					break
+				}
+				pos.DoMove(moves.At(rnd.Intn(moves.Len())))
+			}
+		}
+	}
+	out.Printf("Positions: %d unique: %d transpositions: %d collisions: %d (rate %.8f) inconsistencies: %d\n",
+		positions, len(keyToFen), transpositions, collisions, float64(collisions)/float64(positions), inconsistencies)
+}
+
 func TestMovegenGetMoveFromUci(t *testing.T) {
 
 	pos, _ := position.NewPositionFen("r3k2r/1ppn3p/2q1q1n1/4P3/2q1Pp2/B5R1/pbp2PPP/1R4K1 b kq e3")
@@ -398,6 +654,19 @@ func TestMovegenGetMoveFromUci(t *testing.T) {
 	// invalid castling
 	move = mg.GetMoveFromUci(pos, "e8g8")
 	assert.Equal(t, MoveNone, move)
+
+	// castling as king captures rook
+	move = mg.GetMoveFromUci(pos, "e8a8")
+	assert.Equal(t, CreateMove(SqE8, SqC8, Castling, PtNone), move)
+	move = mg.GetMoveFromUci(pos, "e8h8")
+	assert.Equal(t, MoveNone, move)
+
+	// both notations on both sides
+	pos, _ = position.NewPositionFen("r3k2r/8/8/8/8/8/8/R3K2R w KQkq -")
+	assert.Equal(t, CreateMove(SqE1, SqG1, Castling, PtNone), mg.GetMoveFromUci(pos, "e1g1"))
+	assert.Equal(t, CreateMove(SqE1, SqG1, Castling, PtNone), mg.GetMoveFromUci(pos, "e1h1"))
+	assert.Equal(t, CreateMove(SqE1, SqC1, Castling, PtNone), mg.GetMoveFromUci(pos, "e1c1"))
+	assert.Equal(t, CreateMove(SqE1, SqC1, Castling, PtNone), mg.GetMoveFromUci(pos, "e1a1"))
 }
 
 func TestMovegenGetMoveFromSan(t *testing.T) {
@@ -450,6 +719,28 @@ func TestMovegenGetMoveFromSan(t *testing.T) {
 	assert.Equal(t, CreateMove(SqC2, SqB1, Promotion, Queen), move)
 }
 
+func TestMovegenGetSanFromMove(t *testing.T) {
+	mg := NewMoveGen()
+	p := position.NewPosition("r3k2r/1ppn3p/2q1q1n1/4P3/2q1Pp2/B5R1/pbp2PPP/1R4K1 b kq e3")
+	tests := []struct {
+		uci string
+		san string
+	}{
+		{"e8c8", "O-O-O"},
+		{"f4e3", "fxe3"},
+		{"a2b1q", "axb1=Q#"},
+		{"c2c1q", "c1=Q+"},
+		{"d7e5", "Ndxe5"},
+		{"g6e5", "Ngxe5"},
+		{"c6d5", "Qc6d5"},
+		{"b2a3", "Bxa3"},
+	}
+	for _, test := range tests {
+		move := mg.GetMoveFromUci(p, test.uci)
+		assert.Equal(t, test.san, mg.GetSanFromMove(p, move), test.uci)
+	}
+}
+
 func TestOnDemandKillerPv(t *testing.T) {
 	config.Settings.Search.UsePromNonQuiet = false
 
@@ -465,7 +756,7 @@ func TestOnDemandKillerPv(t *testing.T) {
 		moves.PushBack(move)
 	}
 	assert.Equal(t, 86, moves.Len())
-	assert.Equal(t, "a2b1Q c2b1Q c2b1N a2b1N f4g3 f4e3 c2b1R a2b1R c2b1B a2b1B b2a3 a8a3 g6e5 d7e5 b2e5 e6e5 c4e4 c6e4 b7b6 a2a1Q c2c1Q a2a1N c2c1N f4f3 h7h6 b7b5 h7h5 a2a1R c2c1R a2a1B c2c1B e8g8 e8c8 g6h4 d7c5 a8c8 a8d8 h8f8 d7f6 b2d4 b2c3 c4c5 c4d5 c6c5 c6d5 c6d6 e6d5 e6f5 e6d6 e6f6 g6e7 d7b6 e6e7 e6f7 c4d4 a8a4 a8a5 a8a6 a8a7 c4e2 c4b3 c4c3 c4d3 c4b4 c4b5 c6b5 c6b6 e6g4 c4a4 c6a4 a8b8 h8g8 b2c1 c4f1 c4a6 c6a6 e6h3 e6g8 g6f8 d7f8 b2a1 d7b8 e8f8 e8e7 e8f7 e8d8", moves.StringUci())
+	assert.Equal(t, "a2b1Q c2b1Q c2b1N a2b1N f4g3 f4e3 c2b1R a2b1R c2b1B a2b1B b2a3 a8a3 g6e5 d7e5 b2e5 e6e5 c4e4 c6e4 b7b6 a2a1Q c2c1Q a2a1N c2c1N f4f3 h7h6 b7b5 h7h5 a2a1R c2c1R a2a1B c2c1B e8g8 e8c8 g6h4 d7c5 a8c8 a8d8 h8f8 d7f6 b2d4 g6e7 d7b6 b2c3 c4c5 c4d5 c6c5 c6d5 c6d6 e6d5 e6f5 e6d6 e6f6 e6e7 e6f7 c4d4 a8a4 a8a5 a8a6 a8a7 c4e2 c4b3 c4c3 c4d3 c4b4 c4b5 c6b5 c6b6 e6g4 c4a4 c6a4 b2c1 a8b8 h8g8 c4f1 c4a6 c6a6 e6h3 e6g8 g6f8 d7f8 b2a1 d7b8 e8f8 e8e7 e8f7 e8d8", moves.StringUci())
 	moves.Clear()
 
 	// 48 kiwipete
@@ -484,6 +775,149 @@ func TestOnDemandKillerPv(t *testing.T) {
 
 }
 
+// TestOnDemandOrderingSnapshot compares the order of the moves returned by
+// the on demand move generator with PV move, killer moves and history
+// data set to fixed values against a stored snapshot. This guards the
+// staged generation and the sort values against unintended changes.
+// Run with -update to write the current orders to the snapshot file.
+func TestOnDemandOrderingSnapshot(t *testing.T) {
+	defer func(seeOrdering bool, promNonQuiet bool) {
+		config.Settings.Search.UseSEEOrdering = seeOrdering
+		config.Settings.Search.UsePromNonQuiet = promNonQuiet
+	}(config.Settings.Search.UseSEEOrdering, config.Settings.Search.UsePromNonQuiet)
+	config.Settings.Search.UseSEEOrdering = false
+	config.Settings.Search.UsePromNonQuiet = true
+
+	// fixed history counts for all moves
+	hist := history.NewHistory()
+	for c := White; c <= Black; c++ {
+		for from := SqA1; from < SqNone; from++ {
+			for to := SqA1; to < SqNone; to++ {
+				hist.HistoryCount[c][from][to] = int64((int(from)*7+int(to)*13)%10) * 1_000
+			}
+		}
+	}
+
+	file := "test/testdata/moveordering.txt"
+	data, err := ioutil.ReadFile(file)
+	assert.NoError(t, err)
+	lines := strings.Split(strings.TrimRight(string(data), "\n"), "\n")
+
+	for i, line := range lines {
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+		fields := strings.Split(line, ";")
+		if !assert.Len(t, fields, 4, line) {
+			continue
+		}
+		pos, err := position.NewPositionFen(fields[0])
+		if !assert.NoError(t, err, line) {
+			continue
+		}
+
+		mg := NewMoveGen()
+		mg.SetHistoryData(hist)
+		if fields[1] != "" {
+			pv := mg.GetMoveFromUci(pos, fields[1])
+			assert.NotEqual(t, MoveNone, pv, line)
+			mg.SetPvMove(pv)
+		}
+		for _, k := range strings.Fields(fields[2]) {
+			killer := mg.GetMoveFromUci(pos, k)
+			assert.NotEqual(t, MoveNone, killer, line)
+			mg.StoreKiller(killer)
+		}
+
+		moves := moveslice.NewMoveSlice(MaxMoves)
+		for move := mg.GetNextMove(pos, GenAll, pos.HasCheck()); move != MoveNone; move = mg.GetNextMove(pos, GenAll, pos.HasCheck()) {
+			moves.PushBack(move)
+		}
+
+		if *update {
+			fields[3] = moves.StringUci()
+			lines[i] = strings.Join(fields, ";")
+			continue
+		}
+		assert.Equal(t, fields[3], moves.StringUci(), fields[0])
+	}
+
+	if *update {
+		err := ioutil.WriteFile(file, []byte(strings.Join(lines, "\n")+"\n"), 0644)
+		assert.NoError(t, err)
+	}
+}
+
+func TestOnDemandContinueWithQuiet(t *testing.T) {
+	config.Settings.Search.UsePromNonQuiet = true
+	defer func() { config.Settings.Search.UsePromNonQuiet = false }()
+
+	tests := []struct {
+		fen   string
+		pv    string
+		first GenMode // phase in which the pv move is returned first
+	}{
+		{"r3k2r/p1ppqpb1/bn2pnp1/3PN3/1p2P3/2N2Q1p/PPPBBPPP/R3K2R w KQkq - ", "e1g1", GenQuiet},
+		{"r3k2r/p1ppqpb1/bn2pnp1/3PN3/1p2P3/2N2Q1p/PPPBBPPP/R3K2R w KQkq - ", "e2a6", GenNonQuiet},
+		{"r3k2r/1ppn3p/2q1q1n1/4P3/2q1Pp2/B5R1/pbp2PPP/1R4K1 b kq e3", "b7b6", GenQuiet},
+		{"r3k2r/1ppn3p/2q1q1n1/4P3/2q1Pp2/B5R1/pbp2PPP/1R4K1 b kq e3", "c2c1q", GenNonQuiet}, // quiet promotion
+	}
+
+	for _, test := range tests {
+		mg := NewMoveGen()
+		pos, _ := position.NewPositionFen(test.fen)
+		pv := mg.GetMoveFromUci(pos, test.pv)
+		assert.NotEqual(t, MoveNone, pv)
+		mg.SetPvMove(pv)
+		mg.StoreKiller(mg.GetMoveFromUci(pos, "d2g5"))
+		mg.StoreKiller(mg.GetMoveFromUci(pos, "b2b3"))
+
+		// captures only until exhausted
+		captures := moveslice.NewMoveSlice(100)
+		for move := mg.GetNextMove(pos, GenNonQuiet, false); move != MoveNone; move = mg.GetNextMove(pos, GenNonQuiet, false) {
+			captures.PushBack(move)
+		}
+		// continue with the quiet moves
+		quiets := moveslice.NewMoveSlice(100)
+		for move := mg.GetNextMove(pos, GenAll, false); move != MoveNone; move = mg.GetNextMove(pos, GenAll, false) {
+			quiets.PushBack(move)
+		}
+
+		if test.first == GenNonQuiet {
+			assert.Equal(t, pv, captures.At(0))
+		} else {
+			assert.Equal(t, pv, quiets.At(0))
+		}
+
+		// captures must be the same as all non quiet moves
+		expected := mg.GeneratePseudoLegalMoves(pos, GenNonQuiet, false).Clone()
+		assert.Equal(t, expected.Len(), captures.Len())
+		returned := make(map[Move]bool, captures.Len())
+		for _, m := range *captures {
+			returned[m] = true
+		}
+		for _, m := range *expected {
+			assert.True(t, returned[m.MoveOf()], m.StringUci())
+		}
+
+		// both phases together must be all moves without duplicates
+		all := append(*captures, *quiets...)
+		expected = mg.GeneratePseudoLegalMoves(pos, GenAll, false).Clone()
+		assert.Equal(t, expected.Len(), len(all))
+		seen := make(map[Move]bool, len(all))
+		for _, m := range all {
+			assert.False(t, seen[m], "duplicate %s", m.StringUci())
+			seen[m] = true
+		}
+		for _, m := range *expected {
+			assert.True(t, seen[m.MoveOf()], m.StringUci())
+		}
+
+		// after all moves have been returned there are no more moves
+		assert.Equal(t, MoveNone, mg.GetNextMove(pos, GenAll, false))
+	}
+}
+
 func TestPseudoLegalPVKiller(t *testing.T) {
 	config.Settings.Search.UsePromNonQuiet = false
 
@@ -497,7 +931,7 @@ func TestPseudoLegalPVKiller(t *testing.T) {
 	mg.StoreKiller(mg.GetMoveFromUci(pos, "b7b6"))
 	moves = mg.GeneratePseudoLegalMoves(pos, GenAll, false)
 	assert.Equal(t, 86, moves.Len())
-	assert.Equal(t, "a2b1Q c2b1Q c2b1N a2b1N f4g3 f4e3 b2a3 a8a3 g6e5 d7e5 b2e5 e6e5 c4e4 c6e4 c2b1R a2b1R c2b1B a2b1B b7b6 g6h4 e8g8 e8c8 a2a1Q c2c1Q a2a1N c2c1N d7c5 f4f3 a8c8 a8d8 h8f8 d7f6 b2d4 h7h6 b2c3 c4c5 c4d5 c6c5 c6d5 c6d6 e6d5 e6f5 e6d6 e6f6 g6e7 d7b6 e6e7 e6f7 c4d4 b7b5 h7h5 a8a4 a8a5 a8a6 a8a7 c4e2 c4b3 c4c3 c4d3 c4b4 c4b5 c6b5 c6b6 e6g4 e8f8 c4a4 c6a4 a8b8 h8g8 b2c1 c4f1 c4a6 c6a6 e6h3 e6g8 e8e7 e8f7 g6f8 d7f8 b2a1 e8d8 d7b8 a2a1R c2c1R a2a1B c2c1B", moves.StringUci())
+	assert.Equal(t, "a2b1Q c2b1Q c2b1N a2b1N f4g3 f4e3 b2a3 a8a3 g6e5 d7e5 b2e5 e6e5 c4e4 c6e4 c2b1R a2b1R c2b1B a2b1B b7b6 g6h4 e8g8 e8c8 a2a1Q c2c1Q a2a1N c2c1N d7c5 a8c8 a8d8 h8f8 d7f6 b2d4 f4f3 h7h6 g6e7 d7b6 b2c3 c4c5 c4d5 c6c5 c6d5 c6d6 e6d5 e6f5 e6d6 e6f6 e6e7 e6f7 c4d4 b7b5 h7h5 e8f8 a8a4 a8a5 a8a6 a8a7 c4e2 c4b3 c4c3 c4d3 c4b4 c4b5 c6b5 c6b6 e6g4 c4a4 c6a4 b2c1 a8b8 h8g8 c4f1 c4a6 c6a6 e6h3 e6g8 e8e7 e8f7 e8d8 g6f8 d7f8 b2a1 d7b8 a2a1R c2c1R a2a1B c2c1B", moves.StringUci())
 	moves.Clear()
 
 	// 48 kiwipete
@@ -514,6 +948,82 @@ func TestPseudoLegalPVKiller(t *testing.T) {
 
 }
 
+func TestGenerateMovesForAndFrom(t *testing.T) {
+	mg := NewMoveGen()
+	fens := []string{
+		position.StartFen,
+		"r3k2r/p1ppqpb1/bn2pnp1/3PN3/1p2P3/2N2Q1p/PPPBBPPP/R3K2R w KQkq -",
+		"r3k2r/Pppp1ppp/1b3nbN/nP6/BBP1P3/q4N2/Pp1P2PP/R2Q1RK1 w kq - 0 1",
+		"r3k2r/1pp4p/2q1qNn1/3nP3/2q1Pp2/B5R1/pbp2PPP/1R4K1 b kq -",
+	}
+	for _, fen := range fens {
+		pos, _ := position.NewPositionFen(fen)
+		legal := mg.GenerateLegalMoves(pos, GenAll).Clone()
+
+		// the moves of all piece types together are the legal moves
+		all := moveslice.NewMoveSlice(MaxMoves)
+		for pt := King; pt <= Queen; pt++ {
+			for _, m := range *mg.GenerateMovesFor(pos, pt) {
+				assert.EqualValues(t, pt, pos.GetPiece(m.From()).TypeOf(), fen)
+				assert.Contains(t, *legal, m, fen)
+				all.PushBack(m)
+			}
+		}
+		assert.ElementsMatch(t, *legal, *all, fen)
+
+		// the moves from all squares together are the legal moves
+		all.Clear()
+		for sq := SqA1; sq < SqNone; sq++ {
+			for _, m := range *mg.GenerateMovesFrom(pos, sq) {
+				assert.EqualValues(t, sq, m.From(), fen)
+				assert.Contains(t, *legal, m, fen)
+				all.PushBack(m)
+			}
+		}
+		assert.ElementsMatch(t, *legal, *all, fen)
+	}
+
+	pos := position.NewPosition()
+	assert.EqualValues(t, 4, mg.GenerateMovesFor(pos, Knight).Len())
+	assert.EqualValues(t, 0, mg.GenerateMovesFor(pos, Bishop).Len())
+	assert.EqualValues(t, "g1f3 g1h3", mg.GenerateMovesFrom(pos, SqG1).StringUci())
+	assert.EqualValues(t, 0, mg.GenerateMovesFrom(pos, SqE7).Len())
+}
+
+func TestGenerateQuietNonChecks(t *testing.T) {
+	mg := NewMoveGen()
+	fens := []string{
+		position.StartFen,
+		"r3k2r/p1ppqpb1/bn2pnp1/3PN3/1p2P3/2N2Q1p/PPPBBPPP/R3K2R w KQkq -",
+		"r3k2r/Pppp1ppp/1b3nbN/nP6/BBP1P3/q4N2/Pp1P2PP/R2Q1RK1 w kq - 0 1",
+		"r3k2r/1pp4p/2q1qNn1/3nP3/2q1Pp2/B5R1/pbp2PPP/1R4K1 b kq -",
+	}
+	for _, fen := range fens {
+		pos, _ := position.NewPositionFen(fen)
+		quiet := mg.GenerateLegalMoves(pos, GenQuiet).Clone()
+		nonChecks := mg.GenerateQuietNonChecks(pos).Clone()
+
+		// quiet checks are categorized separately
+		checks := moveslice.NewMoveSlice(MaxMoves)
+		for _, m := range *quiet {
+			if pos.GivesCheck(m) {
+				checks.PushBack(m)
+				assert.NotContains(t, *nonChecks, m, fen)
+			} else {
+				assert.Contains(t, *nonChecks, m, fen)
+			}
+		}
+		assert.EqualValues(t, quiet.Len(), nonChecks.Len()+checks.Len(), fen)
+	}
+
+	pos, _ := position.NewPositionFen("4k3/8/8/8/8/8/8/R3K3 w - -")
+	quiet := mg.GenerateLegalMoves(pos, GenQuiet).Len()
+	moves := mg.GenerateQuietNonChecks(pos)
+	assert.EqualValues(t, quiet-1, moves.Len())
+	assert.NotContains(t, *moves, CreateMove(SqA1, SqA8, Normal, PtNone))
+	assert.Contains(t, *moves, CreateMove(SqA1, SqA7, Normal, PtNone))
+}
+
 func TestEvasion(t *testing.T) {
 	mg := NewMoveGen()
 	var p *position.Position
@@ -707,3 +1217,59 @@ func TestTimingGenerateMovesNew(t *testing.T) {
 	}
 	_ = result
 }
+
+// benchmarkFens is a fixed set of diverse positions (opening, middle game,
+// tactical, promotions, castling, en passant and end games) to get
+// reproducible move generation benchmarks.
+var benchmarkFens = []string{
+	position.StartFen,
+	"r3k2r/p1ppqpb1/bn2pnp1/3PN3/1p2P3/2N2Q1p/PPPBBPPP/R3K2R w KQkq -",
+	"r3k2r/Pppp1ppp/1b3nbN/nP6/BBP1P3/q4N2/Pp1P2PP/R2Q1RK1 w kq -",
+	"rnbq1k1r/pp1Pbppp/2p5/8/2B5/8/PPP1NnPP/RNBQK2R w KQ -",
+	"r3k2r/1ppn3p/2q1q1n1/4P3/2q1Pp2/B5R1/pbp2PPP/1R4K1 b kq e3",
+	"r1b1k2r/pppp1ppp/2n2n2/1Bb1p2q/4P3/2NP1N2/1PP2PPP/R1BQK2R w KQkq -",
+	"8/2p5/3p4/KP5r/1R3p1k/8/4P1P1/8 w - -",
+	"6k1/p4p1p/1p4p1/8/3N4/1P3P2/P5PP/6K1 b - -",
+}
+
+// Benchmark reporting ns/op and generated moves per second
+// for generating all legal moves for all benchmark positions.
+func BenchmarkGenerateLegalMoves(b *testing.B) {
+	positions := make([]*position.Position, 0, len(benchmarkFens))
+	for _, fen := range benchmarkFens {
+		positions = append(positions, position.NewPosition(fen))
+	}
+	mg := NewMoveGen()
+	generated := 0
+	b.ResetTimer()
+	start := time.Now()
+	for i := 0; i < b.N; i++ {
+		for _, p := range positions {
+			generated += mg.GenerateLegalMoves(p, GenAll).Len()
+		}
+	}
+	b.ReportMetric(float64(generated)/time.Since(start).Seconds(), "moves/s")
+}
+
+// Benchmark reporting ns/op and generated moves per second for
+// generating all pseudo legal moves on demand with killer and pv
+// moves for all benchmark positions.
+func BenchmarkGetNextMove(b *testing.B) {
+	positions := make([]*position.Position, 0, len(benchmarkFens))
+	for _, fen := range benchmarkFens {
+		positions = append(positions, position.NewPosition(fen))
+	}
+	mg := NewMoveGen()
+	generated := 0
+	b.ResetTimer()
+	start := time.Now()
+	for i := 0; i < b.N; i++ {
+		for _, p := range positions {
+			mg.ResetOnDemand()
+			for move := mg.GetNextMove(p, GenAll, false); move != MoveNone; move = mg.GetNextMove(p, GenAll, false) {
+				generated++
+			}
+		}
+	}
+	b.ReportMetric(float64(generated)/time.Since(start).Seconds(), "moves/s")
+}
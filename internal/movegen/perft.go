@@ -27,6 +27,8 @@
 package movegen
 
 import (
+	"errors"
+	"fmt"
 	"time"
 
 	"golang.org/x/text/language"
@@ -50,6 +52,21 @@ type Perft struct {
 	stopFlag         bool
 }
 
+// PerftResult holds the detailed counts of a perft run together with
+// the time it took and the resulting nodes per second.
+type PerftResult struct {
+	Depth      int
+	Nodes      uint64
+	Captures   uint64
+	EnPassants uint64
+	Castles    uint64
+	Promotions uint64
+	Checks     uint64
+	CheckMates uint64
+	Time       time.Duration
+	Nps        uint64
+}
+
 // NewPerft creates a new empty Perft instance
 func NewPerft() *Perft {
 	return &Perft{}
@@ -80,55 +97,93 @@ func (perft *Perft) StartPerftMulti(fen string, startDepth int, endDepth int, on
 // divide the the perft depths.
 // If this has been started in a go routine it can be stopped via Stop()
 func (perft *Perft) StartPerft(fen string, depth int, onDemandFlag bool) {
-	perft.stopFlag = false
-
 	// set 1 as minimum
 	if depth <= 0 {
 		depth = 1
 	}
 
+	out.Printf("Performing PERFT Test for Depth %d\n", depth)
+	out.Printf("FEN: %s\n", fen)
+	out.Printf("-----------------------------------------\n")
+
+	result, err := perft.run(fen, depth, onDemandFlag)
+	if err != nil {
+		out.Printf("Perft: %s\n", err)
+		return
+	}
+
+	out.Printf("Time         : %s\n", result.Time)
+	out.Printf("NPS          : %d nps\n", result.Nps)
+	out.Printf("Results:\n")
+	out.Printf("   Nodes     : %d\n", result.Nodes)
+	out.Printf("   Captures  : %d\n", result.Captures)
+	out.Printf("   EnPassant : %d\n", result.EnPassants)
+	out.Printf("   Checks    : %d\n", result.Checks)
+	out.Printf("   CheckMates: %d\n", result.CheckMates)
+	out.Printf("   Castles   : %d\n", result.Castles)
+	out.Printf("   Promotions: %d\n", result.Promotions)
+	out.Printf("-----------------------------------------\n")
+	out.Printf("Finished PERFT Test for Depth %d\n\n", depth)
+}
+
+// Run does a perft test on the given fen to the given depth without
+// printing anything and returns the detailed counts, the time and the
+// nps as a PerftResult. Returns an error if the fen is invalid, the
+// depth is less than 1 or the perft has been stopped via Stop().
+func (perft *Perft) Run(fen string, depth int) (PerftResult, error) {
+	if depth < 1 {
+		return PerftResult{}, fmt.Errorf("perft depth must be at least 1: %d", depth)
+	}
+	return perft.run(fen, depth, false)
+}
+
+// run does the actual perft test and fills the counters
+// of the perft instance as well as the returned result.
+func (perft *Perft) run(fen string, depth int, onDemandFlag bool) (PerftResult, error) {
+	perft.stopFlag = false
+
 	// prepare
 	perft.resetCounter()
-	posPtr, _ := position.NewPositionFen(fen)
+	posPtr, err := position.NewPositionFen(fen)
+	if err != nil {
+		return PerftResult{}, err
+	}
 	mgList := make([]*Movegen, depth+1)
 	for i := 0; i <= depth; i++ {
 		mgList[i] = NewMoveGen()
 	}
 
-	out.Printf("Performing PERFT Test for Depth %d\n", depth)
-	out.Printf("FEN: %s\n", fen)
-	out.Printf("-----------------------------------------\n")
-
-	result := uint64(0)
+	nodes := uint64(0)
 
 	// the actual perft call
 	start := time.Now()
 	if onDemandFlag {
-		result = perft.miniMaxOD(depth, posPtr, &mgList)
+		nodes = perft.miniMaxOD(depth, posPtr, &mgList)
 	} else {
-		result = perft.miniMax(depth, posPtr, &mgList)
+		nodes = perft.miniMax(depth, posPtr, &mgList)
 	}
 	elapsed := time.Since(start)
 
-	if result == 0 {
-		out.Print("Perft stopped\n")
-		return
+	// a mate or stalemate has 0 nodes - only the stop flag tells if
+	// the perft has been stopped
+	if perft.stopFlag {
+		return PerftResult{}, errors.New("perft stopped")
 	}
 
-	perft.Nodes = result
+	perft.Nodes = nodes
 
-	out.Printf("Time         : %s\n", elapsed)
-	out.Printf("NPS          : %d nps\n", (perft.Nodes*uint64(time.Second.Nanoseconds()))/uint64(elapsed.Nanoseconds()+1))
-	out.Printf("Results:\n")
-	out.Printf("   Nodes     : %d\n", perft.Nodes)
-	out.Printf("   Captures  : %d\n", perft.CaptureCounter)
-	out.Printf("   EnPassant : %d\n", perft.EnpassantCounter)
-	out.Printf("   Checks    : %d\n", perft.CheckCounter)
-	out.Printf("   CheckMates: %d\n", perft.CheckMateCounter)
-	out.Printf("   Castles   : %d\n", perft.CastleCounter)
-	out.Printf("   Promotions: %d\n", perft.PromotionCounter)
-	out.Printf("-----------------------------------------\n")
-	out.Printf("Finished PERFT Test for Depth %d\n\n", depth)
+	return PerftResult{
+		Depth:      depth,
+		Nodes:      perft.Nodes,
+		Captures:   perft.CaptureCounter,
+		EnPassants: perft.EnpassantCounter,
+		Castles:    perft.CastleCounter,
+		Promotions: perft.PromotionCounter,
+		Checks:     perft.CheckCounter,
+		CheckMates: perft.CheckMateCounter,
+		Time:       elapsed,
+		Nps:        (perft.Nodes * uint64(time.Second.Nanoseconds())) / uint64(elapsed.Nanoseconds()+1),
+	}, nil
 }
 
 func (perft *Perft) miniMax(depth int, p *position.Position, mgListPtr *[]*Movegen) uint64 {
@@ -32,6 +32,7 @@ import (
 	"github.com/stretchr/testify/assert"
 
 	"github.com/frankkopp/FrankyGo/internal/position"
+	. "github.com/frankkopp/FrankyGo/internal/types"
 )
 
 // ///////////////////////////////////////////////////////////////
@@ -175,6 +176,44 @@ func TestKiwipetePerft(t *testing.T) {
 	}
 }
 
+func TestPerftRun(t *testing.T) {
+	perft := NewPerft()
+
+	// kiwipete depth 3 - see TestKiwipetePerft
+	result, err := perft.Run("r3k2r/p1ppqpb1/bn2pnp1/3PN3/1p2P3/2N2Q1p/PPPBBPPP/R3K2R w KQkq - ", 3)
+	assert.NoError(t, err)
+	assert.Equal(t, 3, result.Depth)
+	assert.EqualValues(t, 97_862, result.Nodes)
+	assert.EqualValues(t, 17_102, result.Captures)
+	assert.EqualValues(t, 45, result.EnPassants)
+	assert.EqualValues(t, 993, result.Checks)
+	assert.EqualValues(t, 1, result.CheckMates)
+	assert.EqualValues(t, 3_162, result.Castles)
+	assert.EqualValues(t, 0, result.Promotions)
+	assert.Greater(t, int64(result.Time), int64(0))
+	assert.Greater(t, result.Nps, uint64(0))
+
+	// position 3 depth 2 with promotions
+	result, err = perft.Run("r3k2r/Pppp1ppp/1b3nbN/nP6/BBP1P3/q4N2/Pp1P2PP/R2Q1RK1 w kq -", 2)
+	assert.NoError(t, err)
+	assert.EqualValues(t, 264, result.Nodes)
+	assert.EqualValues(t, 87, result.Captures)
+	assert.EqualValues(t, 10, result.Checks)
+	assert.EqualValues(t, 6, result.Castles)
+	assert.EqualValues(t, 48, result.Promotions)
+
+	// a mate has no moves
+	result, err = perft.Run("rnb1kbnr/pppp1ppp/8/4p3/6Pq/5P2/PPPPP2P/RNBQKBNR w KQkq -", 1)
+	assert.NoError(t, err)
+	assert.EqualValues(t, 0, result.Nodes)
+
+	// invalid input
+	_, err = perft.Run("r3k2r/p1ppqpb1/bn2pnp1/3PN3/1p2P3/2N2Q1p/PPPBBPPP/R3K2R w KQkq - ", 0)
+	assert.Error(t, err)
+	_, err = perft.Run("invalid fen", 1)
+	assert.Error(t, err)
+}
+
 //noinspection GoImportUsedAsName
 func TestMirrorPerft(t *testing.T) {
 
@@ -240,3 +279,27 @@ func TestPos5Perft(t *testing.T) {
 		assert.Equal(kiwipete[depth][1], perft.Nodes)
 	}
 }
+
+func TestForEachLegalMovePerft(t *testing.T) {
+	mg := NewMoveGen()
+	tests := []struct {
+		fen   string
+		nodes uint64
+	}{
+		{position.StartFen, 400},
+		{"r3k2r/p1ppqpb1/bn2pnp1/3PN3/1p2P3/2N2Q1p/PPPBBPPP/R3K2R w KQkq - ", 2_039},
+	}
+	for _, test := range tests {
+		p, _ := position.NewPositionFen(test.fen)
+		key := p.ZobristKey()
+		var nodes uint64
+		mg.ForEachLegalMove(p, func(m Move, child *position.Position) {
+			mg.ForEachLegalMove(child, func(m Move, grandChild *position.Position) {
+				nodes++
+			})
+		})
+		assert.Equal(t, test.nodes, nodes, test.fen)
+		// position is unchanged after the traversal
+		assert.Equal(t, key, p.ZobristKey())
+	}
+}
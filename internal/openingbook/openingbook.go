@@ -36,12 +36,16 @@
 // BookFormat::SAN for files with lines of moves in SAN notation
 //
 // BookFormat::PGN for PGN formatted games<br/>
+//
+// Files with the extension .gz are decompressed transparently.
 package openingbook
 
 import (
 	"bufio"
+	"compress/gzip"
 	"encoding/gob"
 	"errors"
+	"io"
 	"os"
 	"path/filepath"
 	"regexp"
@@ -109,6 +113,9 @@ type Book struct {
 	bookMap     map[uint64]BookEntry
 	rootEntry   uint64
 	initialized bool
+	// books with lower priority which are consulted
+	// when this book has no move for a position
+	fallbacks []*Book
 }
 
 // NewBook create as new opening book instance.
@@ -121,6 +128,13 @@ func NewBook() *Book {
 // mutex to support concurrent writing to the book data structure.
 var bookLock sync.Mutex
 
+// bookCacheVersion is written in front of the book data in a cache file.
+// It must be increased whenever the cached data changes (e.g. when the
+// zobrist keys change) so that older cache files are recreated.
+// Version 2: keys of positions without castling rights or with an
+// en passant square changed.
+const bookCacheVersion uint32 = 2
+
 // Initialize reads game data from the given file into the internal data structure.
 // A binary cache file will be created in the same folder (postfix .cache) to
 // speedup subsequent loading if the opening book. Initialization only occurs once
@@ -141,6 +155,46 @@ func (b *Book) Initialize(bookPath string, bookFile string, bookFormat BookForma
 	return err
 }
 
+// LoadMany reads several book files into the opening book. The files are
+// given in order of priority - GetEntry returns the entry of the first
+// book which has a move for the position. Each file is read with the
+// format at the same index in formats. If there are fewer formats than
+// files the last format is used for the remaining files.
+// As with Initialize multiple calls will be ignored until Reset() is called.
+func (b *Book) LoadMany(paths []string, formats []string) error {
+	if b.initialized {
+		return nil
+	}
+	if len(paths) == 0 {
+		return errors.New("no book files given")
+	}
+	if len(formats) == 0 {
+		return errors.New("no book formats given")
+	}
+	for i, p := range paths {
+		formatString := formats[len(formats)-1]
+		if i < len(formats) {
+			formatString = formats[i]
+		}
+		bookFormat, found := FormatFromString[formatString]
+		if !found {
+			return errors.New("invalid book format " + formatString)
+		}
+		book := b
+		if i > 0 {
+			book = NewBook()
+		}
+		if err := book.Initialize(p, "", bookFormat, true, false); err != nil {
+			b.Reset()
+			return err
+		}
+		if i > 0 {
+			b.fallbacks = append(b.fallbacks, book)
+		}
+	}
+	return nil
+}
+
 func (b *Book) initialize(bookFilePath string, bookFormat BookFormat, useCache bool, recreateCache bool) error {
 	startTotal := time.Now()
 
@@ -233,14 +287,21 @@ func (b *Book) NumberOfEntries() int {
 	return len(b.bookMap)
 }
 
-// GetEntry returns a copy of the entry with the corresponding key
+// GetEntry returns a copy of the entry with the corresponding key.
+// If several books have been loaded with LoadMany the entry of the
+// book with the highest priority having a move for the position is
+// returned.
 func (b *Book) GetEntry(key position.Key) (BookEntry, bool) {
 	entryPtr, ok := b.bookMap[uint64(key)]
-	if ok {
+	if ok && len(entryPtr.Moves) > 0 {
 		return entryPtr, true
-	} else {
-		return entryPtr, false
 	}
+	for _, fb := range b.fallbacks {
+		if e, found := fb.GetEntry(key); found && len(e.Moves) > 0 {
+			return e, true
+		}
+	}
+	return entryPtr, ok
 }
 
 // Reset resets the opening book so it can/must be initialized again
@@ -248,6 +309,7 @@ func (b *Book) Reset() {
 	b.bookMap = map[uint64]BookEntry{}
 	b.rootEntry = 0
 	b.initialized = false
+	b.fallbacks = nil
 }
 
 // /////////////////////////////////////////////////
@@ -266,8 +328,19 @@ func (b *Book) readFile(bookPath string) (*[]string, error) {
 			b.log.Errorf("File \"%s\" could not be closed: %s\n", bookPath, err)
 		}
 	}()
+	var r io.Reader = f
+	// gzip compressed files are decompressed transparently
+	if strings.HasSuffix(strings.ToLower(bookPath), ".gz") {
+		gz, err := gzip.NewReader(f)
+		if err != nil {
+			b.log.Errorf("File \"%s\" could not be decompressed: %s\n", bookPath, err)
+			return nil, err
+		}
+		defer gz.Close()
+		r = gz
+	}
 	var lines []string
-	s := bufio.NewScanner(f)
+	s := bufio.NewScanner(r)
 	for s.Scan() {
 		lines = append(lines, s.Text())
 	}
@@ -613,13 +686,22 @@ func (b *Book) loadFromCache(bookPath string) (bool, error) {
 	// Create a decoder
 	decoder := gob.NewDecoder(decodeFile)
 
+	// cache files of other versions (or without version) are not used
+	var version uint32
+	if err = decoder.Decode(&version); err != nil {
+		return false, err
+	}
+	if version != bookCacheVersion {
+		return false, errors.New("cache file version does not match")
+	}
+
 	// Decode -- We need to pass a pointer otherwise accounts2 isn't modified
 	bookLock.Lock()
 	err = decoder.Decode(&b.bookMap)
+	bookLock.Unlock()
 	if err != nil {
 		return false, err
 	}
-	bookLock.Unlock()
 
 	// set root entry key
 	p := position.NewPosition()
@@ -641,7 +723,10 @@ func (b *Book) saveToCache(bookPath string) (string, int64, error) {
 	// create binary encoder
 	enc := gob.NewEncoder(encodeFile)
 
-	// encode bookMap
+	// encode version and bookMap
+	if err = enc.Encode(bookCacheVersion); err != nil {
+		return cachePath, 0, err
+	}
 	bookLock.Lock()
 	if err = enc.Encode(b.bookMap); err != nil {
 		panic(err)
@@ -27,6 +27,9 @@
 package openingbook
 
 import (
+	"compress/gzip"
+	"encoding/gob"
+	"io/ioutil"
 	"os"
 	"path"
 	"path/filepath"
@@ -125,6 +128,27 @@ func TestProcessingSimpleSmall(t *testing.T) {
 	// }
 }
 
+func TestProcessingSimpleGzip(t *testing.T) {
+	data, err := ioutil.ReadFile(config.Settings.Search.BookPath + "/book_smalltest.txt")
+	assert.NoError(t, err)
+	bookFile := filepath.Join(t.TempDir(), "book_smalltest.txt.gz")
+	file, err := os.Create(bookFile)
+	assert.NoError(t, err)
+	gz := gzip.NewWriter(file)
+	_, err = gz.Write(data)
+	assert.NoError(t, err)
+	assert.NoError(t, gz.Close())
+	assert.NoError(t, file.Close())
+
+	book := NewBook()
+	err = book.Initialize(bookFile, "", Simple, false, false)
+	assert.NoError(t, err, "Initialize book threw error: %s", err)
+	assert.Equal(t, 11_196, book.NumberOfEntries())
+	entry, found := book.GetEntry(position.NewPosition().ZobristKey())
+	assert.True(t, found)
+	assert.Equal(t, 10, len(entry.Moves))
+}
+
 func TestProcessingSimple(t *testing.T) {
 	if testing.Short() {
 		t.Skip("skipping test in short mode.")
@@ -302,6 +326,33 @@ func TestProcessingPGNCacheSmall(t *testing.T) {
 	}
 }
 
+func TestCacheVersion(t *testing.T) {
+	dir, err := ioutil.TempDir("", "book")
+	assert.NoError(t, err)
+	defer os.RemoveAll(dir)
+	bookPath := filepath.Join(dir, "book.txt")
+	assert.NoError(t, ioutil.WriteFile(bookPath, []byte("e2e4 e7e5\n"), 0644))
+
+	// a cache file without version is not used and is recreated
+	cacheFile, err := os.Create(bookPath + ".cache")
+	assert.NoError(t, err)
+	assert.NoError(t, gob.NewEncoder(cacheFile).Encode(map[uint64]BookEntry{}))
+	assert.NoError(t, cacheFile.Close())
+	book := NewBook()
+	hasCache, err := book.loadFromCache(bookPath)
+	assert.False(t, hasCache)
+	assert.Error(t, err)
+	assert.NoError(t, book.Initialize(bookPath, "", Simple, true, false))
+	assert.Equal(t, 3, book.NumberOfEntries())
+
+	// the recreated cache file is used
+	book = NewBook()
+	hasCache, err = book.loadFromCache(bookPath)
+	assert.True(t, hasCache)
+	assert.NoError(t, err)
+	assert.Equal(t, 3, book.NumberOfEntries())
+}
+
 func TestProcessingPGNCacheLarge(t *testing.T) {
 	if testing.Short() {
 		t.Skip("skipping test in short mode.")
@@ -342,3 +393,41 @@ func TestProcessingPGNCacheLarge(t *testing.T) {
 	assert.Equal(t, 89_615, entry.Counter)
 
 }
+
+func TestLoadManyPriority(t *testing.T) {
+	dir := t.TempDir()
+	highFile := filepath.Join(dir, "high.txt")
+	lowFile := filepath.Join(dir, "low.txt")
+	assert.NoError(t, os.WriteFile(highFile, []byte("e2e4 e7e5\n"), 0644))
+	assert.NoError(t, os.WriteFile(lowFile, []byte("d2d4 d7d5\ne2e4 c7c5 g1f3\n"), 0644))
+
+	book := NewBook()
+	err := book.LoadMany([]string{highFile, lowFile}, []string{"Simple"})
+	assert.NoError(t, err, "Loading books threw error: %s", err)
+
+	// both books have moves - high priority book wins
+	pos := position.NewPosition()
+	entry, found := book.GetEntry(pos.ZobristKey())
+	assert.True(t, found)
+	assert.Equal(t, 1, len(entry.Moves))
+	assert.Equal(t, CreateMove(SqE2, SqE4, Normal, PtNone), Move(entry.Moves[0].Move))
+
+	// after e2e4 the high priority book has e7e5 and the low priority book c7c5
+	pos.DoMove(CreateMove(SqE2, SqE4, Normal, PtNone))
+	entry, found = book.GetEntry(pos.ZobristKey())
+	assert.True(t, found)
+	assert.Equal(t, CreateMove(SqE7, SqE5, Normal, PtNone), Move(entry.Moves[0].Move))
+
+	// only the low priority book has moves for this position
+	pos = position.NewPosition()
+	pos.DoMove(CreateMove(SqE2, SqE4, Normal, PtNone))
+	pos.DoMove(CreateMove(SqC7, SqC5, Normal, PtNone))
+	entry, found = book.GetEntry(pos.ZobristKey())
+	assert.True(t, found)
+	assert.Equal(t, CreateMove(SqG1, SqF3, Normal, PtNone), Move(entry.Moves[0].Move))
+
+	// invalid format
+	book = NewBook()
+	err = book.LoadMany([]string{highFile, lowFile}, []string{"Simple", "Foo"})
+	assert.Error(t, err)
+}
@@ -0,0 +1,66 @@
+//
+// FrankyGo - UCI chess engine in GO for learning purposes
+//
+// MIT License
+//
+// Copyright (c) 2018-2020 Frank Kopp
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in all
+// copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE
+// SOFTWARE.
+//
+
+package position
+
+import (
+	"fmt"
+)
+
+// FenErrorKind describes which part of a fen is malformed.
+type FenErrorKind int
+
+// Kinds of fen errors
+const (
+	InvalidChar   FenErrorKind = iota // invalid character in the piece placement
+	BadRankCount                      // not 8 ranks with 8 squares each
+	BadSideToMove                     // side to move is not w or b
+	BadCastling                       // invalid or unsupported castling rights
+	BadEnPassant                      // invalid en passant square
+	BadCounter                        // half move clock or move number not a number
+)
+
+var fenErrorKindNames = [...]string{"invalid character", "bad rank count", "bad side to move",
+	"bad castling rights", "bad en passant square", "bad counter"}
+
+// String returns a readable name for the kind of fen error
+func (k FenErrorKind) String() string {
+	return fenErrorKindNames[k]
+}
+
+// FenError is returned when a fen can't be parsed. It has the kind of the
+// error, the offending part of the fen and its position (index) in the fen
+// to allow precise diagnostics.
+type FenError struct {
+	Kind FenErrorKind
+	Part string
+	Pos  int
+}
+
+// Error implements the error interface
+func (e *FenError) Error() string {
+	return fmt.Sprintf("invalid fen - %s: '%s' at position %d", e.Kind.String(), e.Part, e.Pos)
+}
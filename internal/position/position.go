@@ -34,7 +34,6 @@
 package position
 
 import (
-	"errors"
 	"fmt"
 	"regexp"
 	"strconv"
@@ -54,7 +53,7 @@ var initialized = false
 // initialize package
 func init() {
 	if !initialized {
-		initZobrist()
+		initZobrist(uint64(DefaultZobristSeed))
 		initialized = true
 	}
 }
@@ -90,14 +89,25 @@ type Position struct {
 	halfMoveClock   int
 	nextPlayer      Color
 
+	// files of the castling rooks in the order of castlingRightsOrder
+	// (Shredder-FEN / X-FEN). Usually h and a.
+	castlingRookFile [4]File
+	// castling rights which are lost when a piece moves from or to
+	// the square. These are the king and castling rook squares.
+	castlingMask [SqLength]CastlingRights
+
 	// Extended Board State
 	// not necessary for a unique position
 	// special for king squares
 	kingSquare [ColorLength]Square
+	// king zone - king square and adjacent squares
+	kingZone [ColorLength]Bitboard
 	// half move number - the actual half move number to determine the full move number
 	nextHalfMoveNumber int
 	// piece bitboards
 	piecesBb [ColorLength][PtLength]Bitboard
+	// number of pieces per color and piece type
+	pieceCount [ColorLength][PtLength]int
 	// occupied bitboards with rotations
 	occupiedBb [ColorLength]Bitboard
 
@@ -119,13 +129,16 @@ type Position struct {
 	// Positional value will always be up to date
 	psqMidValue [ColorLength]Value
 	psqEndValue [ColorLength]Value
-	// Game phase value
+	// Game phase value - not clamped to GamePhaseMax so that it
+	// does not drift when there are more officers than at start
 	gamePhase int
 
 	// caches a hasCheck and hasMate Flag for the current position. Will be set
 	// after a call to hasCheck() and reset to TBD every time a move is made or
 	// unmade.
 	hasCheckFlag int
+	// caches the IsQuiet() result in the same way as hasCheckFlag
+	isQuietFlag int
 }
 
 type historyState struct {
@@ -137,6 +150,7 @@ type historyState struct {
 	enpassantSquare Square
 	halfMoveClock   int
 	hasCheckFlag    int
+	isQuietFlag     int
 }
 
 const maxHistory int = MaxMoves
@@ -224,6 +238,7 @@ func (p *Position) DoMove(m Move) {
 	p.history[tmpHistoryCounter].enpassantSquare = p.enPassantSquare
 	p.history[tmpHistoryCounter].halfMoveClock = p.halfMoveClock
 	p.history[tmpHistoryCounter].hasCheckFlag = p.hasCheckFlag
+	p.history[tmpHistoryCounter].isQuietFlag = p.isQuietFlag
 	// update counter
 	p.historyCounter++
 
@@ -241,9 +256,15 @@ func (p *Position) DoMove(m Move) {
 
 	// update additional state info
 	p.hasCheckFlag = flagTBD
+	p.isQuietFlag = flagTBD
 	p.nextHalfMoveNumber++
 	p.nextPlayer = p.nextPlayer.Flip()
 	p.zobristKey ^= zobristBase.nextPlayer
+
+	if assert.DEBUG {
+		assert.Assert(p.GamePhase() == p.ComputeGamePhaseFromScratch(),
+			"Position DoMove: game phase %d differs from recomputed game phase %d", p.GamePhase(), p.ComputeGamePhaseFromScratch())
+	}
 }
 
 // UndoMove resets the position to a state before the last move has been made
@@ -301,7 +322,13 @@ func (p *Position) UndoMove() {
 	p.enPassantSquare = p.history[tmpHistoryCounter].enpassantSquare
 	p.halfMoveClock = p.history[tmpHistoryCounter].halfMoveClock
 	p.hasCheckFlag = p.history[tmpHistoryCounter].hasCheckFlag
+	p.isQuietFlag = p.history[tmpHistoryCounter].isQuietFlag
 	p.zobristKey = p.history[tmpHistoryCounter].zobristKey
+
+	if assert.DEBUG {
+		assert.Assert(p.GamePhase() == p.ComputeGamePhaseFromScratch(),
+			"Position UndoMove: game phase %d differs from recomputed game phase %d", p.GamePhase(), p.ComputeGamePhaseFromScratch())
+	}
 }
 
 // DoNullMove is used in Null Move Pruning. The position is basically unchanged but
@@ -323,10 +350,12 @@ func (p *Position) DoNullMove() {
 	p.history[tmpHistoryCounter].enpassantSquare = p.enPassantSquare
 	p.history[tmpHistoryCounter].halfMoveClock = p.halfMoveClock
 	p.history[tmpHistoryCounter].hasCheckFlag = p.hasCheckFlag
+	p.history[tmpHistoryCounter].isQuietFlag = p.isQuietFlag
 	// update counter
 	p.historyCounter++
 	// update state for null move
 	p.hasCheckFlag = flagTBD
+	p.isQuietFlag = flagTBD
 	p.clearEnPassant()
 	p.nextHalfMoveNumber++
 	p.nextPlayer = p.nextPlayer.Flip()
@@ -352,9 +381,106 @@ func (p *Position) UndoNullMove() {
 	p.enPassantSquare = p.history[tmpHistoryCounter].enpassantSquare
 	p.halfMoveClock = p.history[tmpHistoryCounter].halfMoveClock
 	p.hasCheckFlag = p.history[tmpHistoryCounter].hasCheckFlag
+	p.isQuietFlag = p.history[tmpHistoryCounter].isQuietFlag
 	p.zobristKey = p.history[tmpHistoryCounter].zobristKey
 }
 
+// UndoMoves takes back the last n moves (including null moves) but never
+// more than have been made on the position. A negative n takes back
+// nothing. Returns the number of moves
+// which have been taken back.
+func (p *Position) UndoMoves(n int) int {
+	if n < 0 {
+		n = 0
+	} else if n > p.historyCounter {
+		n = p.historyCounter
+	}
+	for i := 0; i < n; i++ {
+		if p.history[p.historyCounter-1].move == MoveNone {
+			p.UndoNullMove()
+		} else {
+			p.UndoMove()
+		}
+	}
+	return n
+}
+
+// UndoAll takes back all moves made on the position and returns to its
+// initial setup. Returns the number of moves which have been taken back.
+func (p *Position) UndoAll() int {
+	return p.UndoMoves(p.historyCounter)
+}
+
+// piece types ordered from the least to the most valuable
+var pieceTypesByValue = [...]PieceType{Pawn, Knight, Bishop, Rook, Queen, King}
+
+// AttackersTo returns a bitboard with all pieces of the given color
+// directly attacking the given square. En passant is not considered.
+func (p *Position) AttackersTo(sq Square, by Color) Bitboard {
+	occupiedAll := p.OccupiedAll()
+	return (GetPawnAttacks(by.Flip(), sq) & p.piecesBb[by][Pawn]) |
+		(GetAttacksBb(Knight, sq, BbZero) & p.piecesBb[by][Knight]) |
+		(GetAttacksBb(King, sq, BbZero) & p.piecesBb[by][King]) |
+		(GetAttacksBb(Rook, sq, occupiedAll) & (p.piecesBb[by][Rook] | p.piecesBb[by][Queen])) |
+		(GetAttacksBb(Bishop, sq, occupiedAll) & (p.piecesBb[by][Bishop] | p.piecesBb[by][Queen]))
+}
+
+// GetSmallestPieceBitboard returns the pieces of the least valuable
+// piece type of the given color within the given bitboard and their
+// piece type. Returns BbZero and PtNone if there are none.
+func (p *Position) GetSmallestPieceBitboard(bb Bitboard, c Color) (Bitboard, PieceType) {
+	for _, pt := range pieceTypesByValue {
+		if pieces := bb & p.piecesBb[c][pt]; pieces != BbZero {
+			return pieces, pt
+		}
+	}
+	return BbZero, PtNone
+}
+
+// AttackersByValue returns the squares of all pieces of the given color
+// directly attacking the given square ordered from the least to the
+// most valuable piece. Pieces of the same type are ordered by square.
+func (p *Position) AttackersByValue(sq Square, by Color) []Square {
+	attackers := p.AttackersTo(sq, by)
+	squares := make([]Square, 0, attackers.PopCount())
+	for _, pt := range pieceTypesByValue {
+		pieces := attackers & p.piecesBb[by][pt]
+		for pieces != BbZero {
+			squares = append(squares, pieces.PopLsb())
+		}
+	}
+	return squares
+}
+
+// LowestAttacker returns the piece type of the lowest value of the given
+// color directly attacking the given square or PtNone if the square is
+// not attacked. En passant captures are not considered.
+func (p *Position) LowestAttacker(sq Square, by Color) PieceType {
+	_, pt := p.GetSmallestPieceBitboard(p.AttackersTo(sq, by), by)
+	return pt
+}
+
+// Threats returns the pieces of the given targets attacked by the given
+// color. Hanging pieces are not defended and threatened pieces are
+// defended but attacked by a piece of lower value. The targets should be
+// pieces of the opponent without the king. Pins and x-rays are ignored.
+func (p *Position) Threats(targets Bitboard, by Color) (hanging Bitboard, threatened Bitboard) {
+	them := by.Flip()
+	for targets != BbZero {
+		sq := targets.PopLsb()
+		attacker := p.LowestAttacker(sq, by)
+		if attacker == PtNone {
+			continue
+		}
+		if !p.IsAttacked(sq, them) {
+			hanging.PushSquare(sq)
+		} else if attacker.ValueOf() < p.board[sq].TypeOf().ValueOf() {
+			threatened.PushSquare(sq)
+		}
+	}
+	return hanging, threatened
+}
+
 // IsAttacked checks if the given square is attacked by a piece
 // of the given color.
 func (p *Position) IsAttacked(sq Square, by Color) bool {
@@ -455,6 +581,9 @@ func (p *Position) IsLegalMove(move Move) bool {
 	}
 	// make the move on the position
 	// then check if the move leaves the king in check
+	// this also covers en passant captures which remove two pawns
+	// from the king's rank and reveal an attack by a rook or queen -
+	// a faster pin based check would need to special case these
 	p.DoMove(move)
 	legal := !p.IsAttacked(p.kingSquare[p.nextPlayer.Flip()], p.nextPlayer)
 	p.UndoMove()
@@ -527,6 +656,28 @@ func (p *Position) HasCheck() bool {
 	return check
 }
 
+// IsQuiet returns true if neither king is in check and the next player
+// has no immediately winning capture. A capture is winning if the
+// captured piece is not defended or is attacked by a piece of lower value.
+// This is a static approximation ignoring pins and x-rays. It can be used
+// to decide if the static evaluation of the position can be trusted.
+// The result is cached until a move is made or unmade.
+func (p *Position) IsQuiet() bool {
+	if p.isQuietFlag != flagTBD {
+		return p.isQuietFlag == flagTrue
+	}
+	them := p.nextPlayer.Flip()
+	hanging, threatened := p.Threats(p.occupiedBb[them]&^p.piecesBb[them][King], p.nextPlayer)
+	quiet := !p.HasCheck() && !p.IsAttacked(p.kingSquare[them], p.nextPlayer) &&
+		hanging|threatened == BbZero
+	if quiet {
+		p.isQuietFlag = flagTrue
+	} else {
+		p.isQuietFlag = flagFalse
+	}
+	return quiet
+}
+
 // IsCapturingMove determines if a move on this position is a capturing move
 // incl. en passant
 func (p *Position) IsCapturingMove(move Move) bool {
@@ -588,6 +739,12 @@ func (p *Position) HasInsufficientMaterial() bool {
 	// have different values and it is assumed that this is faster
 	// then a pop count on a bitboard - not empirically tested
 
+	// fast exit for the common case - the most pieces which can be
+	// insufficient material are two kings and three minor pieces
+	if p.OccupiedAll().PopCount() > 5 {
+		return false
+	}
+
 	// no material
 	// both sides have a bare king
 	if p.material[White]+p.material[Black] == 0 {
@@ -595,7 +752,7 @@ func (p *Position) HasInsufficientMaterial() bool {
 	}
 
 	// no more pawns
-	if p.piecesBb[White][Pawn].PopCount() == 0 && p.piecesBb[Black][Pawn].PopCount() == 0 {
+	if p.pieceCount[White][Pawn] == 0 && p.pieceCount[Black][Pawn] == 0 {
 		// one side has a king and a minor piece against a bare king
 		// both sides have a king and a minor piece each
 		if p.materialNonPawn[White] < 400 && p.materialNonPawn[Black] < 400 {
@@ -616,8 +773,9 @@ func (p *Position) HasInsufficientMaterial() bool {
 			return false
 		}
 		// two minor pieces against one draw, except when the stronger side has a bishop pair
-		if (p.materialNonPawn[White] < 2*Bishop.ValueOf() && p.materialNonPawn[Black] <= Bishop.ValueOf()) ||
-			(p.materialNonPawn[White] <= Bishop.ValueOf() && p.materialNonPawn[Black] < 2*Bishop.ValueOf()) {
+		// (bishop and knight against the bare king can force a mate)
+		if (p.materialNonPawn[White] < 2*Bishop.ValueOf() && p.materialNonPawn[Black] > 0 && p.materialNonPawn[Black] <= Bishop.ValueOf()) ||
+			(p.materialNonPawn[White] > 0 && p.materialNonPawn[White] <= Bishop.ValueOf() && p.materialNonPawn[Black] < 2*Bishop.ValueOf()) {
 			return true
 		}
 	}
@@ -716,7 +874,7 @@ func (p *Position) String() string {
 	os.WriteString(p.StringBoard())
 	os.WriteString("\n")
 	os.WriteString(fmt.Sprintf("Next Player    : %s\n", p.nextPlayer.String()))
-	os.WriteString(fmt.Sprintf("Game Phase     : %d\n", p.gamePhase))
+	os.WriteString(fmt.Sprintf("Game Phase     : %d\n", p.GamePhase()))
 	os.WriteString(fmt.Sprintf("Material White : %d\n", p.material[White]))
 	os.WriteString(fmt.Sprintf("Material Black : %d\n", p.material[Black]))
 	os.WriteString(fmt.Sprintf("Pos value White: %d/%d\n", p.psqMidValue[White], p.psqEndValue[White]))
@@ -729,6 +887,50 @@ func (p *Position) StringFen() string {
 	return p.fen()
 }
 
+// MirrorVertical returns a new position which is the vertical mirror of
+// this position. Ranks are mirrored and the colors of all pieces, the
+// next player, castling rights and the en passant square are swapped.
+// The evaluation of the mirrored position should be the same as the
+// evaluation of this position from the view of the next player.
+// The move history is not copied.
+func (p *Position) MirrorVertical() *Position {
+	fenParts := strings.Split(p.fen(), " ")
+	swapCase := func(r rune) rune {
+		switch {
+		case r >= 'a' && r <= 'z':
+			return r - 'a' + 'A'
+		case r >= 'A' && r <= 'Z':
+			return r - 'A' + 'a'
+		}
+		return r
+	}
+	// board
+	ranks := strings.Split(fenParts[0], "/")
+	for i, j := 0, len(ranks)-1; i < j; i, j = i+1, j-1 {
+		ranks[i], ranks[j] = ranks[j], ranks[i]
+	}
+	fenParts[0] = strings.Map(swapCase, strings.Join(ranks, "/"))
+	// next player
+	if fenParts[1] == "w" {
+		fenParts[1] = "b"
+	} else {
+		fenParts[1] = "w"
+	}
+	// castling rights
+	fenParts[2] = strings.Map(swapCase, fenParts[2])
+	// en passant
+	if fenParts[3] != "-" {
+		if fenParts[3][1] == '3' {
+			fenParts[3] = fenParts[3][:1] + "6"
+		} else {
+			fenParts[3] = fenParts[3][:1] + "3"
+		}
+	}
+	// the mirror of a valid position is always valid
+	mirror, _ := NewPositionFen(strings.Join(fenParts, " "))
+	return mirror
+}
+
 // StringBoard returns a visual matrix of the board and pieces
 func (p *Position) StringBoard() string {
 	var os strings.Builder
@@ -748,11 +950,117 @@ func (p *Position) StringBoard() string {
 // Private
 // //////////////////////////////////////////////////////////
 
+// the single castling rights in the order of the castling field of a fen
+var castlingRightsOrder = [4]CastlingRights{CastlingWhiteOO, CastlingWhiteOOO, CastlingBlackOO, CastlingBlackOOO}
+
+// backRank returns the rank the pieces of the given color start on
+func backRank(c Color) Rank {
+	if c == White {
+		return Rank1
+	}
+	return Rank8
+}
+
+// castlingIndex returns the index of the given single castling right
+// in castlingRightsOrder.
+func castlingIndex(cr CastlingRights) int {
+	switch cr {
+	case CastlingWhiteOO:
+		return 0
+	case CastlingWhiteOOO:
+		return 1
+	case CastlingBlackOO:
+		return 2
+	}
+	return 3
+}
+
+// outermostRookFile returns the file of the rook of the given color on
+// its back rank which is the farthest away from the king on the king
+// side (kingSide=true) or the queen side. If there is no such rook the
+// standard rook file h or a is returned.
+func (p *Position) outermostRookFile(color Color, kingSide bool) File {
+	rook := MakePiece(color, Rook)
+	kingFile := p.kingSquare[color].FileOf()
+	if kingSide {
+		for f := FileH; f > kingFile; f-- {
+			if p.board[SquareOf(f, backRank(color))] == rook {
+				return f
+			}
+		}
+		return FileH
+	}
+	for f := FileA; f < kingFile; f++ {
+		if p.board[SquareOf(f, backRank(color))] == rook {
+			return f
+		}
+	}
+	return FileA
+}
+
+// setupCastlingMask sets up the castling rights lost by moves from or to
+// the king and castling rook squares for the current castling rights and
+// castling rook files. The rook files of rights not available are reset
+// to the standard files.
+func (p *Position) setupCastlingMask() {
+	for i, cr := range castlingRightsOrder {
+		color := White
+		if cr.Has(CastlingBlack) {
+			color = Black
+		}
+		if !p.castlingRights.Has(cr) {
+			p.castlingRookFile[i] = FileH
+			if cr.Has(CastlingWhiteOOO | CastlingBlackOOO) {
+				p.castlingRookFile[i] = FileA
+			}
+			continue
+		}
+		p.castlingMask[SquareOf(p.castlingRookFile[i], backRank(color))] |= cr
+		if color == White {
+			p.castlingMask[p.kingSquare[color]] |= CastlingWhite
+		} else {
+			p.castlingMask[p.kingSquare[color]] |= CastlingBlack
+		}
+	}
+}
+
+// castlingString returns the castling field of the fen in X-FEN notation.
+// KQkq are used for the outermost rooks and the file letter of the rook
+// otherwise (upper case for white).
+func (p *Position) castlingString() string {
+	if p.castlingRights == CastlingNone {
+		return "-"
+	}
+	var os strings.Builder
+	for i, cr := range castlingRightsOrder {
+		if !p.castlingRights.Has(cr) {
+			continue
+		}
+		color := White
+		if cr.Has(CastlingBlack) {
+			color = Black
+		}
+		kingSide := cr.Has(CastlingWhiteOO | CastlingBlackOO)
+		letter := "K"
+		if !kingSide {
+			letter = "Q"
+		}
+		if p.castlingRookFile[i] != p.outermostRookFile(color, kingSide) {
+			letter = strings.ToUpper(p.castlingRookFile[i].String())
+		}
+		if color == Black {
+			letter = strings.ToLower(letter)
+		}
+		os.WriteString(letter)
+	}
+	return os.String()
+}
+
 func (p *Position) doNormalMove(fromSq Square, toSq Square, targetPc Piece, fromPc Piece, myColor Color) {
 	// If we still have castling rights and the move touches castling squares then invalidate
 	// the corresponding castling right
 	if p.castlingRights != CastlingNone {
-		cr := GetCastlingRights(fromSq) | GetCastlingRights(toSq)
+		cr := p.castlingMask[fromSq] | p.castlingMask[toSq]
 		if cr != CastlingNone {
 			p.zobristKey ^= zobristBase.castlingRights[p.castlingRights] // out
 			p.castlingRights.Remove(cr)
@@ -822,7 +1130,7 @@ func (p *Position) doPromotionMove(m Move, myColor Color, toSq Square, targetPc
 		p.removePiece(toSq)
 	}
 	if p.castlingRights != CastlingNone {
-		cr := GetCastlingRights(fromSq) | GetCastlingRights(toSq)
+		cr := p.castlingMask[fromSq] | p.castlingMask[toSq]
 		if cr != CastlingNone {
 			p.zobristKey ^= zobristBase.castlingRights[p.castlingRights] // out
 			p.castlingRights.Remove(cr)
@@ -846,17 +1154,16 @@ func (p *Position) putPiece(piece Piece, square Square) {
 	p.board[square] = piece
 	if pieceType == King {
 		p.kingSquare[color] = square
+		p.kingZone[color] = GetPseudoAttacks(King, square) | square.Bb()
 	}
 	// update bitboards
 	p.piecesBb[color][pieceType].PushSquare(square)
 	p.occupiedBb[color].PushSquare(square)
+	p.pieceCount[color][pieceType]++
 	// zobrist
 	p.zobristKey ^= zobristBase.pieces[piece][square]
 	// game phase
 	p.gamePhase += pieceType.GamePhaseValue()
-	if p.gamePhase > GamePhaseMax {
-		p.gamePhase = GamePhaseMax
-	}
 	// material
 	p.material[color] += pieceType.ValueOf()
 	if pieceType > Pawn {
@@ -876,13 +1183,11 @@ func (p *Position) removePiece(square Square) Piece {
 	// update bitboards
 	p.piecesBb[color][pieceType].PopSquare(square)
 	p.occupiedBb[color].PopSquare(square)
+	p.pieceCount[color][pieceType]--
 	// zobrist
 	p.zobristKey ^= zobristBase.pieces[removed][square]
 	// game phase
 	p.gamePhase -= pieceType.GamePhaseValue()
-	if p.gamePhase < 0 {
-		p.gamePhase = 0
-	}
 	// material
 	p.material[color] -= pieceType.ValueOf()
 	if pieceType > Pawn {
@@ -930,7 +1235,7 @@ func (p *Position) fen() string {
 	fen.WriteString(p.nextPlayer.String())
 	// castling
 	fen.WriteString(" ")
-	fen.WriteString(p.castlingRights.String())
+	fen.WriteString(p.castlingString())
 	// en passant
 	fen.WriteString(" ")
 	fen.WriteString(p.enPassantSquare.String())
@@ -944,14 +1249,10 @@ func (p *Position) fen() string {
 	return fen.String()
 }
 
-// regex for first part of fen (position of pieces)
-var regexFenPos = regexp.MustCompile("[0-8pPnNbBrRqQkK/]+")
-
-// regex for next player color in fen
-var regexWorB = regexp.MustCompile("^[w|b]$")
-
 // regex for castling rights in fen
-var regexCastlingRights = regexp.MustCompile("^(K?Q?k?q?|-)$")
+// Besides the standard KQkq this also allows the rook file letters
+// of Shredder-FEN and X-FEN (e.g. HAha).
+var regexCastlingRights = regexp.MustCompile("^([KQA-Hkqa-h]{1,4}|-)$")
 
 // regex for en passant square in fen
 var regexEnPassant = regexp.MustCompile("^([a-h][1-8]|-)$")
@@ -966,45 +1267,42 @@ func (p *Position) setupBoard(fen string) error {
 	fen = strings.TrimSpace(fen)
 	fenParts := strings.Split(fen, " ")
 
-	if len(fenParts) == 0 {
-		err := errors.New("fen must not be empty")
-		return err
-	}
-
-	// make sure only valid chars are used
-	match := regexFenPos.MatchString(fenParts[0])
-	if !match {
-		err := errors.New("fen position contains invalid characters")
-		return err
+	// start index of each fen part for error positions
+	partPos := make([]int, len(fenParts))
+	for i := 1; i < len(fenParts); i++ {
+		partPos[i] = partPos[i-1] + len(fenParts[i-1]) + 1
 	}
 
 	// fen string starts at a8 and runs to h8
 	// with / jumping to file A of next lower rank
-	currentSquare := SqA8
-
-	// loop over fen characters
-	for _, c := range fenParts[0] {
-		if number, e := strconv.Atoi(string(c)); e == nil { // is number
-			currentSquare = Square(int(currentSquare) + (number * int(East)))
-		} else if string(c) == "/" { // find rank separator
-			if currentSquare == SqNone {
-				currentSquare = SqA7
-			} else {
-				currentSquare = currentSquare.To(South).To(South)
+	rank, file := 0, 0
+	for i, c := range fenParts[0] {
+		switch {
+		case c >= '1' && c <= '8': // empty squares
+			file += int(c - '0')
+		case c == '/': // rank separator
+			if file != 8 || rank == 7 {
+				return &FenError{Kind: BadRankCount, Part: fenParts[0], Pos: i}
 			}
-		} else { // find piece type
+			rank++
+			file = 0
+		default: // piece
 			piece := PieceFromChar(string(c))
 			if piece == PieceNone {
-				err := errors.New(fmt.Sprintf("invalid piece character: %s", string(c)))
-				return err
+				return &FenError{Kind: InvalidChar, Part: string(c), Pos: i}
 			}
-			p.putPiece(piece, currentSquare)
-			currentSquare++
+			if file >= 8 {
+				return &FenError{Kind: BadRankCount, Part: fenParts[0], Pos: i}
+			}
+			p.putPiece(piece, SquareOf(File(file), Rank8-Rank(rank)))
+			file++
+		}
+		if file > 8 {
+			return &FenError{Kind: BadRankCount, Part: fenParts[0], Pos: i}
 		}
 	}
-	if currentSquare != SqA2 { // after h1++ we reach a2 - a2 needs to be last current square
-		err := errors.New("not reached last square (h1) after reading fen")
-		return err
+	if rank != 7 || file != 8 {
+		return &FenError{Kind: BadRankCount, Part: fenParts[0], Pos: len(fenParts[0])}
 	}
 
 	// set defaults
@@ -1015,11 +1313,6 @@ func (p *Position) setupBoard(fen string) error {
 
 	// next player
 	if len(fenParts) >= 2 {
-		match = regexWorB.MatchString(fenParts[1])
-		if !match {
-			err := errors.New("fen next player contains invalid characters")
-			return err
-		}
 		switch fenParts[1] {
 		case "w":
 			p.nextPlayer = White
@@ -1029,43 +1322,67 @@ func (p *Position) setupBoard(fen string) error {
 				p.zobristKey ^= zobristBase.nextPlayer
 				p.nextHalfMoveNumber++
 			}
+		default:
+			return &FenError{Kind: BadSideToMove, Part: fenParts[1], Pos: partPos[1]}
 		}
 	}
 
 	// castling rights
 	if len(fenParts) >= 3 {
-		match = regexCastlingRights.MatchString(fenParts[2])
-		if !match {
-			err := errors.New("fen castling rights contains invalid characters")
-			return err
+		if !regexCastlingRights.MatchString(fenParts[2]) {
+			return &FenError{Kind: BadCastling, Part: fenParts[2], Pos: partPos[2]}
 		}
 		// are there  rights to be encoded?
+		// KQkq use the outermost rook on the side of the king (X-FEN).
+		// File letters give the rook file directly (Shredder-FEN / X-FEN)
+		// and there must be a rook of the color on that file on the
+		// back rank.
 		if fenParts[2] != "-" {
-			for _, c := range fenParts[2] {
-				switch string(c) {
-				case "K":
-					p.castlingRights.Add(CastlingWhiteOO)
-				case "Q":
-					p.castlingRights.Add(CastlingWhiteOOO)
-				case "k":
-					p.castlingRights.Add(CastlingBlackOO)
-				case "q":
-					p.castlingRights.Add(CastlingBlackOOO)
+			for i, c := range fenParts[2] {
+				color := White
+				if c >= 'a' {
+					color = Black
+					c -= 'a' - 'A'
+				}
+				kingFile := p.kingSquare[color].FileOf()
+				var cr CastlingRights
+				var rookFile File
+				switch {
+				case c == 'K':
+					cr, rookFile = CastlingWhiteOO, p.outermostRookFile(color, true)
+				case c == 'Q':
+					cr, rookFile = CastlingWhiteOOO, p.outermostRookFile(color, false)
+				default:
+					rookFile = File(c - 'A')
+					if p.board[SquareOf(rookFile, backRank(color))] != MakePiece(color, Rook) ||
+						p.kingSquare[color].RankOf() != backRank(color) || rookFile == kingFile {
+						return &FenError{Kind: BadCastling, Part: string(fenParts[2][i]), Pos: partPos[2] + i}
+					}
+					cr = CastlingWhiteOOO
+					if rookFile > kingFile {
+						cr = CastlingWhiteOO
+					}
+				}
+				if color == Black {
+					cr <<= 2
 				}
+				p.castlingRights.Add(cr)
+				p.castlingRookFile[castlingIndex(cr)] = rookFile
 			}
 		}
-		p.zobristKey ^= zobristBase.castlingRights[p.castlingRights]
 	}
+	p.setupCastlingMask()
+	// the castling rights are part of the key even if there are none
+	p.zobristKey ^= zobristBase.castlingRights[p.castlingRights]
 
 	// en passant
 	if len(fenParts) >= 4 {
-		match = regexEnPassant.MatchString(fenParts[3])
-		if !match {
-			err := errors.New("fen castling rights contains invalid characters")
-			return err
+		if !regexEnPassant.MatchString(fenParts[3]) {
+			return &FenError{Kind: BadEnPassant, Part: fenParts[3], Pos: partPos[3]}
 		}
 		if fenParts[3] != "-" {
 			p.enPassantSquare = MakeSquare(fenParts[3])
+			p.zobristKey ^= zobristBase.enPassantFile[p.enPassantSquare.FileOf()]
 		}
 	}
 
@@ -1074,7 +1391,7 @@ func (p *Position) setupBoard(fen string) error {
 		if number, e := strconv.Atoi(fenParts[4]); e == nil { // is number
 			p.halfMoveClock = number
 		} else {
-			return e
+			return &FenError{Kind: BadCounter, Part: fenParts[4], Pos: partPos[4]}
 		}
 	}
 
@@ -1087,7 +1404,7 @@ func (p *Position) setupBoard(fen string) error {
 			}
 			p.nextHalfMoveNumber = 2*moveNumber - (1 - int(p.nextPlayer))
 		} else {
-			return e
+			return &FenError{Kind: BadCounter, Part: fenParts[5], Pos: partPos[5]}
 		}
 	}
 
@@ -1134,13 +1451,32 @@ func (p *Position) OccupiedBb(c Color) Bitboard {
 // GamePhase is 24 at the start of the game (24 is also the max).
 // End games when no officers are left have a GamePhase value of 0.
 func (p *Position) GamePhase() int {
+	if p.gamePhase > GamePhaseMax {
+		return GamePhaseMax
+	}
 	return p.gamePhase
 }
 
+// ComputeGamePhaseFromScratch calculates the game phase value from all
+// pieces on the board without using the incrementally updated value.
+// Used to verify the incremental update.
+func (p *Position) ComputeGamePhaseFromScratch() int {
+	gamePhase := 0
+	for c := White; c <= Black; c++ {
+		for pt := King; pt < PtLength; pt++ {
+			gamePhase += p.piecesBb[c][pt].PopCount() * pt.GamePhaseValue()
+		}
+	}
+	if gamePhase > GamePhaseMax {
+		return GamePhaseMax
+	}
+	return gamePhase
+}
+
 // GamePhaseFactor returns a factor between 0 and 1 which reflects
 // the ratio between the actual game phase and the max game phase
 func (p *Position) GamePhaseFactor() float64 {
-	return float64(p.gamePhase) / GamePhaseMax
+	return float64(p.GamePhase()) / GamePhaseMax
 }
 
 // GetEnPassantSquare returns the en passant square or SqNone if not set
@@ -1148,6 +1484,13 @@ func (p *Position) GetEnPassantSquare() Square {
 	return p.enPassantSquare
 }
 
+// CastlingRookFile returns the file of the rook for the given single
+// castling right (e.g. CastlingWhiteOO). This is usually h or a but
+// might differ in positions set up from a Shredder-FEN or X-FEN.
+func (p *Position) CastlingRookFile(cr CastlingRights) File {
+	return p.castlingRookFile[castlingIndex(cr)]
+}
+
 // CastlingRights returns the castling rights instance of the position
 func (p *Position) CastlingRights() CastlingRights {
 	return p.castlingRights
@@ -1158,6 +1501,34 @@ func (p *Position) KingSquare(c Color) Square {
 	return p.kingSquare[c]
 }
 
+// KingZone returns a Bitboard of the king square and all adjacent
+// squares of the king of color c. The zone is updated whenever the
+// king moves and does not need to be recomputed.
+func (p *Position) KingZone(c Color) Bitboard {
+	return p.kingZone[c]
+}
+
+// GamePly returns the number of half moves played since the
+// start of the game
+func (p *Position) GamePly() int {
+	return p.nextHalfMoveNumber - 1
+}
+
+// MoveNumber returns the full move number of the position as
+// it is printed in a fen. It starts at 1 and is incremented
+// after each move of black.
+func (p *Position) MoveNumber() int {
+	return (p.nextHalfMoveNumber + 1) / 2
+}
+
+// Ply returns the number of half moves made on this position
+// since it has been created from the start position or a fen.
+// In contrast to GamePly() this does not include the moves
+// before the fen given by its move number.
+func (p *Position) Ply() int {
+	return p.historyCounter
+}
+
 // HalfMoveClock returns the positions half move clock
 func (p *Position) HalfMoveClock() int {
 	return p.halfMoveClock
@@ -1169,6 +1540,12 @@ func (p *Position) Material(c Color) Value {
 	return p.material[c]
 }
 
+// Count returns the number of pieces of the given color and
+// piece type on this position
+func (p *Position) Count(c Color, pt PieceType) int {
+	return p.pieceCount[c][pt]
+}
+
 // MaterialNonPawn returns the non pawn material value for
 // given color
 func (p *Position) MaterialNonPawn(c Color) Value {
@@ -1198,6 +1575,15 @@ func (p *Position) LastMove() Move {
 	return p.history[p.historyCounter-1].move
 }
 
+// PreviousMove returns the move made the given number of plies ago
+// (PreviousMove(1) is the last move) or MoveNone if there is no such move.
+func (p *Position) PreviousMove(plies int) Move {
+	if plies < 1 || p.historyCounter < plies {
+		return MoveNone
+	}
+	return p.history[p.historyCounter-plies].move
+}
+
 // LastCapturedPiece returns the captured piece of the the last
 // move made on the position or MoveNone if the move was
 // non-capturing or the position has no history of earlier moves.
@@ -27,10 +27,12 @@
 package position
 
 import (
+	"errors"
 	"fmt"
 	"os"
 	"path"
 	"runtime"
+	"strings"
 	"testing"
 	"time"
 
@@ -114,6 +116,94 @@ func TestPositionCreation(t *testing.T) {
 	assert.Equal(t, fen, p.StringFen())
 }
 
+func TestPositionCastlingRookFiles(t *testing.T) {
+	// Shredder-FEN
+	p, err := NewPositionFen("r3k2r/8/8/8/8/8/8/R3K2R w HAha - 0 1")
+	assert.NoError(t, err)
+	assert.Equal(t, CastlingAny, p.castlingRights)
+	assert.Equal(t, "r3k2r/8/8/8/8/8/8/R3K2R w KQkq - 0 1", p.StringFen())
+	p2, _ := NewPositionFen("r3k2r/8/8/8/8/8/8/R3K2R w KQkq - 0 1")
+	assert.Equal(t, p2.ZobristKey(), p.ZobristKey())
+
+	// X-FEN / mixed
+	p, err = NewPositionFen("r3k2r/8/8/8/8/8/8/R3K2R b Kha - 0 1")
+	assert.NoError(t, err)
+	assert.Equal(t, CastlingWhiteOO|CastlingBlack, p.castlingRights)
+	assert.Equal(t, "r3k2r/8/8/8/8/8/8/R3K2R b Kkq - 0 1", p.StringFen())
+
+	// rook on the g file inside the rook on the h file
+	p, err = NewPositionFen("1r2k1rr/8/8/8/8/8/8/1R2K1RR w GBgb - 0 1")
+	assert.NoError(t, err)
+	assert.Equal(t, CastlingAny, p.castlingRights)
+	assert.Equal(t, FileG, p.CastlingRookFile(CastlingWhiteOO))
+	assert.Equal(t, FileB, p.CastlingRookFile(CastlingWhiteOOO))
+	assert.Equal(t, FileG, p.CastlingRookFile(CastlingBlackOO))
+	assert.Equal(t, FileB, p.CastlingRookFile(CastlingBlackOOO))
+	// X-FEN: letters only where the rook is not the outermost one
+	assert.Equal(t, "1r2k1rr/8/8/8/8/8/8/1R2K1RR w GQgq - 0 1", p.StringFen())
+	p2, _ = NewPositionFen(p.StringFen())
+	assert.Equal(t, p.StringFen(), p2.StringFen())
+	assert.Equal(t, p.castlingRookFile, p2.castlingRookFile)
+	// moving the h rook keeps the right, moving the g rook loses it
+	p.DoMove(CreateMove(SqH1, SqH2, Normal, PtNone))
+	assert.Equal(t, "1r2k1rr/8/8/8/8/8/7R/1R2K1R1 b KQgq - 1 1", p.StringFen())
+	p.UndoMove()
+	p.DoMove(CreateMove(SqG1, SqG2, Normal, PtNone))
+	assert.Equal(t, "1r2k1rr/8/8/8/8/8/6R1/1R2K2R b Qgq - 1 1", p.StringFen())
+
+	// KQkq infer the outermost rooks
+	p, err = NewPositionFen("1r2k1rr/8/8/8/8/8/8/1R2K1RR w KQkq - 0 1")
+	assert.NoError(t, err)
+	assert.Equal(t, FileH, p.CastlingRookFile(CastlingWhiteOO))
+	assert.Equal(t, FileB, p.CastlingRookFile(CastlingWhiteOOO))
+	assert.Equal(t, "1r2k1rr/8/8/8/8/8/8/1R2K1RR w KQkq - 0 1", p.StringFen())
+
+	// Chess960 start position with the king on b1
+	p, err = NewPositionFen("rkrbbnnq/pppppppp/8/8/8/8/PPPPPPPP/RKRBBNNQ w CAca - 0 1")
+	assert.NoError(t, err)
+	assert.Equal(t, FileC, p.CastlingRookFile(CastlingWhiteOO))
+	assert.Equal(t, FileA, p.CastlingRookFile(CastlingWhiteOOO))
+	assert.Equal(t, "rkrbbnnq/pppppppp/8/8/8/8/PPPPPPPP/RKRBBNNQ w KQkq - 0 1", p.StringFen())
+
+	// a rook file letter needs a rook on the back rank
+	_, err = NewPositionFen("r3k2r/8/8/8/8/8/8/R3K2R w C - 0 1")
+	assert.Error(t, err)
+
+	// invalid characters
+	_, err = NewPositionFen("r3k2r/8/8/8/8/8/8/R3K2R w KQkqX - 0 1")
+	assert.Error(t, err)
+}
+
+func TestPositionFenError(t *testing.T) {
+	tests := []struct {
+		fen  string
+		kind FenErrorKind
+		part string
+		pos  int
+	}{
+		{"rnbqkbnr/pppppppp/8/8/8/8/PPPPPPPP/RNBQKXNR w KQkq - 0 1", InvalidChar, "X", 40},
+		{"rnbqkbnr/pppppppp/8/8/8/8/PPPPPPPP w KQkq - 0 1", BadRankCount, "rnbqkbnr/pppppppp/8/8/8/8/PPPPPPPP", 34},
+		{"rnbqkbnr/pppppppp/9/8/8/8/PPPPPPPP/RNBQKBNR w KQkq - 0 1", InvalidChar, "9", 18},
+		{"rnbqkbnr/ppppppp/8/8/8/8/PPPPPPPP/RNBQKBNR w KQkq - 0 1", BadRankCount, "rnbqkbnr/ppppppp/8/8/8/8/PPPPPPPP/RNBQKBNR", 16},
+		{"rnbqkbnr/ppppppppp/8/8/8/8/PPPPPPPP/RNBQKBNR w KQkq - 0 1", BadRankCount, "rnbqkbnr/ppppppppp/8/8/8/8/PPPPPPPP/RNBQKBNR", 17},
+		{"rnbqkbnr/pppppppp/8/8/8/8/PPPPPPPP/RNBQKBNR x KQkq - 0 1", BadSideToMove, "x", 44},
+		{"rnbqkbnr/pppppppp/8/8/8/8/PPPPPPPP/RNBQKBNR w KQkqX - 0 1", BadCastling, "KQkqX", 46},
+		{"rnbqkbnr/pppppppp/8/8/8/8/PPPPPPPP/RNBQKBNR w BHbh - 0 1", BadCastling, "B", 46},
+		{"rnbqkbnr/pppppppp/8/8/8/8/PPPPPPPP/RNBQKBNR w KQkq e9 0 1", BadEnPassant, "e9", 51},
+		{"rnbqkbnr/pppppppp/8/8/8/8/PPPPPPPP/RNBQKBNR w KQkq - x 1", BadCounter, "x", 53},
+		{"rnbqkbnr/pppppppp/8/8/8/8/PPPPPPPP/RNBQKBNR w KQkq - 0 y", BadCounter, "y", 55},
+	}
+	for _, test := range tests {
+		_, err := NewPositionFen(test.fen)
+		var fenErr *FenError
+		if assert.True(t, errors.As(err, &fenErr), test.fen) {
+			assert.Equal(t, test.kind, fenErr.Kind, test.fen)
+			assert.Equal(t, test.part, fenErr.Part, test.fen)
+			assert.Equal(t, test.pos, fenErr.Pos, test.fen)
+		}
+	}
+}
+
 func TestPositionEquality(t *testing.T) {
 
 	// equal
@@ -135,6 +225,24 @@ func TestPositionEquality(t *testing.T) {
 	assert.Equal(t, *p1, *p3)
 }
 
+func TestSetZobristSeed(t *testing.T) {
+	defer SetZobristSeed(DefaultZobristSeed)
+	fen := "r3k2r/1ppn3p/2q1q1n1/8/2q1Pp2/B5R1/p1p2PPP/1R4K1 b kq e3"
+
+	defaultKey := NewPosition(fen).ZobristKey()
+	SetZobristSeed(0)
+	assert.Equal(t, defaultKey, NewPosition(fen).ZobristKey())
+
+	SetZobristSeed(4711)
+	key := NewPosition(fen).ZobristKey()
+	assert.NotEqual(t, defaultKey, key)
+	SetZobristSeed(4711)
+	assert.Equal(t, key, NewPosition(fen).ZobristKey())
+
+	SetZobristSeed(DefaultZobristSeed)
+	assert.Equal(t, defaultKey, NewPosition(fen).ZobristKey())
+}
+
 func TestPosition_DoUndoMove(t *testing.T) {
 
 	p := NewPosition()
@@ -153,6 +261,35 @@ func TestPosition_DoUndoMove(t *testing.T) {
 	assert.Equal(t, startZobrist, p.ZobristKey())
 }
 
+func TestPositionMoveNumberAndPly(t *testing.T) {
+	p := NewPosition()
+	assert.Equal(t, 1, p.MoveNumber())
+	assert.Equal(t, 0, p.Ply())
+	p.DoMove(CreateMove(SqE2, SqE4, Normal, PtNone))
+	assert.Equal(t, 1, p.MoveNumber())
+	assert.Equal(t, 1, p.Ply())
+	p.DoMove(CreateMove(SqE7, SqE5, Normal, PtNone))
+	assert.Equal(t, 2, p.MoveNumber())
+	assert.Equal(t, 2, p.Ply())
+	assert.True(t, strings.HasSuffix(p.StringFen(), " 2"))
+	p.UndoMove()
+	assert.Equal(t, 1, p.MoveNumber())
+	assert.Equal(t, 1, p.Ply())
+
+	// the move number is taken from the fen - the ply starts at 0
+	p = NewPosition("r3k2r/1ppn3p/2q1q1n1/4P3/2q1Pp2/B5R1/pbp2PPP/1R4K1 b kq e3 0 10")
+	assert.Equal(t, 10, p.MoveNumber())
+	assert.Equal(t, 0, p.Ply())
+	assert.Equal(t, 19, p.GamePly())
+	p.DoMove(CreateMove(SqH7, SqH6, Normal, PtNone))
+	assert.Equal(t, 11, p.MoveNumber())
+	assert.Equal(t, 1, p.Ply())
+	assert.True(t, strings.HasSuffix(p.StringFen(), " 11"))
+	p.DoMove(CreateMove(SqA3, SqB2, Normal, PtNone))
+	assert.Equal(t, 11, p.MoveNumber())
+	assert.Equal(t, 2, p.Ply())
+}
+
 func TestPosition_DoMoveNormal(t *testing.T) {
 
 	var fen string
@@ -237,6 +374,93 @@ func TestPosition_DoMovePromotion(t *testing.T) {
 	assert.Equal(t, "r3k2r/1ppn3p/2q1q1n1/8/2q1Pp2/B5R1/2p2PPP/1r4K1 w kq - 0 2", position.StringFen())
 }
 
+func TestPositionPieceCount(t *testing.T) {
+	assertCounts := func(p *Position) {
+		for c := White; c <= Black; c++ {
+			for pt := King; pt < PtLength; pt++ {
+				assert.Equal(t, p.PiecesBb(c, pt).PopCount(), p.Count(c, pt), "%s %s", c.String(), pt.String())
+			}
+		}
+	}
+
+	position, _ := NewPositionFen("r3k2r/1ppn3p/2q1q1n1/8/2q1Pp2/B5R1/p1p2PPP/1R4K1 b kq e3")
+	assertCounts(position)
+	assert.Equal(t, 6, position.Count(Black, Pawn))
+	assert.Equal(t, 3, position.Count(Black, Queen))
+
+	moves := []Move{
+		CreateMove(SqF4, SqE3, EnPassant, PtNone),
+		CreateMove(SqG3, SqE3, Normal, PtNone),
+		CreateMove(SqA2, SqB1, Promotion, Rook),
+		CreateMove(SqH2, SqH3, Normal, PtNone),
+		CreateMove(SqE8, SqG8, Castling, PtNone),
+	}
+	for _, m := range moves {
+		position.DoMove(m)
+		assertCounts(position)
+	}
+	assert.Equal(t, 3, position.Count(White, Pawn))
+	assert.Equal(t, 1, position.Count(White, Rook))
+	assert.Equal(t, 4, position.Count(Black, Pawn))
+	assert.Equal(t, 3, position.Count(Black, Rook))
+	for range moves {
+		position.UndoMove()
+		assertCounts(position)
+	}
+	assert.Equal(t, "r3k2r/1ppn3p/2q1q1n1/8/2q1Pp2/B5R1/p1p2PPP/1R4K1 b kq e3 0 1", position.StringFen())
+}
+
+func TestPositionGamePhase(t *testing.T) {
+	p := NewPosition()
+	assert.Equal(t, GamePhaseMax, p.GamePhase())
+	assert.Equal(t, p.GamePhase(), p.ComputeGamePhaseFromScratch())
+
+	// more officers than at the start of the game - the game phase must
+	// not drift when promoting and capturing beyond the max value
+	p = NewPosition("rnbqkbnr/pPpppppp/8/8/8/8/PpPPPPPP/RNBQKBNR w KQkq -")
+	moves := []Move{
+		CreateMove(SqB7, SqA8, Promotion, Queen),
+		CreateMove(SqB2, SqA1, Promotion, Queen),
+		CreateMove(SqA8, SqB8, Normal, PtNone),
+		CreateMove(SqA1, SqB1, Normal, PtNone),
+		CreateMove(SqB8, SqC8, Normal, PtNone),
+		CreateMove(SqB1, SqC1, Normal, PtNone),
+		CreateMove(SqC8, SqD8, Normal, PtNone),
+		CreateMove(SqC1, SqD1, Normal, PtNone),
+	}
+	for _, m := range moves {
+		p.DoMove(m)
+		assert.Equal(t, p.ComputeGamePhaseFromScratch(), p.GamePhase(), m.StringUci())
+	}
+	assert.Equal(t, 16, p.GamePhase())
+	for range moves {
+		p.UndoMove()
+		assert.Equal(t, p.ComputeGamePhaseFromScratch(), p.GamePhase())
+	}
+	assert.Equal(t, GamePhaseMax, p.GamePhase())
+}
+
+func TestPositionAttackersByValue(t *testing.T) {
+	p := NewPosition("3q3k/5B2/2p2n2/3p4/4P3/2N5/Q7/3R2K1 w - -")
+
+	assert.Equal(t, []Square{SqE4, SqC3, SqF7, SqD1, SqA2}, p.AttackersByValue(SqD5, White))
+	assert.Equal(t, []Square{SqC6, SqF6, SqD8}, p.AttackersByValue(SqD5, Black))
+	assert.Equal(t, []Square{SqD1, SqG1}, p.AttackersByValue(SqF1, White))
+	assert.Empty(t, p.AttackersByValue(SqH4, Black))
+
+	attackers := p.AttackersTo(SqD5, White)
+	assert.Equal(t, 5, attackers.PopCount())
+	bb, pt := p.GetSmallestPieceBitboard(attackers, White)
+	assert.Equal(t, SqE4.Bb(), bb)
+	assert.Equal(t, Pawn, pt)
+	bb, pt = p.GetSmallestPieceBitboard(attackers&^SqE4.Bb()&^SqC3.Bb(), White)
+	assert.Equal(t, SqF7.Bb(), bb)
+	assert.Equal(t, Bishop, pt)
+	bb, pt = p.GetSmallestPieceBitboard(attackers, Black)
+	assert.Equal(t, BbZero, bb)
+	assert.Equal(t, PtNone, pt)
+}
+
 func TestPosition_IsAttacked(t *testing.T) {
 
 	var fen string
@@ -587,14 +811,207 @@ func TestPosition_CheckInsufficientMaterial(t *testing.T) {
 	assert.False(t, position.HasInsufficientMaterial())
 	position, _ = NewPositionFen("8/8/3bk1n1/8/8/8/4K3/4N3 w - -")
 	assert.True(t, position.HasInsufficientMaterial())
+	// bishop and knight against the bare king can force a mate
+	position, _ = NewPositionFen("8/8/3bk1n1/8/8/8/4K3/8 w - -")
+	assert.False(t, position.HasInsufficientMaterial())
+	position, _ = NewPositionFen("4k3/8/8/8/8/8/8/1NB1K3 w - -")
+	assert.False(t, position.HasInsufficientMaterial())
+
+}
 
+func TestPreviousMove(t *testing.T) {
+	p := NewPosition()
+	assert.Equal(t, MoveNone, p.PreviousMove(1))
+	e2e4 := CreateMove(SqE2, SqE4, Normal, PtNone)
+	e7e5 := CreateMove(SqE7, SqE5, Normal, PtNone)
+	p.DoMove(e2e4)
+	p.DoMove(e7e5)
+	assert.Equal(t, p.LastMove(), p.PreviousMove(1))
+	assert.Equal(t, e7e5, p.PreviousMove(1))
+	assert.Equal(t, e2e4, p.PreviousMove(2))
+	assert.Equal(t, MoveNone, p.PreviousMove(3))
+	assert.Equal(t, MoveNone, p.PreviousMove(0))
+	p.UndoMove()
+	assert.Equal(t, e2e4, p.PreviousMove(1))
+}
+
+func TestUndoMoves(t *testing.T) {
+	p := NewPosition("r3k2r/1ppn3p/2q1q1n1/8/2q1Pp2/6R1/p1p2PPP/1R4K1 b kq e3")
+	fen := p.StringFen()
+	key := p.ZobristKey()
+
+	// nothing to undo
+	assert.EqualValues(t, 0, p.UndoMoves(1))
+	assert.EqualValues(t, 0, p.UndoAll())
+	assert.EqualValues(t, fen, p.StringFen())
+
+	p.DoMove(CreateMove(SqF4, SqE3, EnPassant, PtNone))
+	afterFirst := p.StringFen()
+	p.DoMove(CreateMove(SqF2, SqE3, Normal, PtNone))
+	p.DoMove(CreateMove(SqE8, SqC8, Castling, PtNone))
+	p.DoNullMove()
+	p.DoMove(CreateMove(SqA2, SqB1, Promotion, Queen))
+	p.DoMove(CreateMove(SqG3, SqG6, Normal, PtNone))
+
+	// undo including a null move
+	assert.EqualValues(t, 5, p.UndoMoves(5))
+	assert.EqualValues(t, afterFirst, p.StringFen())
+	p.DoMove(CreateMove(SqF2, SqE3, Normal, PtNone))
+
+	// negative counts undo nothing
+	secondFen := p.StringFen()
+	assert.EqualValues(t, 0, p.UndoMoves(-3))
+	assert.EqualValues(t, secondFen, p.StringFen())
+
+	// can't undo past the start
+	assert.EqualValues(t, 2, p.UndoMoves(10))
+	assert.EqualValues(t, fen, p.StringFen())
+	assert.EqualValues(t, key, p.ZobristKey())
+
+	p.DoMove(CreateMove(SqF4, SqE3, EnPassant, PtNone))
+	p.DoMove(CreateMove(SqF2, SqE3, Normal, PtNone))
+	p.DoMove(CreateMove(SqE8, SqC8, Castling, PtNone))
+	assert.EqualValues(t, 3, p.UndoAll())
+	assert.EqualValues(t, fen, p.StringFen())
+	assert.EqualValues(t, key, p.ZobristKey())
+	assert.EqualValues(t, MoveNone, p.LastMove())
+}
+
+func TestKingZone(t *testing.T) {
+	p := NewPosition("r3k2r/8/8/8/8/8/8/R3K2R w KQkq -")
+	whiteZone := GetPseudoAttacks(King, SqE1) | SqE1.Bb()
+	blackZone := GetPseudoAttacks(King, SqE8) | SqE8.Bb()
+	assert.EqualValues(t, whiteZone, p.KingZone(White))
+	assert.EqualValues(t, blackZone, p.KingZone(Black))
+	assert.EqualValues(t, 6, p.KingZone(White).PopCount())
+
+	// normal king move
+	p.DoMove(CreateMove(SqE1, SqE2, Normal, PtNone))
+	assert.EqualValues(t, GetPseudoAttacks(King, SqE2)|SqE2.Bb(), p.KingZone(White))
+	assert.EqualValues(t, 9, p.KingZone(White).PopCount())
+	assert.EqualValues(t, blackZone, p.KingZone(Black))
+
+	// castling
+	p.DoMove(CreateMove(SqE8, SqG8, Castling, PtNone))
+	assert.EqualValues(t, GetPseudoAttacks(King, SqG8)|SqG8.Bb(), p.KingZone(Black))
+
+	// undo restores the zones
+	p.UndoMove()
+	assert.EqualValues(t, blackZone, p.KingZone(Black))
+	p.UndoMove()
+	assert.EqualValues(t, whiteZone, p.KingZone(White))
+}
+
+func TestIsQuiet(t *testing.T) {
+	// quiet middle game position
+	p := NewPosition("r1bqk2r/pppp1ppp/2n2n2/2b1p3/2B1P3/2NP1N2/PPP2PPP/R1BQK2R w KQkq -")
+	assert.True(t, p.IsQuiet())
+	assert.EqualValues(t, flagTrue, p.isQuietFlag)
+
+	// hanging queen attacked by a knight
+	p = NewPosition("rnb1kbnr/pppp1ppp/8/4p1q1/4P3/5N2/PPPP1PPP/RNBQKB1R w KQkq -")
+	assert.False(t, p.IsQuiet())
+	assert.EqualValues(t, flagFalse, p.isQuietFlag)
+
+	// only captures of the next player count
+	p = NewPosition("rnb1kbnr/pppp1ppp/8/4p1q1/4P3/5N2/PPPP1PPP/RNBQKB1R b KQkq -")
+	assert.True(t, p.IsQuiet())
+
+	// undefended and defended pawns
+	assert.False(t, NewPosition("4k3/8/8/3p4/4P3/8/8/4K3 w - -").IsQuiet())
+	assert.True(t, NewPosition("4k3/8/2p5/3p4/4P3/8/8/4K3 w - -").IsQuiet())
+
+	// check
+	assert.False(t, NewPosition("rnbqkbnr/ppp1pppp/8/1B1p4/4P3/8/PPPP1PPP/RNBQK1NR b KQkq -").IsQuiet())
+
+	// cached value is reset by a move and restored by undo
+	p = NewPosition("r1bqk2r/pppp1ppp/2n2n2/2b1p3/2B1P3/2NP1N2/PPP2PPP/R1BQK2R w KQkq -")
+	assert.True(t, p.IsQuiet())
+	p.DoMove(CreateMove(SqC4, SqF7, Normal, PtNone)) // check
+	assert.EqualValues(t, flagTBD, p.isQuietFlag)
+	assert.False(t, p.IsQuiet())
+	p.UndoMove()
+	assert.EqualValues(t, flagTrue, p.isQuietFlag)
+	p.DoNullMove()
+	assert.EqualValues(t, flagTBD, p.isQuietFlag)
+	p.UndoNullMove()
+	assert.True(t, p.IsQuiet())
+}
+
+func TestThreats(t *testing.T) {
+	// knight attacks the undefended rook
+	p := NewPosition("4k3/pp6/8/3r4/8/4N3/PP6/4K3 w - -")
+	assert.EqualValues(t, Knight, p.LowestAttacker(SqD5, White))
+	hanging, threatened := p.Threats(p.OccupiedBb(Black)&^p.PiecesBb(Black, King), White)
+	assert.EqualValues(t, SqD5.Bb(), hanging)
+	assert.EqualValues(t, BbZero, threatened)
+
+	// pawn attacks the defended knight
+	p = NewPosition("4k3/8/4p3/3n4/4P3/8/8/4K3 w - -")
+	assert.EqualValues(t, Pawn, p.LowestAttacker(SqD5, White))
+	assert.EqualValues(t, PtNone, p.LowestAttacker(SqE4, Black))
+	hanging, threatened = p.Threats(p.OccupiedBb(Black)&^p.PiecesBb(Black, King), White)
+	assert.EqualValues(t, BbZero, hanging)
+	assert.EqualValues(t, SqD5.Bb(), threatened)
+}
+
+func TestInsufficientMaterialPreCheck(t *testing.T) {
+	tests := []struct {
+		fen      string
+		expected bool
+	}{
+		// at most five pieces are checked in detail
+		{"8/8/2n1kn2/8/8/8/4K3/4B3 w - -", true},
+		{"8/8/3bk1b1/8/8/8/4K3/4B3 w - -", true},
+		{"8/8/3bk1n1/8/8/8/4K3/4N3 w - -", true},
+		{"8/8/3bk1b1/8/8/8/4K3/4N3 w - -", false},
+		// pawns, rooks and queens can always mate
+		{"8/3k4/8/8/8/8/3PK3/8 w - -", false},
+		{"8/3k4/8/8/8/8/3RK3/8 w - -", false},
+		{"8/3k4/8/8/8/8/3QK3/8 w - -", false},
+		{"8/2nk4/8/8/8/8/3PK3/8 w - -", false},
+		// more than five pieces are never insufficient material
+		{"8/8/2n1kn2/8/8/8/3NK3/4B3 w - -", false},
+		{"8/8/2b1kb2/8/8/8/3BK3/4B3 w - -", false},
+		{"8/8/2n1kn2/8/8/8/3NKN2/8 w - -", false},
+		{"8/8/2b1k3/8/8/8/3NKN2/4N3 w - -", false},
+	}
+	for _, test := range tests {
+		p, err := NewPositionFen(test.fen)
+		if assert.NoError(t, err, test.fen) {
+			assert.Equal(t, test.expected, p.HasInsufficientMaterial(), test.fen)
+		}
+	}
+}
+
+func BenchmarkHasInsufficientMaterial(b *testing.B) {
+	p := NewPosition("r3k2r/1ppn3p/2q1q1n1/8/2q1Pp2/B5R1/p1p2PPP/1R4K1 b kq e3")
+	for i := 0; i < b.N; i++ {
+		p.HasInsufficientMaterial()
+	}
+}
+
+func TestMirrorVertical(t *testing.T) {
+	p := NewPosition()
+	m := p.MirrorVertical()
+	assert.EqualValues(t, "rnbqkbnr/pppppppp/8/8/8/8/PPPPPPPP/RNBQKBNR b KQkq - 0 1", m.StringFen())
+
+	p = NewPosition("r3k3/1p6/8/3Pp3/8/8/8/4K2R w Kq e6 0 2")
+	m = p.MirrorVertical()
+	assert.EqualValues(t, "4k2r/8/8/8/3pP3/8/1P6/R3K3 b Qk e3 0 2", m.StringFen())
+	assert.EqualValues(t, p.Material(White), m.Material(Black))
+	assert.EqualValues(t, p.Material(Black), m.Material(White))
+
+	// mirroring twice gives the original position
+	assert.EqualValues(t, p.StringFen(), m.MirrorVertical().StringFen())
+	assert.EqualValues(t, p.ZobristKey(), m.MirrorVertical().ZobristKey())
 }
 
 // DoMove/UndoMove took 2.387.592.600 ns for 10.000.000 iterations with 5 do/undo pairs
 // DoMove/UndoMove took 47 ns per do/undo pair
 // Positions per sec 20.941.596 pps
 //
-//noinspection GoUnhandledErrorResult
+// noinspection GoUnhandledErrorResult
 func TestTimingDoUndo(t *testing.T) {
 	// defer profile.Start(profile.CPUProfile, profile.ProfilePath("../bin")).Stop()
 
@@ -40,9 +40,26 @@ type zobrist struct {
 
 var zobristBase = zobrist{}
 
-func initZobrist() {
+// DefaultZobristSeed is the fixed seed for the zobrist random numbers
+// which makes keys identical across runs
+const DefaultZobristSeed int64 = 1070372
+
+// SetZobristSeed re-initializes the zobrist random numbers with the given
+// seed (0 uses DefaultZobristSeed).
+// This must be called before any Position is created as keys of already
+// existing positions would become invalid. It also must not be called
+// while a search is running as transposition table entries would not
+// match any more.
+func SetZobristSeed(seed int64) {
+	if seed == 0 {
+		seed = DefaultZobristSeed
+	}
+	initZobrist(uint64(seed))
+}
+
+func initZobrist(seed uint64) {
 	// Zobrist Key initialization
-	r := NewRandom(1070372)
+	r := NewRandom(seed)
 	for pc := PieceNone; pc < PieceLength; pc++ {
 		for sq := SqA1; sq <= SqH8; sq++ {
 			zobristBase.pieces[pc][sq] = Key(r.Rand64())
@@ -56,3 +73,27 @@ func initZobrist() {
 	}
 	zobristBase.nextPlayer = Key(r.Rand64())
 }
+
+// ZobristOf computes the zobrist key of the given position from scratch.
+// The key is the xor of the random numbers for each piece on its square,
+// for the castling rights (also for no castling rights), for the file of
+// the en passant square if there is one and for black as the next player.
+// Move numbers and the half move clock are not part of the key.
+// This always equals the incrementally updated key of ZobristKey() and can
+// be used to verify it or by tools hashing positions externally.
+func ZobristOf(p *Position) Key {
+	var key Key
+	for sq := SqA1; sq <= SqH8; sq++ {
+		if pc := p.board[sq]; pc != PieceNone {
+			key ^= zobristBase.pieces[pc][sq]
+		}
+	}
+	key ^= zobristBase.castlingRights[p.castlingRights]
+	if p.enPassantSquare != SqNone {
+		key ^= zobristBase.enPassantFile[p.enPassantSquare.FileOf()]
+	}
+	if p.nextPlayer == Black {
+		key ^= zobristBase.nextPlayer
+	}
+	return key
+}
@@ -25,3 +25,52 @@
 //
 
 package position
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+
+	. "github.com/frankkopp/FrankyGo/internal/types"
+)
+
+func TestZobristOf(t *testing.T) {
+	fens := []string{
+		StartFen,
+		"r3k2r/p1ppqpb1/bn2pnp1/3PN3/1p2P3/2N2Q1p/PPPBBPPP/R3K2R w KQkq -",
+		"rnbqkbnr/ppp1pppp/8/8/3pP3/8/PPPP1PPP/RNBQKBNR b KQkq e3 0 3",
+		"8/k1b5/P4p2/1Pp2p1p/K1P2P1P/8/3B4/8 w - c6",
+		"6k1/P7/8/8/8/8/8/3K4 w",
+	}
+	for _, fen := range fens {
+		p := NewPosition(fen)
+		assert.Equal(t, p.ZobristKey(), ZobristOf(p), fen)
+	}
+
+	// incrementally updated keys match after all kinds of moves
+	p := NewPosition("r3k2r/1ppqppp1/8/3P4/8/8/pPPQPPP1/4K2R w Kkq -")
+	moves := []Move{
+		CreateMove(SqE1, SqG1, Castling, PtNone),
+		CreateMove(SqC7, SqC5, Normal, PtNone),
+		CreateMove(SqD5, SqC6, EnPassant, PtNone),
+		CreateMove(SqA2, SqA1, Promotion, Queen),
+		CreateMove(SqF1, SqA1, Normal, PtNone),
+		CreateMove(SqE8, SqC8, Castling, PtNone),
+	}
+	for _, m := range moves {
+		p.DoMove(m)
+		assert.Equal(t, p.ZobristKey(), ZobristOf(p), m.StringUci())
+	}
+	p.DoNullMove()
+	assert.Equal(t, p.ZobristKey(), ZobristOf(p))
+	p.UndoNullMove()
+	for range moves {
+		p.UndoMove()
+		assert.Equal(t, p.ZobristKey(), ZobristOf(p))
+	}
+
+	// a position from a fen has the same key as after the moves leading to it
+	p = NewPosition()
+	p.DoMove(CreateMove(SqE2, SqE4, Normal, PtNone))
+	assert.Equal(t, NewPosition("rnbqkbnr/pppppppp/8/8/4P3/8/PPPP1PPP/RNBQKBNR b KQkq e3 0 1").ZobristKey(), p.ZobristKey())
+}
@@ -36,6 +36,8 @@ import (
 
 	"github.com/op/go-logging"
 
+	"github.com/frankkopp/FrankyGo/internal/assert"
+	"github.com/frankkopp/FrankyGo/internal/attacks"
 	. "github.com/frankkopp/FrankyGo/internal/config"
 	"github.com/frankkopp/FrankyGo/internal/movegen"
 	"github.com/frankkopp/FrankyGo/internal/moveslice"
@@ -71,34 +73,51 @@ func (s *Search) rootSearch(p *position.Position, depth int, alpha Value, beta V
 	// prepare root node search
 	bestNodeValue := ValueNA
 	var value Value
+	winning := s.clearlyWinning()
 
 	// ///////////////////////////////////////////////////////
 	// MOVE LOOP
 	for i, m := range *s.rootMoves {
 
+		// Optional noise to vary the choice between nearly equal root
+		// moves and an optional penalty for moves allowing a repetition
+		// when winning. The search window is shifted by this bias so that
+		// the value of the move plus its bias is correctly bounded.
+		// Infinite bounds are not shifted.
+		bias := s.rootMoveNoise(m) - s.repetitionPenalty(m, winning)
+		moveAlpha, moveBeta := alpha, beta
+		if alpha > ValueMin {
+			moveAlpha -= bias
+		}
+		if beta < ValueMax {
+			moveBeta -= bias
+		}
+
+		nodesBefore := s.nodesVisited
 		p.DoMove(m)
 		s.nodesVisited++
+		s.statistics.PerDepth[depth].Nodes++
 		s.statistics.CurrentVariation.PushBack(m)
 		s.statistics.CurrentRootMoveIndex = i
 		s.statistics.CurrentRootMove = m
 
 		// check repetition and 50 moves
 		if s.checkDrawRepAnd50(p, 2) {
-			value = ValueDraw
+			value = -s.drawValue(p)
 		} else {
 			// ///////////////////////////////////////////////////////////////////
 			// PVS
 			// First move in a node is an assumed PV and searched with full search window
 			if !Settings.Search.UsePVS || i == 0 {
-				value = -s.search(p, depth-1, 1, -beta, -alpha, true, true)
+				value = -s.search(p, depth-1, 1, -moveBeta, -moveAlpha, true, true, false)
 			} else {
 				// Null window search after the initial PV search.
-				value = -s.search(p, depth-1, 1, -alpha-1, -alpha, false, true)
+				value = -s.search(p, depth-1, 1, -moveAlpha-1, -moveAlpha, false, true, true)
 				// If this move improved alpha without exceeding beta we do a proper full window
 				// search to get an accurate score.
-				if value > alpha && value < beta && !s.stopConditions() {
+				if value > moveAlpha && value < moveBeta && !s.stopConditions() {
 					s.statistics.RootPvsResearches++
-					value = -s.search(p, depth-1, 1, -beta, -alpha, true, true)
+					value = -s.search(p, depth-1, 1, -moveBeta, -moveAlpha, true, true, false)
 				}
 			}
 			// ///////////////////////////////////////////////////////////////////
@@ -106,11 +125,25 @@ func (s *Search) rootSearch(p *position.Position, depth int, alpha Value, beta V
 
 		s.statistics.CurrentVariation.PopBack()
 		p.UndoMove()
+		s.rootMoveNodes[m.MoveOf()] += s.nodesVisited - nodesBefore
+
+		// the bias is only used to select and sort the root moves -
+		// the pv stores the unbiased value to be reported
+		unbiased := value
+		if !value.IsCheckMateValue() {
+			value += bias
+		}
 
-		// we want to do at least one complete search with depth 1
-		// After that we can stop any time - any new best moves will
-		// have been stored in pv[0]
-		if s.stopConditions() && depth > 1 {
+		// After the first root move of the first iteration has been
+		// searched we can stop any time - any new best moves will have
+		// been stored in pv[0] and the value of an interrupted search
+		// is not valid. If we are stopped even before the first root
+		// move has been searched completely (e.g. stop right after go)
+		// we still need a legal best move and use the first root move.
+		if s.stopConditions() {
+			if s.pv[0].Len() == 0 {
+				s.pv[0].PushBack(m.SetValue(ValueNA))
+			}
 			return 0
 		}
 
@@ -123,12 +156,15 @@ func (s *Search) rootSearch(p *position.Position, depth int, alpha Value, beta V
 		// this is always the case.
 		if value > bestNodeValue {
 			bestNodeValue = value
+			s.rootBestBias = value - unbiased
 			// we have a new best move and pv[0][0] - store pv+1 tp pv
-			savePV(m, s.pv[1], s.pv[0])
+			pvMove := m
+			savePV(pvMove.SetValue(unbiased), s.pv[1], s.pv[0])
 			if value > alpha {
 				// fail high in root only when using aspiration search
 				if value >= beta {
 					s.statistics.BetaCuts++
+					s.statistics.PerDepth[depth].BetaCuts++
 					return value
 				}
 				// value is < beta
@@ -165,12 +201,139 @@ func (s *Search) rootSearch(p *position.Position, depth int, alpha Value, beta V
 	return bestNodeValue
 }
 
+// isForcing returns true if the given move is a capture, a promotion
+// or gives check.
+func isForcing(p *position.Position, move Move) bool {
+	return p.IsCapturingMove(move) ||
+		move.MoveType() == Promotion ||
+		p.GivesCheck(move)
+}
+
+// nullMoveAllowed returns true if a null move search can be done in the
+// given position at the given depth. Null move pruning fails in zugzwang
+// positions which are more likely in end games. Therefore it is never done
+// when the side to move has only pawns left and requires a higher minimum
+// depth (NmpEndgameDepth) when its non pawn material is below
+// NmpEndgameMaterial.
+func nullMoveAllowed(p *position.Position, depth int) bool {
+	nonPawnMaterial := p.MaterialNonPawn(p.NextPlayer())
+	// zugzwang risk
+	if nonPawnMaterial == 0 {
+		return false
+	}
+	minDepth := Settings.Search.NmpDepth
+	if nonPawnMaterial < Value(Settings.Search.NmpEndgameMaterial) {
+		if Settings.Search.NmpEndgameDepth == 0 {
+			return false
+		}
+		minDepth = Settings.Search.NmpEndgameDepth
+	}
+	return depth >= minDepth
+}
+
+// updateHistory updates the history count of the given move by the given
+// bonus (negative for a malus). Without history gravity the count is
+// increased or decreased by the bonus. With history gravity the update is
+// damped by the current count which keeps it within +/-HistoryMax.
+func (s *Search) updateHistory(c Color, from Square, to Square, bonus int64) {
+	switch {
+	case Settings.Search.UseHistoryGravity:
+		s.history.UpdateHistoryGravity(c, from, to, bonus)
+	case bonus > 0:
+		s.history.IncHistoryCount(c, from, to, bonus)
+	default:
+		s.history.DecHistoryCount(c, from, to, -bonus)
+	}
+}
+
+// updateQuietStats updates the move ordering statistics after a beta cut off.
+// A quiet best move gets a history bonus and is stored as counter move to the
+// opponent's last move and as follow-up move to our own previous move. All
+// quiet moves tried before without a beta cut off get a history malus.
+// We use 1 << depth as bonus to favor deeper searches.
+func (s *Search) updateQuietStats(p *position.Position, bestMove Move, quietsTried *moveslice.MoveSlice, depth int) {
+	us := p.NextPlayer()
+	bonus := int64(1) << depth
+	if !p.IsCapturingMove(bestMove) {
+		if Settings.Search.UseHistoryCounter {
+			s.updateHistory(us, bestMove.From(), bestMove.To(), bonus)
+		}
+		if Settings.Search.UseCounterMoves {
+			if lastMove := p.LastMove(); lastMove != MoveNone {
+				s.history.CounterMoves[lastMove.From()][lastMove.To()] = bestMove.MoveOf()
+			}
+		}
+		if Settings.Search.UseFollowUpMoves {
+			if ownMove := p.PreviousMove(2); ownMove != MoveNone {
+				s.history.FollowUpMoves[ownMove.From()][ownMove.To()] = bestMove.MoveOf()
+			}
+		}
+	}
+	if Settings.Search.UseHistoryCounter && quietsTried != nil {
+		for _, m := range *quietsTried {
+			s.updateHistory(us, m.From(), m.To(), -bonus)
+		}
+	}
+}
+
+// lmrReduction returns the depth reduction for late move reduction of the
+// given move. All exceptions are handled here: moves in PV nodes, the TT
+// move, killer moves, check evasions, promotions, captures and checking
+// moves are never reduced. Moves with a good history and moves in a node
+// where the static eval is improving are reduced less if configured. Moves
+// in expected all nodes (not a cut node) are reduced more if configured.
+func (s *Search) lmrReduction(p *position.Position, move Move, ttMove Move, killers *[2]Move,
+	depth int, moveNum int, isPV bool, cutNode bool, improving bool) int {
+
+	switch {
+	case depth < Settings.Search.LmrDepth || moveNum < Settings.Search.LmrMovesSearched:
+		return 0
+	case isPV || s.mateSearch:
+		return 0
+	case move == ttMove || move == killers[0] || move == killers[1]:
+		return 0
+	case p.HasCheck(): // evasion
+		return 0
+	case move.MoveType() == Promotion || p.IsCapturingMove(move) || p.GivesCheck(move):
+		return 0
+	}
+
+	reduction := LmrReduction(depth, moveNum)
+	if threshold := Settings.Search.LmrHistoryThreshold; threshold > 0 &&
+		s.history.HistoryCount[p.NextPlayer()][move.From()][move.To()] >= int64(threshold) {
+		reduction--
+	}
+	if Settings.Search.LmrImproving && improving {
+		reduction--
+	}
+	if Settings.Search.LmrNodeType && !cutNode {
+		reduction++
+	}
+	if reduction < 0 {
+		return 0
+	}
+	return reduction
+}
+
+// childCutNode returns true if the child node reached by a move of the
+// current node is an expected cut node. In a pv node the first move leads to
+// another pv node and all other moves lead to expected cut nodes. In null
+// window (non pv) nodes the expected node types alternate - the children of
+// an expected cut node are expected all nodes and vice versa.
+func childCutNode(pvNode bool, cutNode bool, movesSearched int) bool {
+	if pvNode {
+		return movesSearched > 0
+	}
+	return !cutNode
+}
+
 // search is the normal alpha beta search after the root move ply (ply > 0)
 // it will be called recursively until the remaining depth == 0 and we would
 // enter quiescence search. Search consumes about 60% of the search time and
 // all major prunings are done here. Quiescence search uses about 40% of the
 // search time and has less options for pruning as not all moves are searched.
-func (s *Search) search(p *position.Position, depth int, ply int, alpha Value, beta Value, isPV bool, doNull bool) Value {
+// cutNode is true if the node is expected to fail high (see childCutNode).
+func (s *Search) search(p *position.Position, depth int, ply int, alpha Value, beta Value, isPV bool, doNull bool, cutNode bool) Value {
 	if trace {
 		s.slog.Debugf("%0*s Ply %-2.d Depth %-2.d a:%-6.d b:%-6.d pv:%-6.v start:  %s", ply, "", ply, depth, alpha, beta, isPV, s.statistics.CurrentVariation.StringUci())
 		defer s.slog.Debugf("%0*s Ply %-2.d Depth %-2.d a:%-6.d b:%-6.d pv:%-6.v end  :  %s", ply, "", ply, depth, alpha, beta, isPV, s.statistics.CurrentVariation.StringUci())
@@ -207,6 +370,16 @@ func (s *Search) search(p *position.Position, depth int, ply int, alpha Value, b
 	hasCheck := p.HasCheck()
 	matethreat := false
 
+	// expected node type - only null window nodes are cut or all nodes
+	pvNode := beta-alpha > 1
+	if !pvNode {
+		if cutNode {
+			s.statistics.CutNodes++
+		} else {
+			s.statistics.AllNodes++
+		}
+	}
+
 	// TT Lookup
 	// Results of searches are stored in the TT to be used to
 	// avoid searching positions several times. If a position
@@ -228,6 +401,7 @@ func (s *Search) search(p *position.Position, depth int, ply int, alpha Value, b
 		ttEntry = s.tt.Probe(p.ZobristKey())
 		if ttEntry != nil { // tt hit
 			s.statistics.TTHit++
+			s.statistics.PerDepth[depth].TTHits++
 			ttMove = ttEntry.Move.MoveOf()
 			if int(ttEntry.Depth) >= depth {
 				ttValue := valueFromTT(ttEntry.Move.ValueOf(), ply)
@@ -261,32 +435,46 @@ func (s *Search) search(p *position.Position, depth int, ply int, alpha Value, b
 		staticEval = s.evaluate(p, ply)
 		// TODO: Consider storing in TT
 	}
-
-	// Razoring from Stockfish
-	// When static eval is well below alpha at the last node
-	// jump directly into qsearch
-	if Settings.Search.UseRazoring &&
-		depth == 1 &&
+	// the static eval is improving when it is better than the static
+	// eval of our last move
+	s.staticEvals[ply] = staticEval
+	improving := ply >= 2 &&
 		staticEval != ValueNA &&
-		staticEval <= alpha-Value(Settings.Search.RazorMargin) {
+		s.staticEvals[ply-2] != ValueNA &&
+		staticEval > s.staticEvals[ply-2]
 
-		return s.qsearch(p, ply, alpha, beta, isPV)
+	// static prunings are skipped when we have a good tt move
+	staticPruning := staticPruningAllowed(ttEntry)
+
+	// Razoring from Stockfish
+	// When static eval is well below alpha at the last nodes
+	// jump directly into qsearch. Above depth 1 the qsearch value
+	// is only trusted if it confirms the fail low - otherwise
+	// the node is searched normally.
+	if !s.mateSearch &&
+		staticPruning &&
+		razoring(depth, staticEval, alpha) {
+
+		value := s.qsearch(p, ply, alpha, beta, isPV)
+		if depth == 1 || value <= alpha {
+			return value
+		}
 	}
 
 	// Reverse Futility Pruning, (RFP, Static Null Move Pruning)
-	// https://www.chessprogramming.org/Reverse_Futility_Pruning
 	// Anticipate likely alpha low in the next ply by a beta cut
 	// off before making and evaluating the move
-	if Settings.Search.UseRFP &&
+	if !s.mateSearch &&
+		staticPruning &&
 		doNull &&
-		depth <= 3 &&
 		!isPV &&
 		!hasCheck {
 
-		margin := rfp[depth]
-		if staticEval-margin >= beta {
+		// fail-hard: beta / fail-soft: staticEval - evalMargin;
+		if value, ok := reverseFutility(depth, staticEval, beta); ok {
 			s.statistics.RfpPrunings++
-			return staticEval - margin // fail-hard: beta / fail-soft: staticEval - evalMargin;
+			s.statistics.PerDepth[depth].Prunings++
+			return value
 		}
 	}
 
@@ -300,12 +488,11 @@ func (s *Search) search(p *position.Position, depth int, ply int, alpha Value, b
 	// - Zugzwang - it would be better not to move
 	// - in check - this would lead to an illegal situation where the king is captured
 	// - recursive null moves should be avoided
-	if Settings.Search.UseNullMove {
+	if Settings.Search.UseNullMove && !s.mateSearch {
 		if doNull &&
 			!isPV &&
-			depth >= Settings.Search.NmpDepth &&
-			p.MaterialNonPawn(us) > 0 &&
-			!hasCheck {
+			!hasCheck &&
+			nullMoveAllowed(p, depth) {
 			// possible other criteria: eval > beta
 
 			// determine depth reduction
@@ -325,7 +512,8 @@ func (s *Search) search(p *position.Position, depth int, ply int, alpha Value, b
 			// do null move search
 			p.DoNullMove()
 			s.nodesVisited++
-			nValue := -s.search(p, newDepth, ply+1, -beta, -beta+1, false, false)
+			s.statistics.PerDepth[depth].Nodes++
+			nValue := -s.search(p, newDepth, ply+1, -beta, -beta+1, false, false, !cutNode)
 			p.UndoNullMove()
 
 			// check if we should stop the search
@@ -351,6 +539,7 @@ func (s *Search) search(p *position.Position, depth int, ply int, alpha Value, b
 			// be above beta if we make a move
 			if nValue >= beta {
 				s.statistics.NullMoveCuts++
+				s.statistics.PerDepth[depth].Prunings++
 				// Store TT
 				if Settings.Search.UseTT {
 					s.storeTT(p, depth, ply, ttMove, nValue, BETA)
@@ -381,7 +570,7 @@ func (s *Search) search(p *position.Position, depth int, ply int, alpha Value, b
 			}
 
 			// do the actual reduced search
-			s.search(p, newDepth, ply, alpha, beta, isPV, true)
+			s.search(p, newDepth, ply, alpha, beta, isPV, true, cutNode)
 			s.statistics.IIDsearches++
 
 			// check if we should stop the search
@@ -416,9 +605,28 @@ func (s *Search) search(p *position.Position, depth int, ply int, alpha Value, b
 		}
 	}
 
+	// Forcing search
+	// Only forcing moves are searched when not in check. As we are not
+	// forced to play one of these the static eval is used as a stand
+	// pat (lower bound) like in qsearch.
+	if s.forcingSearch && !hasCheck {
+		if staticEval >= beta {
+			s.statistics.StandpatCuts++
+			return staticEval
+		}
+		if staticEval > alpha {
+			alpha = staticEval
+		}
+		bestNodeValue = staticEval
+	}
+
 	// prepare move loop
 	var value Value
 	movesSearched := 0
+	movesPruned := 0
+	captureTried := false
+	quietsTried := s.quietsTried[ply]
+	quietsTried.Clear()
 
 	// ///////////////////////////////////////////////////////
 	// MOVE LOOP
@@ -429,6 +637,22 @@ func (s *Search) search(p *position.Position, depth int, ply int, alpha Value, b
 		to := move.To()
 		givesCheck := p.GivesCheck(move)
 
+		// forcing search - skip quiet moves
+		if s.forcingSearch && !hasCheck && !givesCheck &&
+			move.MoveType() != Promotion && !p.IsCapturingMove(move) {
+			continue
+		}
+
+		// measure the quality of capture ordering - the extra SEE
+		// is only worth it in debug builds
+		if assert.DEBUG && !captureTried && p.IsCapturingMove(move) {
+			captureTried = true
+			s.statistics.FirstCaptures++
+			if attacks.See(p, move) >= 0 {
+				s.statistics.FirstCapturesWinning++
+			}
+		}
+
 		if false { // DEBUG
 			err := false
 			msg := ""
@@ -491,6 +715,16 @@ func (s *Search) search(p *position.Position, depth int, ply int, alpha Value, b
 				extension = 1
 			}
 
+			// Pawns close to promotion often decide the game but
+			// the promotion itself might be just beyond the horizon.
+			// Depth is counted in full plies so this extends by a
+			// full ply and not by a fraction of a ply. As with the
+			// other extensions a move is extended by one ply at most.
+			if Settings.Search.UsePassedPawnExt && extension == 0 && isDangerousPawnPush(p, move) {
+				s.statistics.PassedPawnExtension++
+				extension = 1
+			}
+
 			// With this turned off we still can use extension to
 			// at least avoid reductions for these moves.
 			if Settings.Search.UseExtAddDepth {
@@ -502,7 +736,9 @@ func (s *Search) search(p *position.Position, depth int, ply int, alpha Value, b
 		// Forward Pruning
 		// FP will only be done when the move is not
 		// interesting - no check, no capture, etc.
+		// The evaluation free mate search needs all moves.
 		if !isPV &&
+			!s.mateSearch &&
 			extension == 0 &&
 			move != ttMove &&
 			move != (*myMg.KillerMoves())[0] &&
@@ -527,45 +763,65 @@ func (s *Search) search(p *position.Position, depth int, ply int, alpha Value, b
 			// Limited Razoring / Extended FP are covered by this.
 			// TODO: needs testing and tuning
 			// TODO: Crafty excepts moves were passed pawns are far ahead.
-			if Settings.Search.UseFP && depth < 7 {
-				futilityMargin := fp[depth]
-				if staticEval+moveGain+futilityMargin <= alpha {
-					if staticEval+moveGain > bestNodeValue {
-						bestNodeValue = staticEval + moveGain
-					}
-					s.statistics.FpPrunings++
-					continue
+			if futile(depth, staticEval, moveGain, alpha) {
+				if staticEval+moveGain > bestNodeValue {
+					bestNodeValue = staticEval + moveGain
 				}
+				s.statistics.FpPrunings++
+				s.statistics.PerDepth[depth].Prunings++
+				movesPruned++
+				continue
 			}
 
 			// LMP - Late Move Pruning
 			// aka Move Count Based Pruning
 			// TODO: dangerous needs testing and tuning
-			if Settings.Search.UseLmp {
-				if movesSearched >= LmpMovesSearched(depth) {
-					s.statistics.LmpCuts++
-					continue
-				}
+			if lateMove(depth, movesSearched) {
+				s.statistics.LmpCuts++
+				s.statistics.PerDepth[depth].Prunings++
+				movesPruned++
+				continue
 			}
+		}
 
-			// LMR
-			// Late Move Reduction assumes that later moves a rarely
-			// exceeding alpha and therefore the search is reduced in
-			// depth. This is in effect a soft transition into
-			// quiescence search as we usually try the pv move and
-			// capturing moves first. In quiescence only capturing
-			// moves are searched anyway.
-			// newDepth is the "standard" new depth (depth - 1)
-			// lmrDepth is set to newDepth and only reduced
-			// if conditions apply.
-			// TODO: needs testing and tuning
-			if Settings.Search.UseLmr {
-				// compute reduction from depth and move searched
-				if depth >= Settings.Search.LmrDepth &&
-					movesSearched >= Settings.Search.LmrMovesSearched {
-					lmrDepth -= LmrReduction(depth, movesSearched)
-					s.statistics.LmrReductions++
-				}
+		// SEE Pruning
+		// Captures losing material by SEE are pruned close to
+		// the leaves. Quiescence search would most likely not
+		// look at them either.
+		if !isPV &&
+			!s.mateSearch &&
+			extension == 0 &&
+			move != ttMove &&
+			move.MoveType() != Promotion &&
+			p.IsCapturingMove(move) &&
+			!hasCheck &&
+			!givesCheck &&
+			seeLosing(p, move, depth) {
+			s.statistics.SeePrunings++
+			s.statistics.PerDepth[depth].Prunings++
+			movesPruned++
+			continue
+		}
+		// ///////////////////////////////////////////////////////
+
+		// ///////////////////////////////////////////////////////
+		// LMR
+		// Late Move Reduction assumes that later moves a rarely
+		// exceeding alpha and therefore the search is reduced in
+		// depth. This is in effect a soft transition into
+		// quiescence search as we usually try the pv move and
+		// capturing moves first. In quiescence only capturing
+		// moves are searched anyway.
+		// newDepth is the "standard" new depth (depth - 1)
+		// lmrDepth is set to newDepth and only reduced
+		// if conditions apply (see lmrReduction).
+		// After a mate threat from the null move search and for
+		// extended moves we do not reduce at all.
+		// TODO: needs testing and tuning
+		if Settings.Search.UseLmr && !matethreat && extension == 0 {
+			if r := s.lmrReduction(p, move, ttMove, myMg.KillerMoves(), depth, movesSearched, isPV, cutNode, improving); r > 0 {
+				lmrDepth -= r
+				s.statistics.LmrReductions++
 				// make sure not to become negative
 				if lmrDepth < 0 {
 					lmrDepth = 0
@@ -586,12 +842,13 @@ func (s *Search) search(p *position.Position, depth int, ply int, alpha Value, b
 
 		// we only count legal moves
 		s.nodesVisited++
+		s.statistics.PerDepth[depth].Nodes++
 		s.statistics.CurrentVariation.PushBack(move)
 		s.sendSearchUpdateToUci()
 
 		// check repetition and 50 moves
 		if s.checkDrawRepAnd50(p, 2) {
-			value = ValueDraw
+			value = -s.drawValue(p)
 
 		} else {
 
@@ -604,12 +861,12 @@ func (s *Search) search(p *position.Position, depth int, ply int, alpha Value, b
 			// to research the move again with a full window.
 			// https://www.chessprogramming.org/Principal_Variation_Search
 			if !Settings.Search.UsePVS || movesSearched == 0 {
-				value = -s.search(p, newDepth, ply+1, -beta, -alpha, true, true)
+				value = -s.search(p, newDepth, ply+1, -beta, -alpha, true, true, childCutNode(pvNode, cutNode, movesSearched))
 			} else {
 				// Null window search after the initial PV search.
 				// As depth we use a potentially reduced depth if Late Move Reduction
 				// conditions have been met above.
-				value = -s.search(p, lmrDepth, ply+1, -alpha-1, -alpha, false, true)
+				value = -s.search(p, lmrDepth, ply+1, -alpha-1, -alpha, false, true, childCutNode(pvNode, cutNode, movesSearched))
 				// If this move improved alpha without exceeding beta we do a proper full window
 				// search to get an accurate score.
 				// Without LMR we check for value > alpha && value < beta
@@ -618,10 +875,10 @@ func (s *Search) search(p *position.Position, depth int, ply int, alpha Value, b
 					// did we actually have a LMR reduction?
 					if lmrDepth < newDepth {
 						s.statistics.LmrResearches++
-						value = -s.search(p, newDepth, ply+1, -beta, -alpha, true, true)
+						value = -s.search(p, newDepth, ply+1, -beta, -alpha, true, true, false)
 					} else if value < beta {
 						s.statistics.PvsResearches++
-						value = -s.search(p, newDepth, ply+1, -beta, -alpha, true, true)
+						value = -s.search(p, newDepth, ply+1, -beta, -alpha, true, true, false)
 					}
 				}
 			}
@@ -654,6 +911,11 @@ func (s *Search) search(p *position.Position, depth int, ply int, alpha Value, b
 			// node we would play. We will return alpha and store a alpha
 			// node in TT with no best move for TT.
 			if value > alpha {
+				// count when the move from the TT was the first to
+				// raise alpha or cause a beta cut
+				if Settings.Search.UseTTMove && move == ttMove && movesSearched == 1 {
+					s.statistics.TTMoveBest++
+				}
 				// If we found a move that is better or equal than beta
 				// this means that the opponent can/will avoid this
 				// position altogether so we can stop search this node.
@@ -665,6 +927,7 @@ func (s *Search) search(p *position.Position, depth int, ply int, alpha Value, b
 				if value >= beta {
 					// Count beta cuts
 					s.statistics.BetaCuts++
+					s.statistics.PerDepth[depth].BetaCuts++
 					// Count beta cuts on first move
 					if movesSearched == 1 {
 						s.statistics.BetaCuts1st++
@@ -673,19 +936,10 @@ func (s *Search) search(p *position.Position, depth int, ply int, alpha Value, b
 					if Settings.Search.UseKiller && !p.IsCapturingMove(move) {
 						myMg.StoreKiller(move)
 					}
-					// counter for moves which caused a beta cut off
-					// we use 1 << depth as an increment to favor deeper searches
-					// a more repetitions
-					if Settings.Search.UseHistoryCounter {
-						s.history.HistoryCount[us][from][to] += 1 << depth
-					}
-					// store a successful counter move to the previous opponent move
-					if Settings.Search.UseCounterMoves {
-						lastMove := p.LastMove()
-						if lastMove != MoveNone {
-							s.history.CounterMoves[lastMove.From()][lastMove.To()] = move
-						}
-					}
+					// history, counter and follow-up moves for the move
+					// which caused the beta cut off and a malus for the
+					// quiet moves tried before
+					s.updateQuietStats(p, move, quietsTried, depth)
 					ttType = BETA
 					break
 				}
@@ -699,13 +953,10 @@ func (s *Search) search(p *position.Position, depth int, ply int, alpha Value, b
 				ttType = EXACT
 			}
 		}
-		// no beta cutoff - decrease historyCounter for the move
-		// we decrease it by only half the increase amount
-		if Settings.Search.UseHistoryCounter {
-			s.history.HistoryCount[us][from][to] -= 1 << depth
-			if s.history.HistoryCount[us][from][to] < 0 {
-				s.history.HistoryCount[us][from][to] = 0
-			}
+		// no beta cutoff - remember quiet moves for a history
+		// malus when a later move causes a beta cut off
+		if !p.IsCapturingMove(move) {
+			quietsTried.PushBack(move)
 		}
 	}
 	// MOVE LOOP
@@ -714,15 +965,31 @@ func (s *Search) search(p *position.Position, depth int, ply int, alpha Value, b
 	// If we did not have at least one legal move
 	// then we might have a mate or stalemate
 	if movesSearched == 0 && !s.stopConditions() {
+		// a forcing search skips quiet moves and pruning skips
+		// moves so both might legally have searched no move at all
+		if (hasCheck || !s.forcingSearch) && movesPruned == 0 && s.inconsistentNoMoves(p, depth, ply, myMg) {
+			return s.evaluate(p, ply)
+		}
 		if p.HasCheck() { // mate
+			if assert.DEBUG {
+				assert.Assert(myMg.GenerateEvasions(p).Len() == 0, "Search: mate detected but evasions found in %s", p.StringFen())
+			}
 			s.statistics.Checkmates++
 			bestNodeValue = -ValueCheckMate + Value(ply)
-		} else { // stalemate
+			// this is in any case an exact value
+			ttType = EXACT
+		} else if (!s.forcingSearch && movesPruned == 0) || !myMg.HasLegalMove(p) { // stalemate
 			s.statistics.Stalemates++
-			bestNodeValue = ValueDraw
+			bestNodeValue = s.drawValue(p)
+			// this is in any case an exact value
+			ttType = EXACT
+		} else if bestNodeValue == ValueNA {
+			// all moves were pruned without a futility value
+			bestNodeValue = alpha
 		}
-		// this is in any case an exact value
-		ttType = EXACT
+		// otherwise there was no forcing move in a forcing search
+		// or all moves were pruned and the stand pat or futility
+		// value is kept
 	}
 
 	// Store TT
@@ -758,6 +1025,12 @@ func (s *Search) qsearch(p *position.Position, ply int, alpha Value, beta Value,
 		return s.evaluate(p, ply)
 	}
 
+	// in the evaluation free mate search only check evasions need
+	// to be searched to find mates - all other leaves are a draw
+	if s.mateSearch && !p.HasCheck() {
+		return s.evaluate(p, ply)
+	}
+
 	// Mate Distance Pruning
 	// Did we already find a shorter mate then ignore
 	// this one.
@@ -803,6 +1076,7 @@ func (s *Search) qsearch(p *position.Position, ply int, alpha Value, beta Value,
 		ttEntry = s.tt.Probe(p.ZobristKey())
 		if ttEntry != nil { // tt hit
 			s.statistics.TTHit++
+			s.statistics.PerDepth[0].TTHits++
 			ttMove = ttEntry.Move.MoveOf()
 			ttValue := valueFromTT(ttEntry.Move.ValueOf(), ply)
 			cut := false
@@ -871,8 +1145,7 @@ func (s *Search) qsearch(p *position.Position, ply int, alpha Value, beta Value,
 		// Forward Pruning
 		// FP will only be done when the move is not
 		// interesting - no check, no capture, etc.
-		if Settings.Search.UseQFP &&
-			!isPV &&
+		if !isPV &&
 			move != ttMove &&
 			move != (*myMg.KillerMoves())[0] &&
 			move != (*myMg.KillerMoves())[1] &&
@@ -883,12 +1156,12 @@ func (s *Search) qsearch(p *position.Position, ply int, alpha Value, beta Value,
 			// to check in futility pruning what material delta we have
 			moveGain := p.GetPiece(move.To()).ValueOf()
 
-			futilityMargin := Value(150)
-			if staticEval+moveGain+futilityMargin <= alpha {
+			if qsFutile(staticEval, moveGain, alpha) {
 				if staticEval+moveGain > bestNodeValue {
 					bestNodeValue = staticEval + moveGain
 				}
 				s.statistics.QFpPrunings++
+				s.statistics.PerDepth[0].Prunings++
 				continue
 			}
 		}
@@ -899,6 +1172,19 @@ func (s *Search) qsearch(p *position.Position, ply int, alpha Value, beta Value,
 			continue
 		}
 
+		// limit the number of captures searched in quiescence to bound
+		// the branching in wild positions. The captures are sorted so
+		// that the most promising ones come first (with UseSEEOrdering
+		// losing captures come last).
+		if Settings.Search.UseQSCaptureLimit &&
+			!hasCheck &&
+			movesSearched >= Settings.Search.QSCaptureLimit &&
+			move.MoveType() != Promotion {
+			s.statistics.QSCaptureLimits++
+			s.statistics.PerDepth[0].Prunings++
+			continue
+		}
+
 		// ///////////////////////////////////////////////////////
 		// DO MOVE
 		p.DoMove(move)
@@ -911,6 +1197,7 @@ func (s *Search) qsearch(p *position.Position, ply int, alpha Value, beta Value,
 
 		// we only count legal moves
 		s.nodesVisited++
+		s.statistics.PerDepth[0].Nodes++
 		s.statistics.CurrentVariation.PushBack(move)
 		s.sendSearchUpdateToUci()
 
@@ -918,7 +1205,7 @@ func (s *Search) qsearch(p *position.Position, ply int, alpha Value, beta Value,
 		// otherwise only capturing moves are generated
 		// which break repetition and 50-moves rule anyway
 		if hasCheck && s.checkDrawRepAnd50(p, 2) {
-			value = ValueDraw
+			value = -s.drawValue(p)
 		} else {
 			value = -s.qsearch(p, ply+1, -beta, -alpha, isPV)
 		}
@@ -939,20 +1226,16 @@ func (s *Search) qsearch(p *position.Position, ply int, alpha Value, beta Value,
 			bestNodeValue = value
 			bestNodeMove = move
 			if value > alpha {
+				if Settings.Search.UseQSTT && move == ttMove && movesSearched == 1 {
+					s.statistics.TTMoveBest++
+				}
 				if value >= beta {
 					s.statistics.BetaCuts++
+					s.statistics.PerDepth[0].BetaCuts++
 					if movesSearched == 1 {
 						s.statistics.BetaCuts1st++
 					}
-					if Settings.Search.UseHistoryCounter {
-						s.history.HistoryCount[p.NextPlayer()][move.From()][move.To()] += 1 << 1
-					}
-					if Settings.Search.UseCounterMoves {
-						lastMove := p.LastMove()
-						if lastMove != MoveNone {
-							s.history.CounterMoves[lastMove.From()][lastMove.To()] = move
-						}
-					}
+					s.updateQuietStats(p, move, nil, 1)
 					ttType = BETA
 					break
 				}
@@ -969,6 +1252,11 @@ func (s *Search) qsearch(p *position.Position, ply int, alpha Value, beta Value,
 	// then we might have a mate or in quiescence
 	// only quite moves
 	if movesSearched == 0 && !s.stopConditions() {
+		// when in check all moves were generated and a legal
+		// move means the move generator failed
+		if hasCheck && s.inconsistentNoMoves(p, 0, ply, myMg) {
+			return s.evaluate(p, ply)
+		}
 		// if we have a mate we had a check before and therefore
 		// generated all moves. We can be sure this is a mate.
 		if p.HasCheck() {
@@ -992,6 +1280,26 @@ func (s *Search) qsearch(p *position.Position, ply int, alpha Value, beta Value,
 	return bestNodeValue
 }
 
+// inconsistentNoMoves is a safety check for nodes where the move generator did
+// not produce a single legal move. If HasLegalMove finds a legal move anyway
+// the move generator or the position is broken. The inconsistency is logged
+// with the fen and the caller should return a safe value instead of reporting
+// a mate or stalemate. Only active with assert.DEBUG or Settings.Search.CheckNoMoves.
+func (s *Search) inconsistentNoMoves(p *position.Position, depth int, ply int, mg *movegen.Movegen) bool {
+	if !assert.DEBUG && !Settings.Search.CheckNoMoves {
+		return false
+	}
+	if !mg.HasLegalMove(p) {
+		return false
+	}
+	s.statistics.NoMovesErrors++
+	s.log.Criticalf("Search              : No moves generated but legal moves available\n")
+	s.log.Criticalf("Search              : Depth %d Ply %d\n", depth, ply)
+	s.log.Criticalf("Position            : %s\n", p.StringFen())
+	s.log.Criticalf("Legal Moves         : %s\n", mg.GenerateLegalMoves(p, movegen.GenAll).StringUci())
+	return true
+}
+
 // After expanding the search to the required depth and all non quiet moves were
 // generated call the evaluation heuristic on the position.
 // This gives us a numerical value of this quiet position which we will return
@@ -999,6 +1307,11 @@ func (s *Search) qsearch(p *position.Position, ply int, alpha Value, beta Value,
 func (s *Search) evaluate(position *position.Position, ply int) Value {
 	s.statistics.LeafPositionsEvaluated++
 
+	// the evaluation free mate search treats all non mate leaves as a draw
+	if s.mateSearch {
+		return ValueDraw
+	}
+
 	var value = ValueNA
 
 	// We can try to see if TT also helps with already evaluated positions.
@@ -1031,12 +1344,13 @@ func (s *Search) evaluate(position *position.Position, ply int) Value {
 }
 
 // reduce the number of moves searched in quiescence search by trying
-// to only look at good captures. Might be improved with SEE in the
-// future
+// to only look at good captures. With SEE slightly losing captures
+// can be included with QSSeeMargin as they might be tactically
+// necessary.
 func (s *Search) goodCapture(p *position.Position, move Move) bool {
-	if Settings.Search.UseSEE {
+	if Settings.Search.UseQSSee {
 		// Check SEE score of higher value pieces to low value pieces
-		return see(p, move) > 0
+		return attacks.See(p, move) > -Value(Settings.Search.QSSeeMargin)
 	} else {
 		// Lower value piece captures higher value piece
 		// With a margin to also look at Bishop x Knight
@@ -1051,6 +1365,27 @@ func (s *Search) goodCapture(p *position.Position, move Move) bool {
 	}
 }
 
+// isDangerousPawnPush returns true if the move is a pawn push (no
+// promotion) to the 7th rank or a push of a passed pawn to the 6th rank
+// seen from the moving side.
+func isDangerousPawnPush(p *position.Position, move Move) bool {
+	if move.MoveType() == Promotion || p.GetPiece(move.From()).TypeOf() != Pawn {
+		return false
+	}
+	us := p.NextPlayer()
+	rank := move.To().RankOf()
+	if us == Black {
+		rank = Rank8 - rank
+	}
+	switch rank {
+	case Rank7:
+		return true
+	case Rank6:
+		return move.To().PassedPawnMask(us)&p.PiecesBb(us.Flip(), Pawn) == 0
+	}
+	return false
+}
+
 // savePV adds the given move as first move to a dest moveslice and the appends
 // all src moves to dest. Dest will be cleared before the the append.
 func savePV(move Move, src *moveslice.MoveSlice, dest *moveslice.MoveSlice) {
@@ -34,8 +34,10 @@ import (
 	"github.com/stretchr/testify/assert"
 
 	"github.com/frankkopp/FrankyGo/internal/config"
+	"github.com/frankkopp/FrankyGo/internal/movegen"
 	"github.com/frankkopp/FrankyGo/internal/moveslice"
 	"github.com/frankkopp/FrankyGo/internal/position"
+	"github.com/frankkopp/FrankyGo/internal/transpositiontable"
 	. "github.com/frankkopp/FrankyGo/internal/types"
 	"github.com/frankkopp/FrankyGo/internal/util"
 	"github.com/frankkopp/FrankyGo/test/testdata"
@@ -69,6 +71,403 @@ func TestMate(t *testing.T) {
 	assert.EqualValues(t, 9993, s.lastSearchResult.BestValue)
 }
 
+func TestMateSearchMode(t *testing.T) {
+	defer func() { config.Settings.Search.MateSearchMode = false }()
+	config.Settings.Search.UseBook = false
+	fen := "4r1b1/1p4B1/pN2pR2/RB2k3/1P2N2p/2p3b1/n2P1p1r/5K1n w - -"
+	sl := NewSearchLimits()
+	sl.Mate = 3
+
+	// normal search
+	s := NewSearch()
+	p, _ := position.NewPositionFen(fen)
+	s.StartSearch(*p, *sl)
+	s.WaitWhileSearching()
+	assert.EqualValues(t, ValueCheckMate-5, s.lastSearchResult.BestValue)
+	normalNodes := s.lastSearchResult.Nodes
+
+	// evaluation free mate search
+	config.Settings.Search.MateSearchMode = true
+	s = NewSearch()
+	s.StartSearch(*p, *sl)
+	s.WaitWhileSearching()
+	assert.EqualValues(t, ValueCheckMate-5, s.lastSearchResult.BestValue)
+	assert.Less(t, s.lastSearchResult.Nodes, normalNodes)
+	// the draw values of the mate search must not remain in the tt
+	assert.EqualValues(t, 0, s.tt.Len())
+	out.Printf("Nodes normal search: %d mate search mode: %d\n", normalNodes, s.lastSearchResult.Nodes)
+}
+
+func TestLmrReduction(t *testing.T) {
+	defer func() {
+		config.Settings.Search.LmrHistoryThreshold = 0
+		config.Settings.Search.LmrImproving = false
+		config.Settings.Search.LmrNodeType = false
+	}()
+	s := NewSearch()
+	p := position.NewPosition()
+	quiet := CreateMove(SqB1, SqC3, Normal, PtNone)
+	other := CreateMove(SqG1, SqF3, Normal, PtNone)
+	noKillers := &[2]Move{MoveNone, MoveNone}
+	depth, moveNum := 10, 20
+
+	r := s.lmrReduction(p, quiet, MoveNone, noKillers, depth, moveNum, false, true, false)
+	assert.EqualValues(t, LmrReduction(depth, moveNum), r)
+	assert.Greater(t, r, 1)
+
+	// no reduction for early moves or low depths
+	assert.EqualValues(t, 0, s.lmrReduction(p, quiet, MoveNone, noKillers, 2, moveNum, false, true, false))
+	assert.EqualValues(t, 0, s.lmrReduction(p, quiet, MoveNone, noKillers, depth, 1, false, true, false))
+
+	// no reduction in pv nodes, for the tt move and killers
+	assert.EqualValues(t, 0, s.lmrReduction(p, quiet, MoveNone, noKillers, depth, moveNum, true, true, false))
+	assert.EqualValues(t, 0, s.lmrReduction(p, quiet, quiet, noKillers, depth, moveNum, false, true, false))
+	assert.EqualValues(t, 0, s.lmrReduction(p, quiet, MoveNone, &[2]Move{quiet, other}, depth, moveNum, false, true, false))
+	assert.EqualValues(t, 0, s.lmrReduction(p, quiet, MoveNone, &[2]Move{other, quiet}, depth, moveNum, false, true, false))
+
+	// no reduction for check evasions
+	p = position.NewPosition("4k3/8/8/8/8/8/3PP3/r3K3 w - -")
+	evasion := CreateMove(SqE1, SqF2, Normal, PtNone)
+	assert.True(t, p.HasCheck())
+	assert.EqualValues(t, 0, s.lmrReduction(p, evasion, MoveNone, noKillers, depth, moveNum, false, true, false))
+
+	// no reduction for captures and checks
+	p = position.NewPosition("4k3/8/8/3p4/4P3/8/8/R3K3 w - -")
+	assert.EqualValues(t, 0, s.lmrReduction(p, CreateMove(SqE4, SqD5, Normal, PtNone), MoveNone, noKillers, depth, moveNum, false, true, false))
+	assert.EqualValues(t, 0, s.lmrReduction(p, CreateMove(SqA1, SqA8, Normal, PtNone), MoveNone, noKillers, depth, moveNum, false, true, false))
+
+	// smaller reductions for good history and improving static eval
+	p = position.NewPosition()
+	s.history.HistoryCount[White][SqB1][SqC3] = 1_000
+	assert.EqualValues(t, r, s.lmrReduction(p, quiet, MoveNone, noKillers, depth, moveNum, false, true, true))
+	config.Settings.Search.LmrHistoryThreshold = 500
+	assert.EqualValues(t, r-1, s.lmrReduction(p, quiet, MoveNone, noKillers, depth, moveNum, false, true, false))
+	config.Settings.Search.LmrImproving = true
+	assert.EqualValues(t, r-2, s.lmrReduction(p, quiet, MoveNone, noKillers, depth, moveNum, false, true, true))
+	assert.EqualValues(t, r-1, s.lmrReduction(p, other, MoveNone, noKillers, depth, moveNum, false, true, true))
+
+	// larger reductions in expected all nodes
+	config.Settings.Search.LmrImproving = false
+	config.Settings.Search.LmrHistoryThreshold = 0
+	assert.EqualValues(t, r, s.lmrReduction(p, quiet, MoveNone, noKillers, depth, moveNum, false, false, false))
+	config.Settings.Search.LmrNodeType = true
+	assert.EqualValues(t, r+1, s.lmrReduction(p, quiet, MoveNone, noKillers, depth, moveNum, false, false, false))
+	assert.EqualValues(t, r, s.lmrReduction(p, quiet, MoveNone, noKillers, depth, moveNum, false, true, false))
+	assert.EqualValues(t, 0, s.lmrReduction(p, quiet, MoveNone, noKillers, depth, moveNum, true, false, false))
+}
+
+func TestChildCutNode(t *testing.T) {
+	// pv node: first move pv node, all other moves expected cut nodes
+	assert.False(t, childCutNode(true, false, 0))
+	assert.True(t, childCutNode(true, false, 1))
+	assert.True(t, childCutNode(true, false, 10))
+	// the node types alternate in null window nodes
+	for movesSearched := 0; movesSearched < 3; movesSearched++ {
+		assert.False(t, childCutNode(false, true, movesSearched))
+		assert.True(t, childCutNode(false, false, movesSearched))
+	}
+
+	// all null window nodes are classified
+	config.Settings.Search.UseBook = false
+	s := NewSearch()
+	sl := NewSearchLimits()
+	sl.Depth = 6
+	s.StartSearch(*position.NewPosition(), *sl)
+	s.WaitWhileSearching()
+	stats := s.Statistics()
+	assert.Greater(t, stats.CutNodes, uint64(0))
+	assert.Greater(t, stats.AllNodes, uint64(0))
+	assert.LessOrEqual(t, stats.CutNodes+stats.AllNodes, s.NodesVisited())
+}
+
+func TestNullMoveAllowed(t *testing.T) {
+	defer func() {
+		config.Settings.Search.NmpEndgameMaterial = 0
+		config.Settings.Search.NmpEndgameDepth = 6
+	}()
+	depth := config.Settings.Search.NmpDepth
+	middlegame := position.NewPosition("r1bqkb1r/pppp1ppp/2n2n2/4p3/2B1P3/5N2/PPPP1PPP/RNBQK2R w KQkq -")
+	pawnEndgame := position.NewPosition("8/5kpp/8/8/8/8/5PPP/6K1 w - -")
+	rookEndgame := position.NewPosition("8/5kpp/8/8/8/8/5PPP/3R2K1 w - -")
+
+	// zugzwang risk - no null move with only pawns left
+	assert.True(t, nullMoveAllowed(middlegame, depth))
+	assert.False(t, nullMoveAllowed(middlegame, depth-1))
+	assert.False(t, nullMoveAllowed(pawnEndgame, depth))
+	assert.False(t, nullMoveAllowed(pawnEndgame, 20))
+	assert.True(t, nullMoveAllowed(rookEndgame, depth))
+
+	// higher minimum depth in end games
+	config.Settings.Search.NmpEndgameMaterial = 1000
+	config.Settings.Search.NmpEndgameDepth = 6
+	assert.True(t, nullMoveAllowed(middlegame, depth))
+	assert.False(t, nullMoveAllowed(rookEndgame, depth))
+	assert.False(t, nullMoveAllowed(rookEndgame, 5))
+	assert.True(t, nullMoveAllowed(rookEndgame, 6))
+
+	// no null move in end games
+	config.Settings.Search.NmpEndgameDepth = 0
+	assert.True(t, nullMoveAllowed(middlegame, depth))
+	assert.False(t, nullMoveAllowed(rookEndgame, 20))
+}
+
+func TestStaticPruningAllowed(t *testing.T) {
+	defer func() { config.Settings.Search.NoPruneTTMoveDepth = 0 }()
+	move := CreateMove(SqE2, SqE4, Normal, PtNone)
+	deep := &transpositiontable.TtEntry{Move: move, Depth: 6, Type: BETA}
+	shallow := &transpositiontable.TtEntry{Move: move, Depth: 2, Type: BETA}
+	noMove := &transpositiontable.TtEntry{Move: MoveNone, Depth: 6, Type: ALPHA}
+
+	assert.True(t, staticPruningAllowed(nil))
+	assert.True(t, staticPruningAllowed(deep))
+
+	config.Settings.Search.NoPruneTTMoveDepth = 4
+	assert.True(t, staticPruningAllowed(nil))
+	assert.False(t, staticPruningAllowed(deep))
+	assert.True(t, staticPruningAllowed(shallow))
+	assert.True(t, staticPruningAllowed(noMove))
+}
+
+func TestNoPruneTTMove(t *testing.T) {
+	defer func() { config.Settings.Search.NoPruneTTMoveDepth = 0 }()
+	// the winning queen sacrifice g3g6 is found by a search to depth 8
+	p := position.NewPosition("2rr3k/pp3pp1/1nnqbN1p/3pN3/2pP4/2P3Q1/PPB4P/R4RK1 w - -")
+	sl := NewSearchLimits()
+	sl.Depth = 8
+
+	// the second search uses the tt moves of the first search
+	rfpPrunings := func() uint64 {
+		search := NewSearch()
+		search.StartSearch(*p, *sl)
+		search.WaitWhileSearching()
+		search.StartSearch(*p, *sl)
+		search.WaitWhileSearching()
+		assert.EqualValues(t, "g3g6", search.LastSearchResult().BestMove.StringUci())
+		return search.statistics.RfpPrunings
+	}
+
+	withPruning := rfpPrunings()
+	config.Settings.Search.NoPruneTTMoveDepth = 1
+	withoutPruning := rfpPrunings()
+	logTest.Debugf("RFP prunings %d (%d with tt move guard)", withPruning, withoutPruning)
+	assert.Less(t, withoutPruning, withPruning)
+}
+
+func TestQSSeeMargin(t *testing.T) {
+	defer func() { config.Settings.Search.QSSeeMargin = 0 }()
+	search := NewSearch()
+	// bishop takes a knight defended by a pawn: SEE = 320 - 330
+	p := position.NewPosition("4k3/8/4p3/3n4/8/8/6B1/4K3 w - -")
+	bxn := CreateMove(SqG2, SqD5, Normal, PtNone)
+	assert.False(t, search.goodCapture(p, bxn))
+	config.Settings.Search.QSSeeMargin = 50
+	assert.True(t, search.goodCapture(p, bxn))
+	config.Settings.Search.QSSeeMargin = 10
+	assert.False(t, search.goodCapture(p, bxn))
+
+	// more captures are searched in quiescence with a margin
+	qsNodes := func(margin int) uint64 {
+		config.Settings.Search.QSSeeMargin = margin
+		search := NewSearch()
+		p := position.NewPosition("r3k2r/p1ppqpb1/bn2pnp1/3PN3/1p2P3/2N2Q1p/PPPBBPPP/R3K2R w KQkq -")
+		sl := NewSearchLimits()
+		sl.Depth = 4
+		search.StartSearch(*p, *sl)
+		search.WaitWhileSearching()
+		return search.statistics.PerDepth[0].Nodes
+	}
+	withoutMargin := qsNodes(0)
+	withMargin := qsNodes(200)
+	logTest.Debugf("QS nodes %d (%d with margin)", withoutMargin, withMargin)
+	assert.Greater(t, withMargin, withoutMargin)
+}
+
+func TestQSCaptureLimit(t *testing.T) {
+	defer func() {
+		config.Settings.Search.QSSeeMargin = 0
+		config.Settings.Search.UseQSCaptureLimit = false
+		config.Settings.Search.QSCaptureLimit = 4
+	}()
+	config.Settings.Search.UseBook = false
+	// many captures are searched in quiescence with a large margin
+	config.Settings.Search.QSSeeMargin = 300
+	search := func(limit bool) (Move, Statistics) {
+		config.Settings.Search.UseQSCaptureLimit = limit
+		config.Settings.Search.QSCaptureLimit = 2
+		search := NewSearch()
+		// WAC.001 - Qg6 wins
+		p := position.NewPosition("2rr3k/pp3pp1/1nnqbN1p/3pN3/2pP4/2P3Q1/PPB4P/R4RK1 w - -")
+		sl := NewSearchLimits()
+		sl.Depth = 6
+		search.StartSearch(*p, *sl)
+		search.WaitWhileSearching()
+		return search.LastSearchResult().BestMove, search.statistics
+	}
+	moveWithout, statsWithout := search(false)
+	moveWith, statsWith := search(true)
+	logTest.Debugf("QS nodes %d (%d with capture limit)", statsWithout.PerDepth[0].Nodes, statsWith.PerDepth[0].Nodes)
+	assert.Zero(t, statsWithout.QSCaptureLimits)
+	assert.Greater(t, statsWith.QSCaptureLimits, uint64(0))
+	assert.Less(t, statsWith.PerDepth[0].Nodes, statsWithout.PerDepth[0].Nodes)
+	// the tactical result is preserved
+	assert.Equal(t, CreateMove(SqG3, SqG6, Normal, PtNone), moveWithout)
+	assert.Equal(t, moveWithout, moveWith)
+}
+
+func TestSeeSettings(t *testing.T) {
+	defer func() {
+		config.Settings.Search.UseQSSee = true
+		config.Settings.Search.UseSEEPruning = false
+	}()
+	config.Settings.Search.UseBook = false
+	search := func(qsSee bool, seePruning bool) Statistics {
+		config.Settings.Search.UseQSSee = qsSee
+		config.Settings.Search.UseSEEPruning = seePruning
+		search := NewSearch()
+		p := position.NewPosition("r3k2r/p1ppqpb1/bn2pnp1/3PN3/1p2P3/2N2Q1p/PPPBBPPP/R3K2R w KQkq -")
+		sl := NewSearchLimits()
+		sl.Depth = 5
+		search.StartSearch(*p, *sl)
+		search.WaitWhileSearching()
+		return search.statistics
+	}
+	defaults := search(true, false)
+	noQSSee := search(false, false)
+	seePruning := search(true, true)
+	logTest.Debugf("Nodes %d (%d without qs see, %d with see pruning)",
+		defaults.PerDepth[0].Nodes, noQSSee.PerDepth[0].Nodes, seePruning.PerDepth[0].Nodes)
+
+	// the quiescence filter changes the qsearch but does not prune
+	assert.NotEqual(t, defaults.PerDepth[0].Nodes, noQSSee.PerDepth[0].Nodes)
+	assert.Zero(t, defaults.SeePrunings)
+	assert.Zero(t, noQSSee.SeePrunings)
+
+	// see pruning in the main search is independent of the qsearch filter
+	assert.Greater(t, seePruning.SeePrunings, uint64(0))
+	assert.Less(t, seePruning.PerDepth[0].Nodes, defaults.PerDepth[0].Nodes)
+}
+
+func TestUpdateQuietStats(t *testing.T) {
+	defer func() {
+		config.Settings.Search.UseHistoryGravity = false
+		config.Settings.Search.UseFollowUpMoves = false
+	}()
+	config.Settings.Search.UseHistoryGravity = true
+	config.Settings.Search.UseFollowUpMoves = true
+	search := NewSearch()
+	p := position.NewPosition()
+	p.DoMove(CreateMove(SqE2, SqE4, Normal, PtNone))
+	p.DoMove(CreateMove(SqE7, SqE5, Normal, PtNone))
+
+	bestMove := CreateMove(SqG1, SqF3, Normal, PtNone)
+	quietsTried := moveslice.NewMoveSlice(8)
+	quietsTried.PushBack(CreateMove(SqD2, SqD4, Normal, PtNone))
+	quietsTried.PushBack(CreateMove(SqB1, SqC3, Normal, PtNone))
+	search.updateQuietStats(p, bestMove, quietsTried, 4)
+
+	// the best move gets a bonus and the tried quiet moves a malus
+	assert.Greater(t, search.history.HistoryCount[White][SqG1][SqF3], int64(0))
+	assert.Less(t, search.history.HistoryCount[White][SqD2][SqD4], int64(0))
+	assert.Less(t, search.history.HistoryCount[White][SqB1][SqC3], int64(0))
+	assert.Zero(t, search.history.HistoryCount[White][SqF1][SqC4])
+
+	// counter move to the opponent's move and follow-up to our own move
+	assert.Equal(t, bestMove, search.history.CounterMoves[SqE7][SqE5])
+	assert.Equal(t, bestMove, search.history.FollowUpMoves[SqE2][SqE4])
+
+	// a capture gets no history but the tried quiet moves a malus
+	search.history.Clear()
+	p = position.NewPosition("4k3/8/8/3p4/4P3/8/8/4K3 w - -")
+	capture := CreateMove(SqE4, SqD5, Normal, PtNone)
+	quietsTried.Clear()
+	quietsTried.PushBack(CreateMove(SqE1, SqD2, Normal, PtNone))
+	search.updateQuietStats(p, capture, quietsTried, 4)
+	assert.Zero(t, search.history.HistoryCount[White][SqE4][SqD5])
+	assert.Less(t, search.history.HistoryCount[White][SqE1][SqD2], int64(0))
+}
+
+func TestInconsistentNoMoves(t *testing.T) {
+	defer func() { config.Settings.Search.CheckNoMoves = false }()
+	config.Settings.Search.CheckNoMoves = true
+	search := NewSearch()
+	mg := movegen.NewMoveGen()
+
+	// empty the generator for a position with legal moves so the
+	// next call returns no move like a broken generator would
+	p := position.NewPosition()
+	for mg.GetNextMove(p, movegen.GenAll, false) != MoveNone {
+	}
+	assert.Equal(t, MoveNone, mg.GetNextMove(p, movegen.GenAll, false))
+	assert.True(t, search.inconsistentNoMoves(p, 4, 2, mg))
+	assert.EqualValues(t, 1, search.statistics.NoMovesErrors)
+
+	// real mate and stalemate are consistent
+	p = position.NewPosition("rnb1kbnr/pppp1ppp/8/4p3/6Pq/5P2/PPPPP2P/RNBQKBNR w KQkq -")
+	assert.False(t, search.inconsistentNoMoves(p, 4, 2, mg))
+	p = position.NewPosition("7k/5Q2/6K1/8/8/8/8/8 b - -")
+	assert.False(t, search.inconsistentNoMoves(p, 4, 2, mg))
+	assert.EqualValues(t, 1, search.statistics.NoMovesErrors)
+
+	// a search with the check still finds mates
+	config.Settings.Search.UseBook = false
+	search.NewGame()
+	sl := NewSearchLimits()
+	sl.Depth = 4
+	search.StartSearch(*position.NewPosition("7k/8/6K1/8/8/8/8/5Q2 w - -"), *sl)
+	search.WaitWhileSearching()
+	assert.Zero(t, search.statistics.NoMovesErrors)
+	assert.Equal(t, ValueCheckMate-1, search.LastSearchResult().BestValue)
+
+	// nodes where all moves have been pruned are not reported
+	sl.Depth = 8
+	search.StartSearch(*position.NewPosition("6k1/5ppp/8/8/8/8/5PPP/3R2K1 b - -"), *sl)
+	search.WaitWhileSearching()
+	assert.Greater(t, search.statistics.FpPrunings, uint64(0))
+	assert.Zero(t, search.statistics.NoMovesErrors)
+}
+
+func TestIsDangerousPawnPush(t *testing.T) {
+	p := position.NewPosition("4k3/p1P4p/6P1/1P1P4/2p5/8/4p3/4K3 w - -")
+	// push to the 7th rank even if not passed
+	assert.True(t, isDangerousPawnPush(p, CreateMove(SqG6, SqG7, Normal, PtNone)))
+	// push of a passed pawn to the 6th rank
+	assert.True(t, isDangerousPawnPush(p, CreateMove(SqD5, SqD6, Normal, PtNone)))
+	// push of a pawn to the 6th rank which is not passed
+	assert.False(t, isDangerousPawnPush(p, CreateMove(SqB5, SqB6, Normal, PtNone)))
+	// promotions are not extended
+	assert.False(t, isDangerousPawnPush(p, CreateMove(SqC7, SqC8, Promotion, Queen)))
+	// not a pawn move
+	assert.False(t, isDangerousPawnPush(p, CreateMove(SqE1, SqD2, Normal, PtNone)))
+	p = position.NewPosition("4k3/8/8/8/2p5/8/4p3/4K3 b - -")
+	// black pawn to its 6th rank which is passed
+	assert.True(t, isDangerousPawnPush(p, CreateMove(SqC4, SqC3, Normal, PtNone)))
+}
+
+func TestPassedPawnExtension(t *testing.T) {
+	defer func() { config.Settings.Search.UsePassedPawnExt = false }()
+	config.Settings.Search.UseBook = false
+	p := position.NewPosition("7k/8/8/1P6/8/8/6n1/6K1 w - -")
+	sl := NewSearchLimits()
+	sl.Depth = 5
+
+	// without the extension the promotion is beyond the horizon
+	config.Settings.Search.UsePassedPawnExt = false
+	search := NewSearch()
+	search.StartSearch(*p, *sl)
+	search.WaitWhileSearching()
+	without := search.LastSearchResult()
+	assert.NotEqual(t, "b5b6", without.BestMove.StringUci())
+
+	config.Settings.Search.UsePassedPawnExt = true
+	search = NewSearch()
+	search.StartSearch(*p, *sl)
+	search.WaitWhileSearching()
+	with := search.LastSearchResult()
+	assert.EqualValues(t, "b5b6", with.BestMove.StringUci())
+	assert.Greater(t, int(with.BestValue), int(without.BestValue))
+	assert.Greater(t, search.Statistics().PassedPawnExtension, uint64(0))
+}
+
 func TestDevelopAndTest(t *testing.T) {
 	if testing.Short() {
 		t.Skip("skipping test in short mode.")
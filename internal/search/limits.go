@@ -46,6 +46,9 @@ type Limits struct {
 	Depth int
 	Nodes uint64
 	Moves moveslice.MoveSlice
+	// only captures, checks and promotions are searched
+	// (all moves when in check) - see SearchForcing()
+	Forcing bool
 
 	//  time control
 	TimeControl bool
@@ -98,8 +98,23 @@ func LmpMovesSearched(depth int) int {
 var fp = [7]types.Value{0, 100, 200, 300, 500, 900, 1200}
 // Crafty values: {  0, 100, 150, 200,  250,  300,  400,  500, 600, 700, 800, 900, 1000, 1100, 1200, 1300 }
 
+// futility pruning in quiescence search - margin
+const qsFutilityMargin types.Value = 150
+
 // reverse futility pruning - array with margins per depth left.
 var rfp = [4]types.Value{0, 200, 400, 800}
 
+// SEE pruning - array with SEE margins per depth left.
+var seePruning = [4]types.Value{0, 100, 200, 300}
+
 // aspiration steps
 var aspirationSteps = [3]types.Value{50, 200, types.ValueMax}
+
+// aspiration windows are used for iterations deeper than this
+const aspirationMinDepth = 3
+
+// time factor for the first move of a game with OpeningSpeedup
+const openingSpeedupFactor = 0.5
+
+// penalty for root moves allowing a repetition when clearly winning
+const antiRepetitionPenalty types.Value = 10
@@ -0,0 +1,107 @@
+//
+// FrankyGo - UCI chess engine in GO for learning purposes
+//
+// MIT License
+//
+// Copyright (c) 2018-2020 Frank Kopp
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in all
+// copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE
+// SOFTWARE.
+//
+
+package search
+
+import (
+	"github.com/frankkopp/FrankyGo/internal/attacks"
+	. "github.com/frankkopp/FrankyGo/internal/config"
+	"github.com/frankkopp/FrankyGo/internal/position"
+	"github.com/frankkopp/FrankyGo/internal/transpositiontable"
+	. "github.com/frankkopp/FrankyGo/internal/types"
+)
+
+// This file contains the shallow depth forward pruning decisions of the
+// search. Each function only looks at the configuration, the depth and
+// the values given. Conditions which depend on the state of the node
+// (pv node, in check, mate search, etc.) are checked by the search.
+
+// staticPruningAllowed returns false if the given TT entry has a move from
+// a search of at least NoPruneTTMoveDepth. Such a move has been good
+// before and static prunings like razoring and RFP would risk missing it.
+func staticPruningAllowed(ttEntry *transpositiontable.TtEntry) bool {
+	minDepth := Settings.Search.NoPruneTTMoveDepth
+	return minDepth == 0 ||
+		ttEntry == nil ||
+		ttEntry.Move.MoveOf() == MoveNone ||
+		int(ttEntry.Depth) < minDepth
+}
+
+// razoring returns true when the static eval is so far below alpha
+// close to the leaves that the node can be resolved by a quiescence
+// search directly.
+func razoring(depth int, staticEval Value, alpha Value) bool {
+	return Settings.Search.UseRazoring &&
+		depth <= Settings.Search.RazorDepth &&
+		staticEval != ValueNA &&
+		staticEval <= alpha-Value(Settings.Search.RazorMargin)
+}
+
+// reverseFutility returns true and the value to return when the static
+// eval is above beta by a depth dependent margin and a beta cut off in
+// this node can be anticipated (RFP, Static Null Move Pruning).
+// https://www.chessprogramming.org/Reverse_Futility_Pruning
+func reverseFutility(depth int, staticEval Value, beta Value) (Value, bool) {
+	if !Settings.Search.UseRFP || depth >= len(rfp) || staticEval == ValueNA {
+		return ValueNA, false
+	}
+	margin := rfp[depth]
+	if staticEval-margin >= beta {
+		return staticEval - margin, true
+	}
+	return ValueNA, false
+}
+
+// futile returns true when a quiet move together with its material
+// gain and a depth dependent margin can't reach alpha.
+func futile(depth int, staticEval Value, moveGain Value, alpha Value) bool {
+	return Settings.Search.UseFP &&
+		depth < len(fp) &&
+		staticEval+moveGain+fp[depth] <= alpha
+}
+
+// lateMove returns true when enough moves have been searched at
+// the given depth that the remaining quiet moves can be pruned
+// (LMP - Late Move Pruning aka Move Count Based Pruning).
+func lateMove(depth int, movesSearched int) bool {
+	return Settings.Search.UseLmp &&
+		movesSearched >= LmpMovesSearched(depth)
+}
+
+// seeLosing returns true when the capture loses more material by SEE
+// than the depth dependent margin allows close to the leaves.
+func seeLosing(p *position.Position, move Move, depth int) bool {
+	return Settings.Search.UseSEEPruning &&
+		depth < len(seePruning) &&
+		attacks.See(p, move) < -seePruning[depth]
+}
+
+// qsFutile returns true when a move in quiescence search together
+// with its material gain and a margin can't reach alpha.
+func qsFutile(staticEval Value, moveGain Value, alpha Value) bool {
+	return Settings.Search.UseQFP &&
+		staticEval+moveGain+qsFutilityMargin <= alpha
+}
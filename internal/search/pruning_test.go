@@ -0,0 +1,126 @@
+//
+// FrankyGo - UCI chess engine in GO for learning purposes
+//
+// MIT License
+//
+// Copyright (c) 2018-2020 Frank Kopp
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in all
+// copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE
+// SOFTWARE.
+//
+
+package search
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+
+	"github.com/frankkopp/FrankyGo/internal/config"
+	"github.com/frankkopp/FrankyGo/internal/position"
+	. "github.com/frankkopp/FrankyGo/internal/types"
+)
+
+func TestRazoring(t *testing.T) {
+	defer func() {
+		config.Settings.Search.UseRazoring = true
+		config.Settings.Search.RazorMargin = 531
+		config.Settings.Search.RazorDepth = 1
+	}()
+	config.Settings.Search.UseRazoring = true
+	config.Settings.Search.RazorMargin = 500
+	config.Settings.Search.RazorDepth = 1
+	assert.True(t, razoring(1, -500, 0))
+	assert.False(t, razoring(1, -499, 0))
+	assert.False(t, razoring(2, -1000, 0))
+	assert.False(t, razoring(1, ValueNA, 0))
+	config.Settings.Search.RazorDepth = 3
+	assert.True(t, razoring(3, -1000, 0))
+	assert.False(t, razoring(4, -1000, 0))
+	config.Settings.Search.UseRazoring = false
+	assert.False(t, razoring(1, -1000, 0))
+}
+
+func TestReverseFutility(t *testing.T) {
+	defer func() { config.Settings.Search.UseRFP = true }()
+	config.Settings.Search.UseRFP = true
+	value, ok := reverseFutility(1, 300, 100)
+	assert.True(t, ok)
+	assert.EqualValues(t, 300-rfp[1], value)
+	_, ok = reverseFutility(1, 299, 100)
+	assert.False(t, ok)
+	_, ok = reverseFutility(3, 1000, 100)
+	assert.True(t, ok)
+	_, ok = reverseFutility(len(rfp), 5000, 100)
+	assert.False(t, ok)
+	_, ok = reverseFutility(1, ValueNA, ValueMin)
+	assert.False(t, ok)
+	config.Settings.Search.UseRFP = false
+	_, ok = reverseFutility(1, 5000, 100)
+	assert.False(t, ok)
+}
+
+func TestFutile(t *testing.T) {
+	defer func() { config.Settings.Search.UseFP = true }()
+	config.Settings.Search.UseFP = true
+	assert.True(t, futile(1, -100, 0, 0))
+	assert.False(t, futile(1, -99, 0, 0))
+	// the material gain of the move is considered
+	assert.False(t, futile(1, -100, Pawn.ValueOf(), 0))
+	assert.True(t, futile(2, -200, 0, 0))
+	assert.False(t, futile(len(fp), -5000, 0, 0))
+	config.Settings.Search.UseFP = false
+	assert.False(t, futile(1, -5000, 0, 0))
+}
+
+func TestLateMove(t *testing.T) {
+	defer func() { config.Settings.Search.UseLmp = true }()
+	config.Settings.Search.UseLmp = true
+	assert.False(t, lateMove(1, LmpMovesSearched(1)-1))
+	assert.True(t, lateMove(1, LmpMovesSearched(1)))
+	assert.False(t, lateMove(5, LmpMovesSearched(1)))
+	config.Settings.Search.UseLmp = false
+	assert.False(t, lateMove(1, 100))
+}
+
+func TestSeeLosing(t *testing.T) {
+	defer func() { config.Settings.Search.UseSEEPruning = false }()
+	config.Settings.Search.UseSEEPruning = true
+	// queen takes a pawn defended by a pawn
+	p := position.NewPosition("4k3/8/2p5/3p4/8/8/3Q4/4K3 w - -")
+	qxd5 := CreateMove(SqD2, SqD5, Normal, PtNone)
+	assert.True(t, seeLosing(p, qxd5, 1))
+	assert.True(t, seeLosing(p, qxd5, 3))
+	assert.False(t, seeLosing(p, qxd5, len(seePruning)))
+	// pawn takes an undefended pawn
+	p = position.NewPosition("4k3/8/8/3p4/4P3/8/8/4K3 w - -")
+	assert.False(t, seeLosing(p, CreateMove(SqE4, SqD5, Normal, PtNone), 1))
+	config.Settings.Search.UseSEEPruning = false
+	p = position.NewPosition("4k3/8/2p5/3p4/8/8/3Q4/4K3 w - -")
+	assert.False(t, seeLosing(p, qxd5, 1))
+}
+
+func TestQsFutile(t *testing.T) {
+	defer func() { config.Settings.Search.UseQFP = true }()
+	config.Settings.Search.UseQFP = true
+	assert.True(t, qsFutile(-qsFutilityMargin, 0, 0))
+	assert.False(t, qsFutile(-qsFutilityMargin+1, 0, 0))
+	assert.False(t, qsFutile(-qsFutilityMargin, Pawn.ValueOf(), 0))
+	config.Settings.Search.UseQFP = false
+	assert.False(t, qsFutile(-1000, 0, 0))
+}
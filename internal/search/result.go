@@ -39,19 +39,32 @@ import (
 
 // Result stores the result of a search. If BestMove is not MoveNone
 // it can be assumed that all values are valid.
+// It is populated at the end of every search and is the single source
+// for the bestmove sent to the UCI ui.
 type Result struct {
 	BestMove    Move
 	BestValue   Value
 	PonderMove  Move
 	SearchTime  time.Duration
 	SearchDepth int
-	ExtraDepth  int
+	ExtraDepth  int // selective search depth
 	BookMove    bool
+	Nodes       uint64
+	Nps         uint64
 	Pv          moveslice.MoveSlice
+	// bound of BestValue - EXACT, ALPHA (upper bound) or BETA (lower
+	// bound) when the search was stopped after an aspiration fail low or
+	// fail high and Vnone for book moves
+	Bound ValueType
+	// moves to mate for mate values (negative when getting mated)
+	// and 0 otherwise
+	Mate int
+	// nodes searched per root move over all iterations
+	RootMoveNodes map[Move]uint64
 }
 
 func (searchResult *Result) String() string {
-	return out.Sprintf("bestmove = %s, value = %s (%d), ponder = %s, search time = %d ms, search dept = %d/%d, was book move = %v, pv = %s",
-		searchResult.BestMove.StringUci(), searchResult.BestValue.String(), searchResult.BestValue, searchResult.PonderMove.StringUci(), searchResult.SearchTime.Milliseconds(),
-		searchResult.SearchDepth, searchResult.ExtraDepth, searchResult.BookMove, searchResult.Pv.StringUci())
+	return out.Sprintf("bestmove = %s, value = %s (%d) %s, ponder = %s, search time = %d ms, search dept = %d/%d, nodes = %d, nps = %d, was book move = %v, pv = %s",
+		searchResult.BestMove.StringUci(), searchResult.BestValue.String(), searchResult.BestValue, searchResult.Bound.String(), searchResult.PonderMove.StringUci(), searchResult.SearchTime.Milliseconds(),
+		searchResult.SearchDepth, searchResult.ExtraDepth, searchResult.Nodes, searchResult.Nps, searchResult.BookMove, searchResult.Pv.StringUci())
 }
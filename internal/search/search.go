@@ -29,6 +29,7 @@ package search
 import (
 	"context"
 	"math/rand"
+	"path/filepath"
 	"time"
 
 	"golang.org/x/sync/semaphore"
@@ -70,6 +71,15 @@ type Search struct {
 	// history heuristics
 	history *history.History
 
+	// seed for the root move noise - changes with every new game
+	rootNoiseSeed uint64
+
+	// random source for the blunder handicap
+	blunderRand *rand.Rand
+
+	// random source for choosing book moves - reseeded with every new game
+	bookRand *rand.Rand
+
 	// previous search
 	lastSearchResult *Result
 
@@ -84,8 +94,17 @@ type Search struct {
 	nodesVisited      uint64
 	mg                []*movegen.Movegen
 	pv                []*moveslice.MoveSlice
+	quietsTried       []*moveslice.MoveSlice
+	staticEvals       [MaxDepth + 1]Value
 	rootMoves         *moveslice.MoveSlice
+	rootMoveNodes     map[Move]uint64
+	repetitionMoves   map[Move]bool
 	hadBookMove       bool
+	outOfBook         bool
+	mateSearch        bool
+	forcingSearch     bool
+	rootBound         ValueType
+	rootBestBias      Value // root move bias included in the best root value
 	lastUciUpdateTime time.Time
 	statistics        Statistics
 }
@@ -107,6 +126,9 @@ func NewSearch() *Search {
 		tt:                nil,
 		eval:              evaluator.NewEvaluator(),
 		history:           history.NewHistory(),
+		rootNoiseSeed:     uint64(time.Now().UnixNano()),
+		blunderRand:       rand.New(rand.NewSource(time.Now().UnixNano())),
+		bookRand:          rand.New(rand.NewSource(time.Now().UnixNano())),
 		lastSearchResult:  nil,
 		stopFlag:          false,
 		startTime:         time.Time{},
@@ -118,8 +140,13 @@ func NewSearch() *Search {
 		nodesVisited:      0,
 		mg:                nil,
 		pv:                nil,
+		quietsTried:       nil,
 		rootMoves:         nil,
+		rootMoveNodes:     nil,
 		hadBookMove:       false,
+		outOfBook:         false,
+		mateSearch:        false,
+		forcingSearch:     false,
 		lastUciUpdateTime: time.Time{},
 		statistics:        Statistics{},
 	}
@@ -132,8 +159,11 @@ func (s *Search) NewGame() {
 	s.StopSearch()
 	if s.tt != nil {
 		s.tt.Clear()
-		s.history = history.NewHistory()
 	}
+	s.history.Clear()
+	s.rootNoiseSeed = uint64(time.Now().UnixNano())
+	s.bookRand.Seed(time.Now().UnixNano())
+	s.outOfBook = false
 }
 
 // StartSearch starts the search on the given position with
@@ -154,6 +184,49 @@ func (s *Search) StartSearch(p position.Position, sl Limits) {
 	s.initSemaphore.Release(1)
 }
 
+// AnalyzeBatch searches the given positions one after the other with
+// the given search limits and returns the results in the same order.
+// This allows analysis tools to evaluate many positions within one
+// process. The TT is reused between the positions unless clearHash is
+// set. A running search is stopped first.
+func (s *Search) AnalyzeBatch(positions []position.Position, sl Limits, clearHash bool) []Result {
+	s.StopSearch()
+	results := make([]Result, 0, len(positions))
+	for _, p := range positions {
+		if clearHash && s.tt != nil {
+			s.tt.Clear()
+		}
+		s.StartSearch(p, sl)
+		s.WaitWhileSearching()
+		results = append(results, s.LastSearchResult())
+	}
+	return results
+}
+
+// SearchForcing searches the given position considering only forcing
+// moves - captures, checks and promotions. When in check all evasions
+// are searched. A side which is not in check may always stand pat with
+// the static evaluation instead of playing a forcing move. This finds
+// forced tactical lines (e.g. a mate by checks) much faster than a full
+// search and is meant for tools explaining tactics. The result holds
+// the forcing pv and its value. If there is no forcing move in the
+// position the best move is MoveNone and the value is the static
+// evaluation. As the values of a forcing search are not valid for a
+// normal search the TT is cleared before and after the search.
+func (s *Search) SearchForcing(p position.Position, sl Limits) Result {
+	s.StopSearch()
+	if s.tt != nil {
+		s.tt.Clear()
+	}
+	sl.Forcing = true
+	s.StartSearch(p, sl)
+	s.WaitWhileSearching()
+	if s.tt != nil {
+		s.tt.Clear()
+	}
+	return s.LastSearchResult()
+}
+
 // StopSearch stops a running search as quickly as possible.
 // The search stops gracefully and a result will be sent to UCI.
 // This will wait for the search to be stopped before returning.
@@ -234,6 +307,40 @@ func (s *Search) ClearHash() {
 	}
 }
 
+// ClearHistory clears the history counters and counter moves.
+// Is ignored with a warning while searching.
+func (s *Search) ClearHistory() {
+	if s.IsSearching() {
+		msg := "Can't clear history while searching."
+		s.sendInfoStringToUci(msg)
+		s.log.Warning(msg)
+		return
+	}
+	s.history.Clear()
+	s.sendInfoStringToUci("History cleared")
+}
+
+// ClearCaches clears all caches and states which carry over from one
+// search to the next - the transposition table and the history heuristics.
+// With WarmUpCaches the cleared transposition table is pre-touched so
+// the memory is allocated before the next search starts. This makes the
+// node count of a search independent of previous searches e.g. for
+// benchmarks. Is ignored with a warning while searching.
+func (s *Search) ClearCaches() {
+	if s.IsSearching() {
+		s.log.Warning("Can't clear caches while searching.")
+		return
+	}
+	if s.tt != nil {
+		s.tt.Clear()
+		if config.Settings.Search.WarmUpCaches {
+			s.tt.WarmUp()
+		}
+	}
+	s.history.Clear()
+	s.log.Debug("Caches cleared")
+}
+
 // ResizeCache resizes and clears the transposition table.
 // Is ignored with a warning while searching.
 func (s *Search) ResizeCache() {
@@ -291,6 +398,13 @@ func (s *Search) run(position *position.Position, sl *Limits) {
 	// setup and report search limits
 	s.setupSearchLimits(position, sl)
 
+	// the values of the evaluation free mate search are not valid for
+	// a normal search and vice versa - the TT is cleared before and
+	// after the search
+	if s.mateSearch && s.tt != nil {
+		s.tt.Clear()
+	}
+
 	// when not pondering and search is time controlled start timer
 	if s.searchLimits.TimeControl && !s.searchLimits.Ponder {
 		s.startTimer()
@@ -298,13 +412,24 @@ func (s *Search) run(position *position.Position, sl *Limits) {
 
 	// check for opening book move when we have a time controlled game
 	bookMove := MoveNone
-	if s.book != nil && config.Settings.Search.UseBook && sl.TimeControl {
-		bookEntry, found := s.book.GetEntry(position.ZobristKey())
-		if found && len(bookEntry.Moves) > 0 {
-			// choose move - random for now
-			rand.Seed(int64(time.Now().Nanosecond()))
-			bookMove = Move(bookEntry.Moves[rand.Intn(len(bookEntry.Moves))].Move)
-			s.log.Debug("Opening Book: Choosing book move: ", bookMove.StringUci())
+	if s.book != nil && config.Settings.Search.UseBook && sl.TimeControl && !sl.Forcing {
+		if maxPly := config.Settings.Search.BookMaxPly; maxPly > 0 && position.GamePly() >= maxPly {
+			s.log.Infof("Opening Book: Book limited to %d plies", maxPly)
+		} else {
+			bookEntry, found := s.book.GetEntry(position.ZobristKey())
+			if found && len(bookEntry.Moves) > 0 {
+				bookMove = s.chooseBookMove(&bookEntry)
+				s.log.Debug("Opening Book: Choosing book move: ", bookMove.StringUci())
+			}
+		}
+		// signal once when we leave the book
+		if bookMove != MoveNone {
+			s.outOfBook = false
+		} else if !s.outOfBook {
+			s.outOfBook = true
+			msg := out.Sprintf("Out of book at ply %d", position.GamePly())
+			s.sendInfoStringToUci(msg)
+			s.log.Info("Opening Book: " + msg)
 		}
 	} else {
 		s.log.Info("Opening Book: Not using book")
@@ -321,13 +446,16 @@ func (s *Search) run(position *position.Position, sl *Limits) {
 	// Initialize ply based data
 	s.mg = make([]*movegen.Movegen, 0, MaxDepth+1)
 	s.pv = make([]*moveslice.MoveSlice, 0, MaxDepth+1)
+	s.quietsTried = make([]*moveslice.MoveSlice, 0, MaxDepth+1)
 	for i := 0; i <= MaxDepth; i++ {
 		newMoveGen := movegen.NewMoveGen()
-		if config.Settings.Search.UseHistoryCounter || config.Settings.Search.UseCounterMoves {
+		if config.Settings.Search.UseHistoryCounter || config.Settings.Search.UseCounterMoves ||
+			config.Settings.Search.UseFollowUpMoves {
 			newMoveGen.SetHistoryData(s.history)
 		}
 		s.mg = append(s.mg, newMoveGen)
 		s.pv = append(s.pv, moveslice.NewMoveSlice(MaxDepth+1))
+		s.quietsTried = append(s.quietsTried, moveslice.NewMoveSlice(MaxMoves))
 	}
 
 	// release the init phase lock to signal the calling go routine
@@ -339,6 +467,9 @@ func (s *Search) run(position *position.Position, sl *Limits) {
 	if bookMove == MoveNone {
 		// no book move --> do search
 		searchResult = s.iterativeDeepening(position)
+		if s.mateSearch && s.tt != nil {
+			s.tt.Clear()
+		}
 	} else {
 		// create result based on book move
 		searchResult = &Result{BestMove: bookMove, BookMove: true}
@@ -357,15 +488,31 @@ func (s *Search) run(position *position.Position, sl *Limits) {
 		}
 	}
 
-	// update search result with search time and pv
+	// update search result with search time, nodes and pv
 	searchResult.SearchTime = time.Since(s.startTime)
+	searchResult.Nodes = s.nodesVisited
+	searchResult.Nps = util.Nps(s.nodesVisited, searchResult.SearchTime)
 	searchResult.Pv = *s.pv[0]
+	searchResult.Mate = searchResult.BestValue.MateIn()
+	if !searchResult.BookMove {
+		searchResult.Bound = s.rootBound
+		// handicap - occasionally replace the best move with a random move
+		if !sl.Infinite {
+			s.blunder(position, searchResult)
+		}
+	}
+
+	// never send an illegal move to the UCI ui
+	// (a forcing search might not have any forcing move)
+	if !sl.Forcing || searchResult.BestMove != MoveNone {
+		s.validateResult(position, searchResult)
+	}
 
 	// print stats to log
 	s.log.Info(out.Sprintf("Search finished after %s", searchResult.SearchTime))
 	s.log.Info(out.Sprintf("Search depth was %d(%d) with %d nodes visited. NPS = %d nps",
-		s.statistics.CurrentSearchDepth, s.statistics.CurrentExtraSearchDepth, s.nodesVisited,
-		util.Nps(s.nodesVisited, searchResult.SearchTime)))
+		s.statistics.CurrentSearchDepth, s.statistics.CurrentExtraSearchDepth, searchResult.Nodes,
+		searchResult.Nps))
 	s.log.Debugf("Search stats: %s", s.statistics.String())
 	// s.log.Debugf("History stats: %s", s.history.String())
 
@@ -405,18 +552,22 @@ func (s *Search) iterativeDeepening(position *position.Position) *Result {
 
 	// prepare search result
 	var result *Result
+	s.rootBound = EXACT
+	s.rootBestBias = 0
 
 	// check repetition and 50 moves
 	if s.checkDrawRepAnd50(position, 2) {
 		msg := "Search called on DRAW by Repetition or 50-moves-rule"
 		s.sendInfoStringToUci(msg)
 		s.log.Warning(msg)
-		result = &Result{BestValue: ValueDraw}
+		result = &Result{BestValue: s.drawValue(position)}
 		return result
 	}
 
 	// generate all legal root moves
 	s.rootMoves = s.mg[0].GenerateLegalMoves(position, movegen.GenAll)
+	s.rootMoveNodes = make(map[Move]uint64, s.rootMoves.Len())
+	s.repetitionMoves = s.findRepetitionMoves(position)
 
 	// check if there are legal moves - if not it's mate or stalemate
 	if s.rootMoves.Len() == 0 {
@@ -431,11 +582,24 @@ func (s *Search) iterativeDeepening(position *position.Position) *Result {
 			msg := "Search called on a stalemate position"
 			s.sendInfoStringToUci(msg)
 			s.log.Warning(msg)
-			result = &Result{BestValue: ValueDraw}
+			result = &Result{BestValue: s.drawValue(position)}
 		}
 		return result
 	}
 
+	// in a forcing search only captures, checks and promotions are
+	// searched at the root unless we are in check
+	if s.forcingSearch && !position.HasCheck() {
+		s.rootMoves = s.forcingMoves(position, s.rootMoves)
+		if s.rootMoves.Len() == 0 {
+			msg := "Forcing search called on a position without forcing moves"
+			s.sendInfoStringToUci(msg)
+			s.log.Info(msg)
+			result = &Result{BestValue: s.evaluate(position, 0)}
+			return result
+		}
+	}
+
 	// add some extra time for the move after the last book move
 	// hadBookMove move will be true at his point if we ever had
 	// a book move.
@@ -451,11 +615,12 @@ func (s *Search) iterativeDeepening(position *position.Position) *Result {
 	if s.searchLimits.Depth > 0 {
 		maxDepth = s.searchLimits.Depth
 	}
-
-	// Max window search in preparation for aspiration window search
-	// not needed yet
-	alpha := ValueMin
-	beta := ValueMax
+	// a mate in n moves is found at the latest after 2n-1 plies
+	// so in the evaluation free mate search there is no point in
+	// searching any deeper
+	if s.mateSearch && maxDepth > 2*s.searchLimits.Mate-1 {
+		maxDepth = 2*s.searchLimits.Mate - 1
+	}
 
 	// ###########################################
 	// ### BEGIN Iterative Deepening
@@ -464,6 +629,7 @@ func (s *Search) iterativeDeepening(position *position.Position) *Result {
 
 		// update search counter
 		s.nodesVisited++
+		s.statistics.PerDepth[iterationDepth].Nodes++
 
 		s.statistics.CurrentIterationDepth = iterationDepth
 		s.statistics.CurrentSearchDepth = s.statistics.CurrentIterationDepth
@@ -473,28 +639,58 @@ func (s *Search) iterativeDeepening(position *position.Position) *Result {
 
 		// ###########################################
 		// Start actual alpha beta search
-		s.rootSearch(position, iterationDepth, alpha, beta)
+		// Aspiration windows are only used after a few iterations
+		// and not when the last iteration found a mate.
+		// The root moves are sorted by value after each iteration
+		// so the first root move holds the last best value.
+		if config.Settings.Search.UseAspiration &&
+			iterationDepth > aspirationMinDepth &&
+			!s.rootMoves.At(0).ValueOf().IsCheckMateValue() {
+			s.aspirationSearch(position, iterationDepth, s.rootMoves.At(0).ValueOf())
+		} else {
+			s.rootSearch(position, iterationDepth, ValueMin, ValueMax)
+		}
 		// ###########################################
 
 		// check if we need to stop
 		// doing this after the first iteration ensures that
 		// we have done at least one complete search and have
 		// a pv (best) move
-		// If we only have one move to play also stop the search
-		if !s.stopConditions() && s.rootMoves.Len() > 1 {
+		// If we only have one move to play (UseOnlyMove) also stop the
+		// search but report the pv and value of the first iteration
+		// In the evaluation free mate search stop when a mate has been found
+		onlyMove := config.Settings.Search.UseOnlyMove && s.rootMoves.Len() == 1
+		if !s.stopConditions() && !onlyMove && !s.mateFound() {
 			// sort root moves for the next iteration
 			s.rootMoves.Sort()
 			s.statistics.CurrentBestRootMove = s.pv[0].At(0)
 			s.statistics.CurrentBestRootMoveValue = s.pv[0].At(0).ValueOf()
 			// update UCI GUI
 			s.sendIterationEndInfoToUci()
+			if config.Settings.Search.ReportRootMoveNodes {
+				s.sendRootMoveNodesToUci()
+			}
 		} else {
+			// a completed iteration is always reported
+			if !s.stopConditions() {
+				if onlyMove {
+					s.log.Debugf("Only one move to play: %s", s.pv[0].At(0).MoveOf().StringUci())
+				}
+				s.statistics.CurrentBestRootMove = s.pv[0].At(0)
+				s.statistics.CurrentBestRootMoveValue = s.pv[0].At(0).ValueOf()
+				s.sendIterationEndInfoToUci()
+			}
 			break
 		}
 	}
 	// ### END OF Iterative Deepening
 	// ###########################################
 
+	// report the best reply to each root move
+	if config.Settings.Search.ShowRefutations {
+		s.sendRefutationsToUci(position)
+	}
+
 	// update searchResult here
 	// best move is pv[0][0] - we need to make sure this array entry exists at this time
 	// best value is pv[0][0].valueOf
@@ -508,6 +704,12 @@ func (s *Search) iterativeDeepening(position *position.Position) *Result {
 		BookMove:    false,
 	}
 
+	// copy the nodes searched per root move
+	result.RootMoveNodes = make(map[Move]uint64, len(s.rootMoveNodes))
+	for m, n := range s.rootMoveNodes {
+		result.RootMoveNodes[m] = n
+	}
+
 	// see if we have a move we could ponder on
 	if s.pv[0].Len() > 1 {
 		result.PonderMove = s.pv[0].At(1).MoveOf()
@@ -522,12 +724,54 @@ func (s *Search) iterativeDeepening(position *position.Position) *Result {
 				result.PonderMove = ttEntry.Move
 				s.log.Debugf(out.Sprintf("Using ponder move from hash: %s", result.PonderMove.StringUci()))
 			}
+			position.UndoMove()
 		}
 	}
 
 	return result
 }
 
+// aspirationSearch searches the root moves with a narrow window around
+// the value of the previous iteration. If the search fails high or low
+// the window is widened by the next aspiration step and the root moves
+// are searched again. Each re-search is reported to the UCI ui with the
+// bound of the failed search.
+// If the search is stopped during a re-search after a fail low the best
+// move, pv and exact value of the previous iteration are used.
+func (s *Search) aspirationSearch(position *position.Position, depth int, bestValue Value) Value {
+	previousPv := s.pv[0].Clone()
+	alphaStep, betaStep := 0, 0
+	alpha := Max(bestValue-aspirationSteps[alphaStep], ValueMin)
+	beta := Min(bestValue+aspirationSteps[betaStep], ValueMax)
+	for {
+		value := s.rootSearch(position, depth, alpha, beta)
+		if s.stopConditions() {
+			if s.rootBound == ALPHA && s.pv[0].At(0).MoveOf() == previousPv.At(0).MoveOf() {
+				savePV(previousPv.PopFront(), previousPv, s.pv[0])
+				s.rootBound = EXACT
+			}
+			return value
+		}
+		switch {
+		case value <= alpha && alpha > ValueMin: // fail low
+			s.rootBound = ALPHA
+			s.statistics.AspirationResearches++
+			s.sendAspirationResearchInfoToUci(value-s.rootBestBias, "upperbound")
+			alphaStep++
+			alpha = Max(bestValue-aspirationSteps[alphaStep], ValueMin)
+		case value >= beta && beta < ValueMax: // fail high
+			s.rootBound = BETA
+			s.statistics.AspirationResearches++
+			s.sendAspirationResearchInfoToUci(value-s.rootBestBias, "lowerbound")
+			betaStep++
+			beta = Min(bestValue+aspirationSteps[betaStep], ValueMax)
+		default:
+			s.rootBound = EXACT
+			return value
+		}
+	}
+}
+
 // Initialize sets up opening book, transposition table
 // and other potentially time consuming setup tasks
 // This can be called several times without doing
@@ -539,15 +783,27 @@ func (s *Search) initialize() {
 			s.book = openingbook.NewBook()
 			bookPath := config.Settings.Search.BookPath
 			bookFile := config.Settings.Search.BookFile
-			bookFormat, found := openingbook.FormatFromString[config.Settings.Search.BookFormat]
-			if !found {
-				s.log.Warningf("Book format invalid %s", config.Settings.Search.BookFormat)
-				s.book = nil
-			}
-			err := s.book.Initialize(bookPath, bookFile, bookFormat, true, false)
-			if err != nil {
-				s.log.Warningf("Book could not be initialized: %s (%s)", bookPath, err)
-				s.book = nil
+			if bookFiles := config.Settings.Search.BookFiles; len(bookFiles) > 0 {
+				paths := make([]string, 0, len(bookFiles))
+				for _, f := range bookFiles {
+					paths = append(paths, filepath.Join(bookPath, f))
+				}
+				err := s.book.LoadMany(paths, []string{config.Settings.Search.BookFormat})
+				if err != nil {
+					s.log.Warningf("Books could not be initialized: %s (%s)", bookPath, err)
+					s.book = nil
+				}
+			} else {
+				bookFormat, found := openingbook.FormatFromString[config.Settings.Search.BookFormat]
+				if !found {
+					s.log.Warningf("Book format invalid %s", config.Settings.Search.BookFormat)
+					s.book = nil
+				}
+				err := s.book.Initialize(bookPath, bookFile, bookFormat, true, false)
+				if err != nil {
+					s.log.Warningf("Book could not be initialized: %s (%s)", bookPath, err)
+					s.book = nil
+				}
 			}
 		}
 	} else {
@@ -568,6 +824,35 @@ func (s *Search) initialize() {
 	}
 }
 
+// effectiveContempt returns the contempt for the given position. When
+// phase contempt is used the configured contempt is interpolated by the
+// game phase from ContemptMax in the opening to 0 in the end game.
+func (s *Search) effectiveContempt(position *position.Position) Value {
+	contempt := float64(config.Settings.Search.ContemptMax)
+	if config.Settings.Search.UsePhaseContempt {
+		contempt *= position.GamePhaseFactor()
+	}
+	return Value(contempt)
+}
+
+// drawValue returns the value of a draw from the view of the next
+// player of the given position. The evaluator computes the draw value
+// so that evaluation and search use the same value.
+func (s *Search) drawValue(position *position.Position) Value {
+	if s.mateSearch {
+		return ValueDraw
+	}
+	return s.eval.DrawValue(position)
+}
+
+// mateFound returns true when in the evaluation free mate search the
+// best root move of the last iteration mates in n moves or less.
+func (s *Search) mateFound() bool {
+	return s.mateSearch &&
+		s.pv[0].Len() > 0 &&
+		s.pv[0].At(0).ValueOf() >= ValueCheckMate-Value(2*s.searchLimits.Mate-1)
+}
+
 // stopConditions checks if stopFlag is set or if nodesVisited have
 // reached a potential maximum set in the search limits.
 func (s *Search) stopConditions() bool {
@@ -577,9 +862,22 @@ func (s *Search) stopConditions() bool {
 	if s.searchLimits.Nodes > 0 && s.nodesVisited >= s.searchLimits.Nodes {
 		s.stopFlag = true
 	}
+	if config.Settings.Search.MaxNps > 0 {
+		s.throttleNps()
+	}
 	return s.stopFlag
 }
 
+// throttleNps sleeps as long as the search is ahead of the nodes per
+// second given by MaxNps. This is used in tests to make time based stop
+// conditions independent of the speed of the machine.
+func (s *Search) throttleNps() {
+	target := time.Duration(float64(s.nodesVisited) / float64(config.Settings.Search.MaxNps) * float64(time.Second))
+	if ahead := target - time.Since(s.startTime); ahead > 0 {
+		time.Sleep(ahead)
+	}
+}
+
 // setupSearchLimits reports to log.debug on search limits for the search
 // and sets up time control.
 func (s *Search) setupSearchLimits(position *position.Position, sl *Limits) {
@@ -589,8 +887,22 @@ func (s *Search) setupSearchLimits(position *position.Position, sl *Limits) {
 	if sl.Ponder {
 		s.log.Info("Search mode: Ponder")
 	}
+	contempt := s.effectiveContempt(position)
+	s.eval.SetContempt(position.NextPlayer(), contempt)
+	if contempt != 0 {
+		s.log.Infof("Search mode: Contempt %d", contempt)
+	}
+	s.mateSearch = false
 	if sl.Mate > 0 {
 		s.log.Infof("Search mode: Search for mate in %d", sl.Mate)
+		if config.Settings.Search.MateSearchMode {
+			s.log.Info("Search mode: Evaluation free mate search")
+			s.mateSearch = true
+		}
+	}
+	s.forcingSearch = sl.Forcing
+	if s.forcingSearch {
+		s.log.Info("Search mode: Forcing moves only")
 	}
 	if sl.TimeControl {
 		s.timeLimit = s.setupTimeControl(position, sl)
@@ -608,6 +920,17 @@ func (s *Search) setupSearchLimits(position *position.Position, sl *Limits) {
 	} else {
 		s.log.Info("Search mode: No time control")
 	}
+	// handicap - cap the search effort when playing but not when analysing
+	if !sl.Infinite {
+		if maxDepth := config.Settings.Search.MaxDepthPerMove; maxDepth > 0 && (sl.Depth == 0 || sl.Depth > maxDepth) {
+			s.log.Infof("Search mode: Handicap max depth per move: %d", maxDepth)
+			sl.Depth = maxDepth
+		}
+		if maxNodes := config.Settings.Search.MaxNodesPerMove; maxNodes > 0 && (sl.Nodes == 0 || sl.Nodes > maxNodes) {
+			s.log.Infof(out.Sprintf("Search mode: Handicap max nodes per move: %d", maxNodes))
+			sl.Nodes = maxNodes
+		}
+	}
 	if sl.Depth > 0 {
 		s.log.Debugf("Search mode: Depth limited  : %d", sl.Depth)
 	}
@@ -619,6 +942,130 @@ func (s *Search) setupSearchLimits(position *position.Position, sl *Limits) {
 	}
 }
 
+// blunder replaces the best move of the given search result with a random
+// legal move with the probability given by BlunderProbability. This is a
+// crude way to weaken the engine for beginners. As the random move has not
+// been searched as best move the value, pv and ponder move are replaced
+// as well.
+func (s *Search) blunder(p *position.Position, result *Result) {
+	if config.Settings.Search.BlunderProbability <= 0 || s.blunderRand.Float64() >= config.Settings.Search.BlunderProbability {
+		return
+	}
+	legalMoves := movegen.NewMoveGen().GenerateLegalMoves(p, movegen.GenAll)
+	if legalMoves.Len() == 0 {
+		return
+	}
+	randomMove := legalMoves.At(s.blunderRand.Intn(legalMoves.Len())).MoveOf()
+	s.log.Debugf("Handicap: Replacing best move %s with random move %s", result.BestMove.StringUci(), randomMove.StringUci())
+	result.BestMove = randomMove
+	result.BestValue = ValueNA
+	result.Bound = Vnone
+	result.Mate = 0
+	result.PonderMove = MoveNone
+	result.Pv = *moveslice.NewMoveSlice(MaxDepth + 1)
+	result.Pv.PushBack(randomMove)
+}
+
+// chooseBookMove chooses a move from the given book entry weighted by how
+// often the move has been played in the book games. With the probability
+// given by BookVariety the most played move is skipped to vary the
+// openings between games.
+func (s *Search) chooseBookMove(entry *openingbook.BookEntry) Move {
+	moves := make([]Move, 0, len(entry.Moves))
+	weights := make([]int, 0, len(entry.Moves))
+	top := 0
+	for i, m := range entry.Moves {
+		weight := 1
+		if next, found := s.book.GetEntry(position.Key(m.NextEntry)); found && next.Counter > 1 {
+			weight = next.Counter
+		}
+		moves = append(moves, Move(m.Move))
+		weights = append(weights, weight)
+		if weight > weights[top] {
+			top = i
+		}
+	}
+	if len(moves) > 1 && s.bookRand.Float64() < config.Settings.Search.BookVariety {
+		s.log.Debugf("Opening Book: Skipping top book move %s", moves[top].StringUci())
+		moves = append(moves[:top], moves[top+1:]...)
+		weights = append(weights[:top], weights[top+1:]...)
+	}
+	total := 0
+	for _, w := range weights {
+		total += w
+	}
+	r := s.bookRand.Intn(total)
+	for i, w := range weights {
+		if r < w {
+			return moves[i]
+		}
+		r -= w
+	}
+	return moves[len(moves)-1]
+}
+
+// validateResult re-validates the best move, the ponder move and the pv
+// of the given search result against the legal moves of the given
+// position. The pv is assembled from killer and TT moves as well and
+// an illegal best move would lose the game in a tournament. If the best
+// move is not legal it is replaced by the best legal root move. The pv
+// is cut at the first illegal move and an illegal ponder move is
+// removed.
+func (s *Search) validateResult(p *position.Position, result *Result) {
+	mg := movegen.NewMoveGen()
+	legalMoves := mg.GenerateLegalMoves(p, movegen.GenAll).Clone()
+	if legalMoves.Len() == 0 {
+		return
+	}
+
+	if !mg.ValidateMove(p, result.BestMove) {
+		s.log.Warningf("Best move %s is not legal - using best legal root move", result.BestMove.StringUci())
+		bestMove := legalMoves.At(0).MoveOf()
+		bestValue := ValueNA
+		if s.rootMoves != nil {
+			for _, m := range *s.rootMoves {
+				if m.ValueOf() > bestValue && mg.ValidateMove(p, m.MoveOf()) {
+					bestMove = m.MoveOf()
+					bestValue = m.ValueOf()
+				}
+			}
+		}
+		result.BestMove = bestMove
+		result.BestValue = bestValue
+		result.PonderMove = MoveNone
+		result.Pv = *moveslice.NewMoveSlice(MaxDepth + 1)
+	}
+
+	// keep only the legal part of the pv starting with the best move
+	pv := moveslice.NewMoveSlice(MaxDepth + 1)
+	if result.Pv.Len() > 0 && result.Pv.At(0).Equals(result.BestMove) {
+		for i := 0; i < result.Pv.Len(); i++ {
+			if !mg.ValidateMove(p, result.Pv.At(i).MoveOf()) {
+				s.log.Warningf("Pv move %s is not legal - pv is cut", result.Pv.At(i).StringUci())
+				break
+			}
+			pv.PushBack(result.Pv.At(i))
+			p.DoMove(result.Pv.At(i).MoveOf())
+		}
+		for i := 0; i < pv.Len(); i++ {
+			p.UndoMove()
+		}
+	} else {
+		pv.PushBack(result.BestMove)
+	}
+	result.Pv = *pv
+
+	// the ponder move must be legal after the best move
+	if result.PonderMove != MoveNone {
+		p.DoMove(result.BestMove)
+		if !mg.ValidateMove(p, result.PonderMove) {
+			s.log.Warningf("Ponder move %s is not legal - ponder move removed", result.PonderMove.StringUci())
+			result.PonderMove = MoveNone
+		}
+		p.UndoMove()
+	}
+}
+
 // setupTimeControl sets up time control according to the given search limits
 // and returns a limit on the duration for the current search.
 func (s *Search) setupTimeControl(p *position.Position, sl *Limits) time.Duration {
@@ -634,9 +1081,14 @@ func (s *Search) setupTimeControl(p *position.Position, sl *Limits) time.Duratio
 		// moves left
 		movesLeft := int64(sl.MovesToGo)
 		if movesLeft == 0 { // default
-			// we estimate minimum 15 more moves in final game phases
-			// in early game phases this grows up to 40
-			movesLeft = int64(15 + (25 * p.GamePhaseFactor()))
+			// we estimate minimum MovesToGoEstimateEnd more moves in final
+			// game phases in early game phases this grows up to MovesToGoEstimate
+			movesLeftEnd := config.Settings.Search.MovesToGoEstimateEnd
+			movesLeftOpening := config.Settings.Search.MovesToGoEstimate
+			movesLeft = int64(float64(movesLeftEnd) + (float64(movesLeftOpening-movesLeftEnd) * p.GamePhaseFactor()))
+			if movesLeft < 1 {
+				movesLeft = 1
+			}
 		}
 		// time left for current player
 		var timeLeft time.Duration
@@ -648,6 +1100,11 @@ func (s *Search) setupTimeControl(p *position.Position, sl *Limits) time.Duratio
 		}
 		// estimate time per move
 		timeLimit := time.Duration(timeLeft.Nanoseconds() / movesLeft)
+		// spend less time in the opening
+		if n := config.Settings.Search.OpeningSpeedup; n > 0 && p.MoveNumber() < n {
+			f := openingSpeedupFactor + (1.0-openingSpeedupFactor)*float64(p.MoveNumber()-1)/float64(n-1)
+			timeLimit = time.Duration(int64(f * float64(timeLimit.Nanoseconds())))
+		}
 		// account for runtime of our code
 		if timeLimit.Milliseconds() < 100 {
 			// limits for very short available time reduced by another 20%
@@ -680,15 +1137,20 @@ func (s *Search) addExtraTime(f float64) {
 // the time limit and extra time given. If time limit is reached this will set
 // the stopFlag to true and terminate itself.
 func (s *Search) startTimer() {
+	// a search might finish before its timer has even started (e.g. with
+	// only one move to play) so the timer must not stop a later search
+	searchStart := s.startTime
 	go func() {
 		timerStart := time.Now()
 		s.log.Debugf("Timer started with time limit of %s", s.timeLimit)
 		// as timeLimit changes due to extra times we can't set a fixed timeout
 		// so we do a relaxed busy wait
-		for time.Since(timerStart) < s.timeLimit+s.extraTime && !s.stopFlag {
+		for time.Since(timerStart) < s.timeLimit+s.extraTime && !s.stopFlag && s.startTime == searchStart {
 			time.Sleep(5 * time.Millisecond)
 		}
-		if s.stopFlag {
+		if s.startTime != searchStart {
+			s.log.Debugf("Timer of a finished search stopped after wall time: %s", time.Since(timerStart))
+		} else if s.stopFlag {
 			s.log.Debugf("Timer stopped early after wall time: %s (time limit %s and extra time %s)",
 				time.Since(timerStart), s.timeLimit, s.extraTime)
 		} else {
@@ -722,10 +1184,11 @@ func (s *Search) sendInfoStringToUci(msg string) {
 	}
 }
 
-// send UCI information about search - could be called each 500ms or so.
+// send UCI information about search - sent at most every
+// Settings.Search.InfoInterval milliseconds.
 func (s *Search) sendSearchUpdateToUci() {
 	// also do a regular search update here
-	if time.Since(s.lastUciUpdateTime) > time.Second {
+	if time.Since(s.lastUciUpdateTime) > time.Duration(config.Settings.Search.InfoInterval)*time.Millisecond {
 		s.lastUciUpdateTime = time.Now()
 		hashfull := 0
 		if s.tt != nil {
@@ -764,8 +1227,9 @@ func (s *Search) sendIterationEndInfoToUci() {
 			s.nodesVisited,
 			s.getNps(),
 			time.Since(s.startTime),
-			*s.pv[0])
+			s.reportedPv())
 	} else {
+		pv := s.reportedPv()
 		s.log.Infof(out.Sprintf("depth %d seldepth %d value %s nodes %d nps %d time %d pv %s",
 			s.statistics.CurrentSearchDepth,
 			s.statistics.CurrentExtraSearchDepth,
@@ -773,8 +1237,204 @@ func (s *Search) sendIterationEndInfoToUci() {
 			s.nodesVisited,
 			s.getNps(),
 			time.Since(s.startTime).Milliseconds(),
-			s.pv[0].StringUci()))
+			pv.StringUci()))
+	}
+}
+
+// sendRootMoveNodesToUci sends the nodes searched for each root move
+// in the order of the root moves as info strings to the UCI ui.
+func (s *Search) sendRootMoveNodesToUci() {
+	for _, m := range *s.rootMoves {
+		msg := out.Sprintf("root move %s nodes %d", m.StringUci(), s.rootMoveNodes[m.MoveOf()])
+		if s.uciHandlerPtr != nil {
+			s.uciHandlerPtr.SendInfoString(msg)
+		} else {
+			s.log.Info(msg)
+		}
+	}
+}
+
+// sendRefutationsToUci sends the best reply of the opponent to each root
+// move to the UCI ui. Root moves without a known reply (e.g. mate) are
+// skipped. Only the value of the pv move is exact. All other root moves
+// have been searched with a null window and failed low so their values
+// are upper bounds.
+func (s *Search) sendRefutationsToUci(p *position.Position) {
+	mg := movegen.NewMoveGen()
+	for _, m := range *s.rootMoves {
+		refutation := s.refutation(p, mg, m.MoveOf())
+		if refutation.Len() == 0 {
+			continue
+		}
+		bound, score := "", m.ValueOf().String()
+		if s.pv[0].Len() == 0 || m.MoveOf() != s.pv[0].At(0).MoveOf() {
+			bound = "upperbound"
+			score += " " + bound
+		}
+		s.log.Debugf("Refutation %s %s value %s", m.StringUci(), refutation.StringUci(), score)
+		if s.uciHandlerPtr != nil {
+			s.uciHandlerPtr.SendRefutation(m.MoveOf(), m.ValueOf(), bound, *refutation)
+		} else {
+			s.log.Info(out.Sprintf("refutation %s %s score %s", m.StringUci(), refutation.StringUci(), score))
+		}
+	}
+}
+
+// refutation returns the best reply of the opponent found in the search
+// for the given root move. For the best move this is the rest of the pv
+// and for all other moves the best move stored in the tt for the position
+// after the root move. The tt move is only used if it is legal in the
+// position. The given move generator is used
+// to validate the tt move and must not be the one which generated the
+// root moves.
+func (s *Search) refutation(p *position.Position, mg *movegen.Movegen, move Move) *moveslice.MoveSlice {
+	refutation := moveslice.NewMoveSlice(MaxDepth)
+	if s.pv[0].Len() > 0 && move == s.pv[0].At(0).MoveOf() {
+		for i := 1; i < s.pv[0].Len(); i++ {
+			refutation.PushBack(s.pv[0].At(i).MoveOf())
+		}
+	} else if config.Settings.Search.UseTT {
+		p.DoMove(move)
+		if ttEntry := s.tt.Probe(p.ZobristKey()); ttEntry != nil &&
+			ttEntry.Move.MoveOf() != MoveNone &&
+			mg.ValidateMove(p, ttEntry.Move.MoveOf()) {
+			refutation.PushBack(ttEntry.Move.MoveOf())
+		}
+		p.UndoMove()
+	}
+	return refutation
+}
+
+// sendAspirationResearchInfoToUci reports a failed aspiration search
+// with its value and bound (lowerbound or upperbound) to the UCI ui.
+func (s *Search) sendAspirationResearchInfoToUci(value Value, bound string) {
+	if s.uciHandlerPtr != nil {
+		s.uciHandlerPtr.SendAspirationResearchInfo(
+			s.statistics.CurrentSearchDepth,
+			s.statistics.CurrentExtraSearchDepth,
+			value,
+			bound,
+			s.nodesVisited,
+			s.getNps(),
+			time.Since(s.startTime),
+			s.reportedPv())
+	} else {
+		pv := s.reportedPv()
+		s.log.Infof(out.Sprintf("depth %d seldepth %d value %s %s nodes %d nps %d time %d pv %s",
+			s.statistics.CurrentSearchDepth,
+			s.statistics.CurrentExtraSearchDepth,
+			value.String(),
+			bound,
+			s.nodesVisited,
+			s.getNps(),
+			time.Since(s.startTime).Milliseconds(),
+			pv.StringUci()))
+	}
+}
+
+// reportedPv returns the root pv as it is reported to the UCI ui.
+// It is limited to MaxPvLength moves and cut at the first move which
+// is not legal when walking the pv on the current position.
+func (s *Search) reportedPv() moveslice.MoveSlice {
+	pv := moveslice.NewMoveSlice(MaxDepth + 1)
+	maxLength := config.Settings.Search.MaxPvLength
+	if maxLength <= 0 || maxLength > s.pv[0].Len() {
+		maxLength = s.pv[0].Len()
+	}
+	if s.currentPosition == nil {
+		return *pv
+	}
+	p := s.currentPosition
+	mg := movegen.NewMoveGen()
+	for i := 0; i < maxLength; i++ {
+		move := s.pv[0].At(i).MoveOf()
+		if !mg.ValidateMove(p, move) {
+			break
+		}
+		pv.PushBack(move)
+		p.DoMove(move)
+	}
+	for i := 0; i < pv.Len(); i++ {
+		p.UndoMove()
+	}
+	return *pv
+}
+
+// rootMoveNoise returns a small value between 0 and the configured
+// RootMoveNoise for the given root move. The value is deterministic for
+// the move within a game (rootNoiseSeed) but varies between games.
+func (s *Search) rootMoveNoise(m Move) Value {
+	if config.Settings.Search.RootMoveNoise <= 0 {
+		return 0
+	}
+	// splitmix64 hash of the seed and the move
+	z := s.rootNoiseSeed + uint64(m.MoveOf())*0x9E3779B97F4A7C15
+	z = (z ^ (z >> 30)) * 0xBF58476D1CE4E5B9
+	z = (z ^ (z >> 27)) * 0x94D049BB133111EB
+	z = z ^ (z >> 31)
+	return Value(z % uint64(config.Settings.Search.RootMoveNoise+1))
+}
+
+// forcingMoves returns a new move slice with the captures, checks and
+// promotions of the given moves.
+func (s *Search) forcingMoves(p *position.Position, moves *moveslice.MoveSlice) *moveslice.MoveSlice {
+	forcing := moveslice.NewMoveSlice(moves.Len())
+	for _, m := range *moves {
+		if isForcing(p, m.MoveOf()) {
+			forcing.PushBack(m)
+		}
+	}
+	return forcing
+}
+
+// findRepetitionMoves returns the root moves which repeat a position of
+// the game or after which the opponent can repeat a position. Returns nil
+// if the anti repetition bias is not used.
+func (s *Search) findRepetitionMoves(p *position.Position) map[Move]bool {
+	if !config.Settings.Search.UseAntiRepetition {
+		return nil
+	}
+	repetitionMoves := make(map[Move]bool)
+	mg := movegen.NewMoveGen()
+	for _, m := range *s.rootMoves {
+		p.DoMove(m)
+		repetition := p.CheckRepetitions(1)
+		if !repetition {
+			for _, r := range *mg.GenerateLegalMoves(p, movegen.GenAll) {
+				p.DoMove(r)
+				repetition = p.CheckRepetitions(1)
+				p.UndoMove()
+				if repetition {
+					break
+				}
+			}
+		}
+		p.UndoMove()
+		if repetition {
+			repetitionMoves[m.MoveOf()] = true
+		}
+	}
+	return repetitionMoves
+}
+
+// clearlyWinning returns true if the anti repetition bias is used and
+// the best value of the last iteration is at least AntiRepetitionMargin.
+func (s *Search) clearlyWinning() bool {
+	if !config.Settings.Search.UseAntiRepetition || s.pv[0].Len() == 0 {
+		return false
+	}
+	value := s.pv[0].At(0).ValueOf()
+	return value != ValueNA && value >= Value(config.Settings.Search.AntiRepetitionMargin)
+}
+
+// repetitionPenalty returns a small penalty for root moves which allow
+// a repetition when we are clearly winning. This prefers moves making
+// progress among moves with nearly equal values.
+func (s *Search) repetitionPenalty(m Move, winning bool) Value {
+	if !winning || !s.repetitionMoves[m.MoveOf()] {
+		return 0
 	}
+	return antiRepetitionPenalty
 }
 
 // helper to calculate current nps relative to s.startTime.
@@ -27,9 +27,13 @@
 package search
 
 import (
+	"encoding/json"
+	"math/rand"
 	"os"
 	"path"
+	"reflect"
 	"runtime"
+	"strings"
 	"testing"
 	"time"
 
@@ -38,6 +42,8 @@ import (
 
 	"github.com/frankkopp/FrankyGo/internal/config"
 	"github.com/frankkopp/FrankyGo/internal/logging"
+	"github.com/frankkopp/FrankyGo/internal/movegen"
+	"github.com/frankkopp/FrankyGo/internal/moveslice"
 	"github.com/frankkopp/FrankyGo/internal/position"
 	. "github.com/frankkopp/FrankyGo/internal/types"
 )
@@ -128,6 +134,282 @@ func TestSetupTimeControl(t *testing.T) {
 	assert.EqualValues(t, 3600, timeLimit.Milliseconds())
 }
 
+func TestSetupTimeControlMovesToGoEstimate(t *testing.T) {
+	defer func() {
+		config.Settings.Search.MovesToGoEstimate = 40
+		config.Settings.Search.MovesToGoEstimateEnd = 15
+	}()
+	s := NewSearch()
+	sl := NewSearchLimits()
+	sl.TimeControl = true
+	sl.WhiteTime = 60 * time.Second
+	sl.BlackTime = 60 * time.Second
+	opening := position.NewPosition()
+	endgame := position.NewPosition("8/2P1P1P1/3PkP2/8/4K3/8/8/8 w - - 0 1")
+
+	// opening: 60s / 40 moves * 0.9 - end game: 60s / 15 moves * 0.9
+	assert.EqualValues(t, 1350, s.setupTimeControl(opening, sl).Milliseconds())
+	assert.EqualValues(t, 3600, s.setupTimeControl(endgame, sl).Milliseconds())
+
+	config.Settings.Search.MovesToGoEstimate = 30
+	config.Settings.Search.MovesToGoEstimateEnd = 10
+	openingLimit := s.setupTimeControl(opening, sl)
+	endgameLimit := s.setupTimeControl(endgame, sl)
+	assert.EqualValues(t, 1800, openingLimit.Milliseconds())
+	assert.EqualValues(t, 5400, endgameLimit.Milliseconds())
+	assert.Less(t, openingLimit.Nanoseconds(), endgameLimit.Nanoseconds())
+
+	// increment is added per move
+	sl.WhiteInc = 2 * time.Second
+	assert.EqualValues(t, 3600, s.setupTimeControl(opening, sl).Milliseconds())
+}
+
+func TestOpeningSpeedup(t *testing.T) {
+	defer func() { config.Settings.Search.OpeningSpeedup = 0 }()
+	s := NewSearch()
+	sl := NewSearchLimits()
+	sl.TimeControl = true
+	sl.WhiteTime = 60 * time.Second
+	sl.BlackTime = 60 * time.Second
+	sl.MovesToGo = 20
+	early := position.NewPosition()
+	middle := position.NewPosition("r1bq1rk1/pp2bppp/2n1pn2/3p4/2PP4/2N2N2/PP2BPPP/R2QKB1R w KQ - 0 25")
+
+	// without speedup both get 60s / 20 moves * 0.9
+	assert.EqualValues(t, 2700, s.setupTimeControl(early, sl).Milliseconds())
+	assert.EqualValues(t, 2700, s.setupTimeControl(middle, sl).Milliseconds())
+
+	// with speedup the first move gets half of the time
+	config.Settings.Search.OpeningSpeedup = 11
+	earlyLimit := s.setupTimeControl(early, sl)
+	assert.EqualValues(t, 1350, earlyLimit.Milliseconds())
+	assert.EqualValues(t, 2700, s.setupTimeControl(middle, sl).Milliseconds())
+
+	// growing up to the normal time at the last move of the opening
+	p := position.NewPosition("r1bqkbnr/pppp1ppp/2n5/4p3/4P3/5N2/PPPP1PPP/RNBQKB1R w KQkq - 2 6")
+	assert.EqualValues(t, 2025, s.setupTimeControl(p, sl).Milliseconds())
+	p = position.NewPosition("r1bqkbnr/pppp1ppp/2n5/4p3/4P3/5N2/PPPP1PPP/RNBQKB1R w KQkq - 2 11")
+	assert.EqualValues(t, 2700, s.setupTimeControl(p, sl).Milliseconds())
+
+	// move time is not changed
+	sl = NewSearchLimits()
+	sl.TimeControl = true
+	sl.MoveTime = 1 * time.Second
+	assert.EqualValues(t, 980, s.setupTimeControl(early, sl).Milliseconds())
+}
+
+func TestPhaseContempt(t *testing.T) {
+	defer func() {
+		config.Settings.Search.ContemptMax = 0
+		config.Settings.Search.UsePhaseContempt = false
+	}()
+	s := NewSearch()
+	opening := position.NewPosition()
+	endgame := position.NewPosition("8/2P1P1P1/3PkP2/8/4K3/8/8/8 w - - 0 1")
+	assert.EqualValues(t, GamePhaseMax, opening.GamePhase())
+	assert.EqualValues(t, 0, endgame.GamePhase())
+
+	// no contempt
+	assert.EqualValues(t, 0, s.effectiveContempt(opening))
+	assert.EqualValues(t, 0, s.effectiveContempt(endgame))
+
+	// fixed contempt
+	config.Settings.Search.ContemptMax = 40
+	assert.EqualValues(t, 40, s.effectiveContempt(opening))
+	assert.EqualValues(t, 40, s.effectiveContempt(endgame))
+
+	// contempt scaled by game phase
+	config.Settings.Search.UsePhaseContempt = true
+	assert.EqualValues(t, 40, s.effectiveContempt(opening))
+	assert.EqualValues(t, 0, s.effectiveContempt(endgame))
+	middlegame := position.NewPosition("r3k3/8/8/8/8/8/8/R3K2Q w - - 0 1")
+	assert.EqualValues(t, 8, middlegame.GamePhase())
+	assert.EqualValues(t, 13, s.effectiveContempt(middlegame))
+
+	// a draw is bad for the root color and good for the opponent
+	s.eval.SetContempt(White, s.effectiveContempt(opening))
+	assert.EqualValues(t, -40, s.drawValue(opening))
+	opening.DoMove(CreateMove(SqE2, SqE4, Normal, PtNone))
+	assert.EqualValues(t, 40, s.drawValue(opening))
+}
+
+func TestAnalyzeBatch(t *testing.T) {
+	config.Settings.Search.UseBook = false
+	positions := []position.Position{
+		*position.NewPosition(),
+		*position.NewPosition("6k1/5ppp/8/8/8/8/8/R5K1 w - -"),
+	}
+	sl := NewSearchLimits()
+	sl.Depth = 4
+	search := NewSearch()
+	results := search.AnalyzeBatch(positions, *sl, true)
+	assert.Len(t, results, 2)
+	for _, r := range results {
+		logTest.Debug(r.String())
+		assert.NotEqual(t, MoveNone, r.BestMove)
+		assert.Greater(t, r.Pv.Len(), 0)
+		assert.Greater(t, r.Nodes, uint64(0))
+		assert.EqualValues(t, 4, r.SearchDepth)
+	}
+	// back rank mate
+	assert.Equal(t, CreateMove(SqA1, SqA8, Normal, PtNone), results[1].BestMove)
+	assert.True(t, results[1].BestValue.IsCheckMateValue())
+}
+
+func TestClearCaches(t *testing.T) {
+	defer func() { config.Settings.Search.WarmUpCaches = false }()
+	config.Settings.Search.UseBook = false
+	config.Settings.Search.WarmUpCaches = true
+	sl := NewSearchLimits()
+	sl.Depth = 6
+	search := NewSearch()
+	p1 := position.NewPosition("r3k2r/p1ppqpb1/bn2pnp1/3PN3/1p2P3/2N2Q1p/PPPBBPPP/R3K2R w KQkq -")
+	p2 := position.NewPosition("1r3rk1/1pnnq1bR/p1pp2B1/P2P1p2/1PP1pP2/2B3P1/5PK1/2Q4R w - -")
+
+	bench := func() []uint64 {
+		nodes := make([]uint64, 0, 2)
+		for _, p := range []*position.Position{p1, p2} {
+			search.ClearCaches()
+			search.StartSearch(*p, *sl)
+			search.WaitWhileSearching()
+			nodes = append(nodes, search.LastSearchResult().Nodes)
+		}
+		return nodes
+	}
+
+	first := bench()
+	second := bench()
+	assert.Equal(t, first, second)
+
+	// without clearing the caches the residual state changes the search
+	search.ClearCaches()
+	search.StartSearch(*p1, *sl)
+	search.WaitWhileSearching()
+	search.StartSearch(*p1, *sl)
+	search.WaitWhileSearching()
+	assert.NotEqual(t, first[0], search.LastSearchResult().Nodes)
+}
+
+func TestOnlyMove(t *testing.T) {
+	defer func() { config.Settings.Search.UseOnlyMove = true }()
+	config.Settings.Search.UseBook = false
+	config.Settings.Search.UseOnlyMove = true
+	search := NewSearch()
+
+	// Kxb2 is the only legal move
+	p := position.NewPosition("k7/8/8/8/8/8/1r6/K6r w - -")
+	sl := NewSearchLimits()
+	sl.TimeControl = true
+	sl.MoveTime = 5 * time.Second
+	start := time.Now()
+	search.StartSearch(*p, *sl)
+	search.WaitWhileSearching()
+	assert.Less(t, time.Since(start).Milliseconds(), int64(1_000))
+	result := search.LastSearchResult()
+	assert.Equal(t, CreateMove(SqA1, SqB2, Normal, PtNone), result.BestMove)
+	assert.EqualValues(t, 1, result.SearchDepth)
+	assert.Greater(t, result.Pv.Len(), 0)
+	assert.Equal(t, result.BestMove, search.statistics.CurrentBestRootMove.MoveOf())
+
+	// without the option the position is searched to the given depth
+	config.Settings.Search.UseOnlyMove = false
+	sl = NewSearchLimits()
+	sl.Depth = 4
+	search.StartSearch(*p, *sl)
+	search.WaitWhileSearching()
+	assert.Equal(t, CreateMove(SqA1, SqB2, Normal, PtNone), search.LastSearchResult().BestMove)
+	assert.EqualValues(t, 4, search.LastSearchResult().SearchDepth)
+}
+
+func TestSearchForcing(t *testing.T) {
+	config.Settings.Search.UseBook = false
+	sl := NewSearchLimits()
+	sl.Depth = 4
+	search := NewSearch()
+
+	// Philidor's legacy - Qg8+ Rxg8 Nf7#
+	p := position.NewPosition("5r1k/6pp/7N/3Q4/8/8/6PP/6K1 w - - 0 1")
+	result := search.SearchForcing(*p, *sl)
+	logTest.Debug(result.String())
+	assert.Equal(t, "d5g8", result.BestMove.StringUci())
+	assert.EqualValues(t, ValueCheckMate-3, result.BestValue)
+	assert.Equal(t, "d5g8 f8g8 h6f7", result.Pv.StringUci())
+
+	// all moves of the pv are forcing
+	for _, m := range result.Pv {
+		assert.True(t, isForcing(p, m.MoveOf()), m.StringUci())
+		p.DoMove(m.MoveOf())
+	}
+
+	// no forcing moves in the start position
+	p = position.NewPosition()
+	result = search.SearchForcing(*p, *sl)
+	assert.Equal(t, MoveNone, result.BestMove)
+	assert.Equal(t, 0, result.Pv.Len())
+
+	// a normal search afterwards is not limited to forcing moves
+	search.StartSearch(*p, *sl)
+	search.WaitWhileSearching()
+	assert.NotEqual(t, MoveNone, search.LastSearchResult().BestMove)
+}
+
+func TestPlaySelfGame(t *testing.T) {
+	config.Settings.Search.UseBook = false
+	sl := NewSearchLimits()
+	sl.TimeControl = true
+	sl.MoveTime = 20 * time.Millisecond
+
+	// mate in one
+	result, pgn := PlaySelfGame(*sl, "6k1/5ppp/8/8/8/8/8/R5K1 w - - 0 1")
+	logTest.Debug(pgn)
+	assert.Equal(t, "1-0", result)
+	assert.Contains(t, pgn, "[FEN \"6k1/5ppp/8/8/8/8/8/R5K1 w - - 0 1\"]")
+	assert.Contains(t, pgn, "1. Ra8# 1-0")
+
+	// short end game played until the end
+	result, pgn = PlaySelfGame(*sl, "8/8/4k3/8/8/3QK3/8/8 b - - 0 40")
+	logTest.Debug(pgn)
+	assert.Contains(t, []string{"1-0", "1/2-1/2"}, result)
+	assert.Contains(t, pgn, "40... K")
+	assert.True(t, strings.HasSuffix(pgn, result+"\n"))
+}
+
+func TestDrawValue(t *testing.T) {
+	defer func() { config.Settings.Search.DrawValue = 0 }()
+	config.Settings.Search.UseBook = false
+	// white is a pawn down and every king move is a draw by the 50-moves
+	// rule while pawn moves continue the game
+	p := position.NewPosition("4k3/p6p/8/8/8/8/P7/4K3 w - - 99 80")
+	sl := NewSearchLimits()
+	sl.Depth = 4
+
+	// a draw is better than being a pawn down
+	search := NewSearch()
+	search.StartSearch(*p, *sl)
+	search.WaitWhileSearching()
+	result := search.LastSearchResult()
+	logTest.Debug(result.String())
+	assert.Equal(t, King, p.GetPiece(result.BestMove.From()).TypeOf())
+	assert.EqualValues(t, ValueDraw, result.BestValue)
+
+	// with a draw valued highly for the opponent white avoids the draw
+	config.Settings.Search.DrawValue = 300
+	search = NewSearch()
+	search.StartSearch(*p, *sl)
+	search.WaitWhileSearching()
+	result = search.LastSearchResult()
+	logTest.Debug(result.String())
+	assert.Equal(t, Pawn, p.GetPiece(result.BestMove.From()).TypeOf())
+	assert.Greater(t, int(result.BestValue), -300)
+	assert.Less(t, int(result.BestValue), 0)
+
+	// the draw value is from the view of the next player
+	search.eval.SetContempt(White, 0)
+	assert.EqualValues(t, 300, search.drawValue(p))
+	p.DoMove(CreateMove(SqE1, SqD1, Normal, PtNone))
+	assert.EqualValues(t, 300, search.drawValue(p))
+}
+
 func TestWaitWhileSearching(t *testing.T) {
 	search := NewSearch()
 	p := position.NewPosition()
@@ -147,6 +429,79 @@ func TestWaitWhileSearching(t *testing.T) {
 	assert.GreaterOrEqual(t, elapsed.Milliseconds(), int64(2_000))
 }
 
+func TestStopImmediately(t *testing.T) {
+	search := NewSearch()
+	mg := movegen.NewMoveGen()
+	fens := []string{
+		position.StartFen,
+		"r3k2r/p1ppqpb1/bn2pnp1/3PN3/1p2P3/2N2Q1p/PPPBBPPP/R3K2R w KQkq -",
+		"6k1/5ppp/8/8/8/8/8/K2r4 w - -",
+	}
+	for _, fen := range fens {
+		p, _ := position.NewPositionFen(fen)
+		sl := NewSearchLimits()
+		sl.Infinite = true
+		search.StartSearch(*p, *sl)
+		search.StopSearch()
+		bestMove := search.LastSearchResult().BestMove
+		bestValue := search.LastSearchResult().BestValue
+		assert.True(t, mg.ValidateMove(p, bestMove), "%s %s", fen, bestMove.StringUci())
+		// no value from an interrupted search
+		assert.True(t, bestValue == ValueNA || bestValue.IsValid(), "%s %d", fen, bestValue)
+	}
+
+	// stopped by a node limit before the first root move could be
+	// searched completely
+	p := position.NewPosition()
+	sl := NewSearchLimits()
+	sl.Nodes = 2
+	search.StartSearch(*p, *sl)
+	search.WaitWhileSearching()
+	assert.True(t, mg.ValidateMove(p, search.LastSearchResult().BestMove))
+	assert.EqualValues(t, ValueNA, search.LastSearchResult().BestValue)
+	assert.EqualValues(t, 1, search.LastSearchResult().SearchDepth)
+}
+
+func TestValidateResult(t *testing.T) {
+	search := NewSearch()
+	mg := movegen.NewMoveGen()
+	p := position.NewPosition()
+	e2e4 := CreateMove(SqE2, SqE4, Normal, PtNone)
+	e7e5 := CreateMove(SqE7, SqE5, Normal, PtNone)
+	d2d4 := CreateMove(SqD2, SqD4, Normal, PtNone)
+	search.rootMoves = mg.GenerateLegalMoves(p, movegen.GenAll).Clone()
+	for i, m := range *search.rootMoves {
+		if m.MoveOf() == d2d4 {
+			search.rootMoves.Set(i, m.SetValue(50))
+		}
+	}
+
+	// corrupted pv with an illegal best move
+	result := &Result{BestMove: e7e5, BestValue: 100, PonderMove: e2e4}
+	result.Pv = *moveslice.NewMoveSlice(MaxDepth + 1)
+	result.Pv.PushBack(e7e5)
+	result.Pv.PushBack(e2e4)
+	search.validateResult(p, result)
+	assert.True(t, mg.ValidateMove(p, result.BestMove))
+	assert.EqualValues(t, d2d4, result.BestMove)
+	assert.EqualValues(t, 50, result.BestValue)
+	assert.EqualValues(t, MoveNone, result.PonderMove)
+	assert.EqualValues(t, "d2d4", result.Pv.StringUci())
+
+	// legal best move but corrupted pv and ponder move
+	result = &Result{BestMove: e2e4, BestValue: 30, PonderMove: e2e4}
+	result.Pv = *moveslice.NewMoveSlice(MaxDepth + 1)
+	result.Pv.PushBack(e2e4)
+	result.Pv.PushBack(e7e5)
+	result.Pv.PushBack(e7e5)
+	search.validateResult(p, result)
+	assert.EqualValues(t, e2e4, result.BestMove)
+	assert.EqualValues(t, 30, result.BestValue)
+	assert.EqualValues(t, MoveNone, result.PonderMove)
+	assert.EqualValues(t, "e2e4 e7e5", result.Pv.StringUci())
+	assert.EqualValues(t, position.StartFen, p.StringFen())
+}
+
 func TestIsSearching(t *testing.T) {
 	search := NewSearch()
 	p := position.NewPosition()
@@ -187,6 +542,508 @@ func TestStaleMatePosition(t *testing.T) {
 	assert.EqualValues(t, ValueDraw, result.BestValue)
 }
 
+func TestLastSearchResultSummary(t *testing.T) {
+	config.Settings.Search.UseBook = false
+	search := NewSearch()
+	p := position.NewPosition()
+	sl := NewSearchLimits()
+	sl.TimeControl = true
+	sl.MoveTime = 500 * time.Millisecond
+	search.StartSearch(*p, *sl)
+	search.WaitWhileSearching()
+	result := search.LastSearchResult()
+	logTest.Debug(result.String())
+	assert.NotEqual(t, MoveNone, result.BestMove)
+	assert.NotEqual(t, MoveNone, result.PonderMove)
+	assert.Greater(t, result.SearchDepth, 1)
+	assert.GreaterOrEqual(t, result.ExtraDepth, result.SearchDepth)
+	assert.Greater(t, result.Nodes, uint64(0))
+	assert.EqualValues(t, search.NodesVisited(), result.Nodes)
+	assert.Greater(t, result.Nps, uint64(0))
+	assert.Greater(t, result.SearchTime.Milliseconds(), int64(0))
+	assert.Equal(t, result.BestMove, result.Pv.At(0).MoveOf())
+	assert.Contains(t, result.String(), out.Sprintf("nodes = %d", result.Nodes))
+}
+
+func TestAntiRepetition(t *testing.T) {
+	defer func() { config.Settings.Search.UseAntiRepetition = false }()
+	config.Settings.Search.UseBook = false
+	mg := movegen.NewMoveGen()
+	// the king went back and forth - e1f2 repeats a position
+	p := position.NewPosition("8/8/8/4k3/8/8/8/3QK3 w - - 0 1")
+	for _, m := range []string{"e1f2", "e5e6", "f2e1", "e6e5"} {
+		p.DoMove(mg.GetMoveFromUci(p, m))
+	}
+	repetitionMove := mg.GetMoveFromUci(p, "e1f2")
+	sl := NewSearchLimits()
+	sl.Depth = 6
+
+	search := NewSearch()
+	search.StartSearch(*p, *sl)
+	search.WaitWhileSearching()
+	value := search.LastSearchResult().BestValue
+	assert.Nil(t, search.repetitionMoves)
+
+	// a progress move with the same value is preferred
+	config.Settings.Search.UseAntiRepetition = true
+	search = NewSearch()
+	search.StartSearch(*p, *sl)
+	search.WaitWhileSearching()
+	result := search.LastSearchResult()
+	logTest.Debug(result.String())
+	assert.EqualValues(t, map[Move]bool{repetitionMove: true}, search.repetitionMoves)
+	assert.True(t, search.clearlyWinning())
+	assert.NotEqual(t, repetitionMove, result.BestMove)
+	assert.EqualValues(t, value, result.BestValue)
+}
+
+func TestMaxNps(t *testing.T) {
+	defer func() { config.Settings.Search.MaxNps = 0 }()
+	config.Settings.Search.UseBook = false
+	config.Settings.Search.MaxNps = 10000
+	search := NewSearch()
+	p := position.NewPosition()
+	sl := NewSearchLimits()
+	sl.TimeControl = true
+	sl.MoveTime = 500 * time.Millisecond
+	search.StartSearch(*p, *sl)
+	search.WaitWhileSearching()
+	result := search.LastSearchResult()
+	logTest.Debug(result.String())
+	// about 480ms search time (movetime minus 20ms) at 10.000 nps
+	assert.GreaterOrEqual(t, result.Nodes, uint64(3500))
+	assert.LessOrEqual(t, result.Nodes, uint64(6000))
+	assert.LessOrEqual(t, result.Nps, uint64(10500))
+}
+
+func TestBookMaxPly(t *testing.T) {
+	defer func() {
+		config.Settings.Search.UseBook = false
+		config.Settings.Search.BookMaxPly = 0
+	}()
+	config.Settings.Search.UseBook = true
+	config.Settings.Search.BookMaxPly = 2
+	search := NewSearch()
+	sl := NewSearchLimits()
+	sl.TimeControl = true
+	sl.MoveTime = 100 * time.Millisecond
+
+	// within the first plies the book is used
+	p := position.NewPosition()
+	p.DoMove(CreateMove(SqE2, SqE4, Normal, PtNone))
+	assert.EqualValues(t, 1, p.GamePly())
+	search.StartSearch(*p, *sl)
+	search.WaitWhileSearching()
+	assert.True(t, search.LastSearchResult().BookMove)
+	assert.False(t, search.outOfBook)
+
+	// past the configured ply the book is not consulted
+	p.DoMove(CreateMove(SqE7, SqE5, Normal, PtNone))
+	assert.EqualValues(t, 2, p.GamePly())
+	search.StartSearch(*p, *sl)
+	search.WaitWhileSearching()
+	assert.False(t, search.LastSearchResult().BookMove)
+	assert.True(t, search.outOfBook)
+
+	// without limit the book has a move for this position
+	config.Settings.Search.BookMaxPly = 0
+	search.StartSearch(*p, *sl)
+	search.WaitWhileSearching()
+	assert.True(t, search.LastSearchResult().BookMove)
+}
+
+func TestBookVariety(t *testing.T) {
+	defer func() {
+		config.Settings.Search.UseBook = false
+		config.Settings.Search.BookVariety = 0.0
+	}()
+	config.Settings.Search.UseBook = true
+	search := NewSearch()
+	search.initialize()
+	if !assert.NotNil(t, search.book) {
+		return
+	}
+	p := position.NewPosition()
+	entry, found := search.book.GetEntry(p.ZobristKey())
+	assert.True(t, found)
+	assert.Greater(t, len(entry.Moves), 1)
+	inBook := make(map[Move]bool)
+	for _, m := range entry.Moves {
+		inBook[Move(m.Move)] = true
+	}
+
+	// without variety the most played move is chosen most often
+	chosen := make(map[Move]int)
+	for seed := int64(1); seed <= 200; seed++ {
+		search.bookRand = rand.New(rand.NewSource(seed))
+		chosen[search.chooseBookMove(&entry)]++
+	}
+	top, topCount := MoveNone, 0
+	for m, c := range chosen {
+		assert.True(t, inBook[m], "out of book move %s", m.StringUci())
+		if c > topCount {
+			top, topCount = m, c
+		}
+	}
+
+	// with full variety the most played move is never chosen but the
+	// choice still covers several book moves
+	config.Settings.Search.BookVariety = 1.0
+	chosen = make(map[Move]int)
+	for seed := int64(1); seed <= 200; seed++ {
+		search.bookRand = rand.New(rand.NewSource(seed))
+		chosen[search.chooseBookMove(&entry)]++
+	}
+	assert.Greater(t, len(chosen), 1)
+	assert.Zero(t, chosen[top])
+	for m := range chosen {
+		assert.True(t, inBook[m], "out of book move %s", m.StringUci())
+	}
+}
+
+func TestStatisticsPerDepth(t *testing.T) {
+	config.Settings.Search.UseBook = false
+	search := NewSearch()
+	p := position.NewPosition("r3k2r/p1ppqpb1/bn2pnp1/3PN3/1p2P3/2N2Q1p/PPPBBPPP/R3K2R w KQkq -")
+	sl := NewSearchLimits()
+	sl.Depth = 6
+	search.StartSearch(*p, *sl)
+	search.WaitWhileSearching()
+	stats := search.Statistics()
+	assert.EqualValues(t, search.NodesVisited(), stats.PerDepth.Nodes())
+	assert.Greater(t, stats.PerDepth[0].Nodes, uint64(0))
+	assert.Greater(t, stats.PerDepth[1].BetaCuts, uint64(0))
+	assert.Zero(t, stats.PerDepth[7].Nodes)
+	assert.Contains(t, stats.PerDepth.String(), "Depth")
+	assert.Contains(t, stats.String(), "PerDepth")
+}
+
+func TestStatisticsJSON(t *testing.T) {
+	config.Settings.Search.UseBook = false
+	search := NewSearch()
+	p := position.NewPosition("r3k2r/p1ppqpb1/bn2pnp1/3PN3/1p2P3/2N2Q1p/PPPBBPPP/R3K2R w KQkq -")
+	sl := NewSearchLimits()
+	sl.Depth = 6
+	search.StartSearch(*p, *sl)
+	search.WaitWhileSearching()
+	stats := search.Statistics()
+
+	data, err := stats.JSON()
+	assert.NoError(t, err)
+	assert.Contains(t, string(data), `"BetaCuts":`)
+	assert.Contains(t, string(data), `"TTMoveBestRatio":`)
+
+	// raw keys are stable field names
+	var raw map[string]interface{}
+	assert.NoError(t, json.Unmarshal(data, &raw))
+	assert.EqualValues(t, stats.TTHit, raw["TTHit"])
+	assert.EqualValues(t, stats.TTMoveBestRatio(), raw["TTMoveBestRatio"])
+
+	// round trip
+	var back Statistics
+	assert.NoError(t, json.Unmarshal(data, &back))
+	assert.EqualValues(t, stats.BetaCuts, back.BetaCuts)
+	assert.EqualValues(t, stats.TTHit, back.TTHit)
+	assert.EqualValues(t, stats.NullMoveCuts, back.NullMoveCuts)
+	assert.EqualValues(t, stats.CurrentIterationDepth, back.CurrentIterationDepth)
+	assert.EqualValues(t, stats.CurrentBestRootMove, back.CurrentBestRootMove)
+	assert.EqualValues(t, stats.PerDepth, back.PerDepth)
+	assert.EqualValues(t, search.NodesVisited(), back.PerDepth.Nodes())
+
+	// every field has an explicit key
+	for _, typ := range []reflect.Type{reflect.TypeOf(Statistics{}), reflect.TypeOf(DepthStats{})} {
+		for i := 0; i < typ.NumField(); i++ {
+			_, ok := typ.Field(i).Tag.Lookup("json")
+			assert.True(t, ok, "missing json tag for %s.%s", typ.Name(), typ.Field(i).Name)
+		}
+	}
+}
+
+func TestRefutations(t *testing.T) {
+	config.Settings.Search.UseBook = false
+	search := NewSearch()
+	p := position.NewPosition("r1bqkbnr/pppp1ppp/2n5/4p3/4P3/5N2/PPPP1PPP/RNBQKB1R w KQkq -")
+	sl := NewSearchLimits()
+	sl.Depth = 6
+	search.StartSearch(*p, *sl)
+	search.WaitWhileSearching()
+
+	mg := movegen.NewMoveGen()
+	found := 0
+	for _, m := range *search.rootMoves {
+		refutation := search.refutation(p, mg, m.MoveOf())
+		if m.MoveOf() == search.LastSearchResult().BestMove {
+			// the refutation of the best move is the rest of the pv
+			assert.EqualValues(t, search.pv[0].Len()-1, refutation.Len())
+			assert.EqualValues(t, search.LastSearchResult().PonderMove, refutation.At(0))
+		}
+		if refutation.Len() == 0 {
+			continue
+		}
+		found++
+		// the reply is a legal move after the root move
+		p.DoMove(m.MoveOf())
+		assert.True(t, mg.ValidateMove(p, refutation.At(0)), m.StringUci())
+		p.UndoMove()
+	}
+	// all root moves have been searched deep enough to have a reply in the tt
+	assert.EqualValues(t, search.rootMoves.Len(), found)
+	assert.EqualValues(t, "r1bqkbnr/pppp1ppp/2n5/4p3/4P3/5N2/PPPP1PPP/RNBQKB1R w KQkq - 0 1", p.StringFen())
+
+	// without tt only the best move has a refutation
+	defer func() { config.Settings.Search.UseTT = true }()
+	config.Settings.Search.UseTT = false
+	search = NewSearch()
+	search.StartSearch(*p, *sl)
+	search.WaitWhileSearching()
+	found = 0
+	for _, m := range *search.rootMoves {
+		if search.refutation(p, mg, m.MoveOf()).Len() > 0 {
+			found++
+		}
+	}
+	assert.EqualValues(t, 1, found)
+}
+
+func TestStatisticsTTMoveBest(t *testing.T) {
+	config.Settings.Search.UseBook = false
+	search := NewSearch()
+	p := position.NewPosition("r3k2r/p1ppqpb1/bn2pnp1/3PN3/1p2P3/2N2Q1p/PPPBBPPP/R3K2R w KQkq -")
+	sl := NewSearchLimits()
+	sl.Depth = 6
+	search.StartSearch(*p, *sl)
+	search.WaitWhileSearching()
+	stats := search.Statistics()
+	assert.Greater(t, stats.TTMoveUsed, uint64(0))
+	assert.Greater(t, stats.TTMoveBest, uint64(0))
+	assert.LessOrEqual(t, stats.TTMoveBest, stats.TTMoveUsed)
+	assert.Greater(t, stats.TTMoveBestRatio(), 0.0)
+	assert.LessOrEqual(t, stats.TTMoveBestRatio(), 1.0)
+	assert.Contains(t, stats.String(), "TTMoveBestRatio")
+
+	// without TT no TT move can be the best move
+	defer func() { config.Settings.Search.UseTT = true }()
+	config.Settings.Search.UseTT = false
+	search = NewSearch()
+	search.StartSearch(*p, *sl)
+	search.WaitWhileSearching()
+	assert.Zero(t, search.Statistics().TTMoveBest)
+}
+
+func TestRootMoveNodes(t *testing.T) {
+	config.Settings.Search.UseBook = false
+	search := NewSearch()
+	p := position.NewPosition()
+	sl := NewSearchLimits()
+	sl.Depth = 5
+	search.StartSearch(*p, *sl)
+	search.WaitWhileSearching()
+	result := search.LastSearchResult()
+	assert.Len(t, result.RootMoveNodes, 20)
+	sum := uint64(0)
+	for _, n := range result.RootMoveNodes {
+		assert.Greater(t, n, uint64(0))
+		sum += n
+	}
+	// only the iteration counter nodes are not counted for a root move
+	assert.EqualValues(t, result.Nodes-uint64(result.SearchDepth), sum)
+}
+
+func TestRootMoveNoise(t *testing.T) {
+	defer func() { config.Settings.Search.RootMoveNoise = 0 }()
+	config.Settings.Search.UseBook = false
+	p := position.NewPosition()
+	sl := NewSearchLimits()
+	sl.Depth = 2
+
+	// without noise the best move is always the same
+	search := NewSearch()
+	chosen := map[Move]bool{}
+	for seed := uint64(1); seed <= 10; seed++ {
+		search.rootNoiseSeed = seed
+		search.StartSearch(*p, *sl)
+		search.WaitWhileSearching()
+		chosen[search.LastSearchResult().BestMove] = true
+	}
+	assert.Len(t, chosen, 1)
+
+	// with noise the best move among nearly equal moves differs with the seed
+	config.Settings.Search.RootMoveNoise = 50
+	chosen = map[Move]bool{}
+	for seed := uint64(1); seed <= 10; seed++ {
+		search.rootNoiseSeed = seed
+		assert.LessOrEqual(t, int(search.rootMoveNoise(CreateMove(SqE2, SqE4, Normal, PtNone))), 50)
+		search.StartSearch(*p, *sl)
+		search.WaitWhileSearching()
+		result := search.LastSearchResult()
+		chosen[result.BestMove] = true
+		// the noise is only used for the selection and not reported
+		for _, m := range *search.rootMoves {
+			if m.MoveOf() == result.BestMove {
+				assert.EqualValues(t, m.ValueOf()-search.rootMoveNoise(m), result.BestValue)
+			}
+		}
+	}
+	assert.Greater(t, len(chosen), 1)
+}
+
+func TestReportedPv(t *testing.T) {
+	defer func() { config.Settings.Search.MaxPvLength = 20 }()
+	config.Settings.Search.UseBook = false
+	p := position.NewPosition("r3k2r/1ppn3p/2q1q1n1/8/2q1Pp2/6R1/p1p2PPP/1R4K1 b kq e3 0 1")
+	sl := NewSearchLimits()
+	sl.Depth = 6
+	search := NewSearch()
+	search.StartSearch(*p, *sl)
+	search.WaitWhileSearching()
+
+	// reported pv never exceeds the cap and all moves are legal
+	mg := movegen.NewMoveGen()
+	for _, maxLength := range []int{1, 3, 20} {
+		config.Settings.Search.MaxPvLength = maxLength
+		pv := search.reportedPv()
+		assert.Greater(t, pv.Len(), 0)
+		assert.LessOrEqual(t, pv.Len(), maxLength)
+		pos := *p
+		for i := 0; i < pv.Len(); i++ {
+			assert.True(t, mg.ValidateMove(&pos, pv.At(i)), pv.StringUci())
+			pos.DoMove(pv.At(i))
+		}
+	}
+
+	// the reported pv is cut at the first illegal move
+	config.Settings.Search.MaxPvLength = 0
+	search.currentPosition = position.NewPosition()
+	search.pv[0].Clear()
+	for _, m := range []string{"e2e4", "e7e5", "g1f3", "b8c6", "f1b5", "a7a6", "e2e4"} {
+		search.pv[0].PushBack(CreateMove(MakeSquare(m[0:2]), MakeSquare(m[2:4]), Normal, PtNone))
+	}
+	pv := search.reportedPv()
+	assert.Equal(t, "e2e4 e7e5 g1f3 b8c6 f1b5 a7a6", pv.StringUci())
+	config.Settings.Search.MaxPvLength = 4
+	pv = search.reportedPv()
+	assert.Equal(t, "e2e4 e7e5 g1f3 b8c6", pv.StringUci())
+	assert.Equal(t, position.StartFen, search.currentPosition.StringFen())
+}
+
+func TestHandicap(t *testing.T) {
+	defer func() {
+		config.Settings.Search.MaxDepthPerMove = 0
+		config.Settings.Search.MaxNodesPerMove = 0
+		config.Settings.Search.BlunderProbability = 0.0
+	}()
+	config.Settings.Search.UseBook = false
+	p := position.NewPosition()
+	search := NewSearch()
+
+	// search effort is capped
+	config.Settings.Search.MaxDepthPerMove = 2
+	search.StartSearch(*p, *NewSearchLimits())
+	search.WaitWhileSearching()
+	assert.EqualValues(t, 2, search.LastSearchResult().SearchDepth)
+	config.Settings.Search.MaxDepthPerMove = 0
+	config.Settings.Search.MaxNodesPerMove = 1_000
+	sl := NewSearchLimits()
+	sl.Depth = 6
+	search.StartSearch(*p, *sl)
+	search.WaitWhileSearching()
+	assert.Less(t, search.LastSearchResult().Nodes, uint64(2_000))
+	config.Settings.Search.MaxNodesPerMove = 0
+
+	// with blunder probability 1.0 a random legal move is played
+	config.Settings.Search.BlunderProbability = 1.0
+	sl = NewSearchLimits()
+	sl.Depth = 2
+	for seed := int64(1); seed <= 5; seed++ {
+		search.blunderRand = rand.New(rand.NewSource(seed))
+		search.StartSearch(*p, *sl)
+		search.WaitWhileSearching()
+		expected := rand.New(rand.NewSource(seed))
+		expected.Float64()
+		legalMoves := movegen.NewMoveGen().GenerateLegalMoves(p, movegen.GenAll)
+		assert.Equal(t, legalMoves.At(expected.Intn(legalMoves.Len())).MoveOf(), search.LastSearchResult().BestMove)
+		assert.Equal(t, MoveNone, search.LastSearchResult().PonderMove)
+		result := search.LastSearchResult()
+		assert.Equal(t, ValueNA, result.BestValue)
+		assert.Equal(t, 1, result.Pv.Len())
+		assert.Equal(t, result.BestMove, result.Pv.At(0).MoveOf())
+	}
+
+	// infinite searches are not handicapped
+	config.Settings.Search.MaxDepthPerMove = 2
+	sl = NewSearchLimits()
+	sl.Infinite = true
+	search.StartSearch(*p, *sl)
+	time.Sleep(500 * time.Millisecond)
+	search.StopSearch()
+	search.WaitWhileSearching()
+	assert.Greater(t, search.LastSearchResult().SearchDepth, 2)
+	assert.NotEqual(t, ValueNA, search.LastSearchResult().BestValue)
+}
+
+func TestSearchResult(t *testing.T) {
+	config.Settings.Search.UseBook = false
+	search := NewSearch()
+	p := position.NewPosition()
+	sl := NewSearchLimits()
+	sl.Depth = 5
+	search.StartSearch(*p, *sl)
+	search.WaitWhileSearching()
+	result := search.LastSearchResult()
+	logTest.Debug(result.String())
+	assert.EqualValues(t, result.BestMove, result.Pv.At(0).MoveOf())
+	assert.EqualValues(t, result.PonderMove, result.Pv.At(1).MoveOf())
+	assert.EqualValues(t, result.BestValue, result.Pv.At(0).ValueOf())
+	assert.EqualValues(t, EXACT, result.Bound)
+	assert.EqualValues(t, 0, result.Mate)
+	assert.EqualValues(t, 5, result.SearchDepth)
+	assert.GreaterOrEqual(t, result.ExtraDepth, result.SearchDepth)
+	assert.EqualValues(t, search.NodesVisited(), result.Nodes)
+	assert.Greater(t, result.SearchTime.Nanoseconds(), int64(0))
+	assert.False(t, result.BookMove)
+
+	// mate in one
+	p = position.NewPosition("6k1/5ppp/8/8/8/8/8/R5K1 w - -")
+	search.StartSearch(*p, *sl)
+	search.WaitWhileSearching()
+	result = search.LastSearchResult()
+	assert.EqualValues(t, "a1a8", result.BestMove.StringUci())
+	assert.EqualValues(t, result.BestMove, result.Pv.At(0).MoveOf())
+	assert.EqualValues(t, ValueCheckMate-1, result.BestValue)
+	assert.EqualValues(t, EXACT, result.Bound)
+	assert.EqualValues(t, 1, result.Mate)
+}
+
+func TestWinDrawLoss(t *testing.T) {
+	// a large advantage is a likely win
+	win, draw, loss := WinDrawLoss(Value(800), 0.5)
+	assert.Greater(t, win, 900)
+	assert.Less(t, loss, 10)
+	assert.EqualValues(t, 1000, win+draw+loss)
+
+	// a large disadvantage is a likely loss
+	win, draw, loss = WinDrawLoss(Value(-800), 0.5)
+	assert.Greater(t, loss, 900)
+	assert.EqualValues(t, 1000, win+draw+loss)
+
+	// a balanced position is a likely draw
+	win, draw, loss = WinDrawLoss(ValueZero, 1.0)
+	assert.Greater(t, draw, 900)
+	assert.Equal(t, win, loss)
+	assert.EqualValues(t, 1000, win+draw+loss)
+
+	// the same advantage wins more likely in the end game
+	opening, _, _ := WinDrawLoss(Value(250), 1.0)
+	endgame, _, _ := WinDrawLoss(Value(250), 0.0)
+	assert.Greater(t, endgame, opening)
+
+	// mates are certain
+	win, draw, loss = WinDrawLoss(ValueCheckMate-5, 1.0)
+	assert.Equal(t, []int{1000, 0, 0}, []int{win, draw, loss})
+	win, draw, loss = WinDrawLoss(-ValueCheckMate+4, 1.0)
+	assert.Equal(t, []int{0, 0, 1000}, []int{win, draw, loss})
+}
+
 func TestSearchDev(t *testing.T) {
 	t.SkipNow()
 	config.Settings.Search.UseBook = false
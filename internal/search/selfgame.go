@@ -0,0 +1,131 @@
+//
+// FrankyGo - UCI chess engine in GO for learning purposes
+//
+// MIT License
+//
+// Copyright (c) 2018-2020 Frank Kopp
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in all
+// copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE
+// SOFTWARE.
+//
+
+package search
+
+import (
+	"strings"
+	"time"
+
+	"github.com/frankkopp/FrankyGo/internal/movegen"
+	"github.com/frankkopp/FrankyGo/internal/position"
+	. "github.com/frankkopp/FrankyGo/internal/types"
+)
+
+// maximum number of plies of a self game before it is aborted
+const maxSelfGamePlies = 1_000
+
+// PlaySelfGame plays a game of the engine against itself starting from
+// the given fen (start position if empty). Every move is searched with
+// the given search limits - clocks of time controlled limits are not
+// updated during the game. The game ends with checkmate, stalemate or a
+// draw by repetition, the 50-moves rule or insufficient material.
+// Returns the result ("1-0", "0-1", "1/2-1/2" or "*" if the game has been
+// aborted) and the game as PGN.
+func PlaySelfGame(sl Limits, openingFen string) (string, string) {
+	fen := openingFen
+	if fen == "" {
+		fen = position.StartFen
+	}
+	p, err := position.NewPositionFen(fen)
+	if err != nil {
+		return "*", ""
+	}
+	startPosition := *p
+
+	s := NewSearch()
+	s.NewGame()
+	mg := movegen.NewMoveGen()
+	var sanMoves []string
+
+	result := "*"
+	for ply := 0; ply < maxSelfGamePlies; ply++ {
+		if !mg.HasLegalMove(p) {
+			if p.HasCheck() {
+				if p.NextPlayer() == White {
+					result = "0-1"
+				} else {
+					result = "1-0"
+				}
+			} else {
+				result = "1/2-1/2"
+			}
+			break
+		}
+		if p.HasInsufficientMaterial() || p.HalfMoveClock() >= 100 || p.CheckRepetitions(2) {
+			result = "1/2-1/2"
+			break
+		}
+		s.StartSearch(*p, sl)
+		s.WaitWhileSearching()
+		bestMove := s.LastSearchResult().BestMove
+		if bestMove == MoveNone {
+			break
+		}
+		sanMoves = append(sanMoves, mg.GetSanFromMove(p, bestMove))
+		p.DoMove(bestMove)
+	}
+	s.log.Infof("Self game finished with %s after %d plies", result, len(sanMoves))
+
+	return result, selfGamePgn(&startPosition, openingFen, sanMoves, result)
+}
+
+// selfGamePgn returns a PGN of the given moves in SAN played from
+// the start position.
+func selfGamePgn(start *position.Position, openingFen string, sanMoves []string, result string) string {
+	var pgn strings.Builder
+	pgn.WriteString("[Event \"FrankyGo Self Game\"]\n")
+	pgn.WriteString("[Site \"?\"]\n")
+	pgn.WriteString(out.Sprintf("[Date \"%s\"]\n", time.Now().Format("2006.01.02")))
+	pgn.WriteString("[Round \"-\"]\n")
+	pgn.WriteString("[White \"FrankyGo\"]\n")
+	pgn.WriteString("[Black \"FrankyGo\"]\n")
+	pgn.WriteString(out.Sprintf("[Result \"%s\"]\n", result))
+	if openingFen != "" {
+		pgn.WriteString("[SetUp \"1\"]\n")
+		pgn.WriteString(out.Sprintf("[FEN \"%s\"]\n", start.StringFen()))
+	}
+	pgn.WriteString("\n")
+
+	moveNumber := (start.GamePly() + 2) / 2
+	blackToMove := start.NextPlayer() == Black
+	for i, san := range sanMoves {
+		if !blackToMove {
+			pgn.WriteString(out.Sprintf("%d. ", moveNumber))
+		} else if i == 0 {
+			pgn.WriteString(out.Sprintf("%d... ", moveNumber))
+		}
+		pgn.WriteString(san)
+		pgn.WriteString(" ")
+		if blackToMove {
+			moveNumber++
+		}
+		blackToMove = !blackToMove
+	}
+	pgn.WriteString(result)
+	pgn.WriteString("\n")
+	return pgn.String()
+}
@@ -27,6 +27,9 @@
 package search
 
 import (
+	"encoding/json"
+	"strings"
+
 	"github.com/frankkopp/FrankyGo/internal/moveslice"
 	. "github.com/frankkopp/FrankyGo/internal/types"
 )
@@ -37,62 +40,133 @@ import (
 
 // Statistics are extra data and stats not essential for a functioning search
 type Statistics struct {
-	QFpPrunings uint64
-
-	BestMoveChange       uint64
-	AspirationResearches uint64
-
-	BetaCuts    uint64
-	BetaCuts1st uint64
-
-	RfpPrunings uint64
-	FpPrunings  uint64
-
-	ThreatExtension uint64
-	NMPMateAlpha    uint64
-	NMPMateBeta     uint64
-
-	CheckExtension uint64
-	CheckInQS      uint64
-
-	LmpCuts       uint64
-	LmrResearches uint64
-	LmrReductions uint64
-
-	Evaluations       uint64
-	EvaluationsFromTT uint64
-
-	TTHit      uint64
-	TTMiss     uint64
-	TTMoveUsed uint64
-	NoTTMove   uint64
-	TTCuts     uint64
-	TTNoCuts   uint64
-
-	IIDmoves    uint64
-	IIDsearches uint64
-
-	LeafPositionsEvaluated uint64
-	Checkmates             uint64
-	Stalemates             uint64
-	RootPvsResearches      uint64
-	PvsResearches          uint64
-	NullMoveCuts           uint64
-	StandpatCuts           uint64
-	Mdp                    uint64
-
-	CurrentIterationDepth    int
-	CurrentSearchDepth       int
-	CurrentExtraSearchDepth  int
-	CurrentVariation         moveslice.MoveSlice
-	CurrentRootMoveIndex     int
-	CurrentRootMove          Move
-	CurrentBestRootMove      Move
-	CurrentBestRootMoveValue Value
+	QFpPrunings     uint64 `json:"QFpPrunings"`
+	QSCaptureLimits uint64 `json:"QSCaptureLimits"`
+	NoMovesErrors   uint64 `json:"NoMovesErrors"` // nodes without generated moves but with legal moves
+
+	BestMoveChange       uint64 `json:"BestMoveChange"`
+	AspirationResearches uint64 `json:"AspirationResearches"`
+
+	BetaCuts    uint64 `json:"BetaCuts"`
+	BetaCuts1st uint64 `json:"BetaCuts1st"`
+
+	FirstCaptures        uint64 `json:"FirstCaptures"` // only counted in debug builds
+	FirstCapturesWinning uint64 `json:"FirstCapturesWinning"`
+
+	RfpPrunings uint64 `json:"RfpPrunings"`
+	FpPrunings  uint64 `json:"FpPrunings"`
+
+	ThreatExtension uint64 `json:"ThreatExtension"`
+	NMPMateAlpha    uint64 `json:"NMPMateAlpha"`
+	NMPMateBeta     uint64 `json:"NMPMateBeta"`
+
+	CheckExtension uint64 `json:"CheckExtension"`
+	CheckInQS      uint64 `json:"CheckInQS"`
+
+	PassedPawnExtension uint64 `json:"PassedPawnExtension"`
+
+	LmpCuts       uint64 `json:"LmpCuts"`
+	LmrResearches uint64 `json:"LmrResearches"`
+	LmrReductions uint64 `json:"LmrReductions"`
+	SeePrunings   uint64 `json:"SeePrunings"`
+
+	CutNodes uint64 `json:"CutNodes"` // null window nodes expected to fail high
+	AllNodes uint64 `json:"AllNodes"` // null window nodes expected to fail low
+
+	Evaluations       uint64 `json:"Evaluations"`
+	EvaluationsFromTT uint64 `json:"EvaluationsFromTT"`
+
+	TTHit      uint64 `json:"TTHit"`
+	TTMiss     uint64 `json:"TTMiss"`
+	TTMoveUsed uint64 `json:"TTMoveUsed"`
+	TTMoveBest uint64 `json:"TTMoveBest"` // tt move was the first move to raise alpha or cut
+	NoTTMove   uint64 `json:"NoTTMove"`
+	TTCuts     uint64 `json:"TTCuts"`
+	TTNoCuts   uint64 `json:"TTNoCuts"`
+
+	IIDmoves    uint64 `json:"IIDmoves"`
+	IIDsearches uint64 `json:"IIDsearches"`
+
+	LeafPositionsEvaluated uint64 `json:"LeafPositionsEvaluated"`
+	Checkmates             uint64 `json:"Checkmates"`
+	Stalemates             uint64 `json:"Stalemates"`
+	RootPvsResearches      uint64 `json:"RootPvsResearches"`
+	PvsResearches          uint64 `json:"PvsResearches"`
+	NullMoveCuts           uint64 `json:"NullMoveCuts"`
+	StandpatCuts           uint64 `json:"StandpatCuts"`
+	Mdp                    uint64 `json:"Mdp"`
+
+	CurrentIterationDepth    int                 `json:"CurrentIterationDepth"`
+	CurrentSearchDepth       int                 `json:"CurrentSearchDepth"`
+	CurrentExtraSearchDepth  int                 `json:"CurrentExtraSearchDepth"`
+	CurrentVariation         moveslice.MoveSlice `json:"CurrentVariation"`
+	CurrentRootMoveIndex     int                 `json:"CurrentRootMoveIndex"`
+	CurrentRootMove          Move                `json:"CurrentRootMove"`
+	CurrentBestRootMove      Move                `json:"CurrentBestRootMove"`
+	CurrentBestRootMoveValue Value               `json:"CurrentBestRootMoveValue"`
+
+	// breakdown by remaining search depth (0 = quiescence search)
+	PerDepth DepthStatistics `json:"PerDepth"`
 }
 
 func (s *Statistics) String() string {
-	return out.Sprintf("%+v", *s)
+	return out.Sprintf("%+v TTMoveBestRatio:%.2f", *s, s.TTMoveBestRatio())
+}
+
+// JSON returns the statistics as a JSON object for test harnesses and
+// dashboards. The keys are given by the json tags of Statistics (the field
+// names) plus the derived TTMoveBestRatio. Keep the tags when renaming a
+// field so the keys stay stable. Moves and values are exported as their
+// numeric representation. The result can be unmarshalled back into
+// Statistics.
+func (s *Statistics) JSON() ([]byte, error) {
+	return json.Marshal(struct {
+		*Statistics
+		TTMoveBestRatio float64 `json:"TTMoveBestRatio"`
+	}{s, s.TTMoveBestRatio()})
+}
+
+// TTMoveBestRatio returns the share of used TT moves which turned
+// out to be the best move of the node.
+func (s *Statistics) TTMoveBestRatio() float64 {
+	if s.TTMoveUsed == 0 {
+		return 0
+	}
+	return float64(s.TTMoveBest) / float64(s.TTMoveUsed)
+}
+
+// DepthStats are counters for nodes with the same remaining search depth
+type DepthStats struct {
+	Nodes    uint64 `json:"Nodes"`
+	BetaCuts uint64 `json:"BetaCuts"`
+	TTHits   uint64 `json:"TTHits"`
+	Prunings uint64 `json:"Prunings"`
+}
+
+// DepthStatistics holds DepthStats indexed by remaining search depth
+type DepthStatistics [MaxDepth + 1]DepthStats
+
+// Nodes returns the sum of nodes over all depths
+func (d *DepthStatistics) Nodes() uint64 {
+	sum := uint64(0)
+	for i := range d {
+		sum += d[i].Nodes
+	}
+	return sum
+}
+
+// String returns a table of the statistics for all depths
+// which have nodes.
+func (d DepthStatistics) String() string {
+	var os strings.Builder
+	os.WriteString(out.Sprintf("%5s %15s %15s %15s %15s\n", "Depth", "Nodes", "BetaCuts", "TTHits", "Prunings"))
+	for i := range d {
+		if d[i].Nodes == 0 {
+			continue
+		}
+		os.WriteString(out.Sprintf("%5d %15d %15d %15d %15d\n", i, d[i].Nodes, d[i].BetaCuts, d[i].TTHits, d[i].Prunings))
+	}
+	return os.String()
 }
 
 // // counter for cut off to measure quality of move ordering
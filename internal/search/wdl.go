@@ -0,0 +1,58 @@
+//
+// FrankyGo - UCI chess engine in GO for learning purposes
+//
+// MIT License
+//
+// Copyright (c) 2018-2020 Frank Kopp
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in all
+// copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE
+// SOFTWARE.
+//
+
+package search
+
+import (
+	"math"
+
+	"github.com/frankkopp/FrankyGo/internal/config"
+	. "github.com/frankkopp/FrankyGo/internal/types"
+)
+
+// WinDrawLoss maps a search value from the view of the side to move to
+// win, draw and loss probabilities in permille using a logistic model.
+// The value in centipawns at which a win becomes 50% likely is
+// interpolated by the game phase factor (1.0 = opening, 0.0 = end game)
+// between WdlMidpoint and WdlMidpointEnd. WdlSpread defines how fast the
+// probabilities change around this point. Mate values are certain wins
+// or losses. The three values always sum up to 1000.
+func WinDrawLoss(value Value, gamePhaseFactor float64) (win int, draw int, loss int) {
+	if value.IsCheckMateValue() {
+		if value > 0 {
+			return 1000, 0, 0
+		}
+		return 0, 0, 1000
+	}
+	cp := float64(value.ToCentipawns())
+	midpoint := float64(config.Settings.Search.WdlMidpointEnd) +
+		float64(config.Settings.Search.WdlMidpoint-config.Settings.Search.WdlMidpointEnd)*gamePhaseFactor
+	spread := float64(config.Settings.Search.WdlSpread)
+	win = int(math.Round(1000 / (1 + math.Exp((midpoint-cp)/spread))))
+	loss = int(math.Round(1000 / (1 + math.Exp((midpoint+cp)/spread))))
+	draw = 1000 - win - loss
+	return win, draw, loss
+}
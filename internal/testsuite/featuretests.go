@@ -27,7 +27,7 @@
 package testsuite
 
 import (
-	"io/ioutil"
+	"os"
 	"path/filepath"
 	"sort"
 	"strings"
@@ -37,87 +37,124 @@ import (
 	"github.com/frankkopp/FrankyGo/internal/util"
 )
 
-// FeatureTests runs all epd tests in a folder and prints a report
-func FeatureTests(folder string, searchTime time.Duration, searchDepth int) string {
+// FileResult is the result of a single test suite file run by RunDirectory.
+type FileResult struct {
+	File   string // path relative to the directory
+	Result SuiteResult
+}
 
-	// get all tests in folder
-	files, err := ioutil.ReadDir(folder)
+// DirectoryResult is the result of running all test suite files of a
+// directory. The embedded SuiteResult holds the sums over all files.
+type DirectoryResult struct {
+	SuiteResult
+	Dir      string
+	Files    []FileResult  // sorted by file
+	Duration time.Duration // wall time to run all files
+}
+
+// RunDirectory runs all test suite files (.epd and .epd.gz) in the given
+// directory and with recursive also in all its sub directories. It returns
+// the results per file and the sums over all files.
+func RunDirectory(dir string, searchTime time.Duration, depth int, recursive bool) (*DirectoryResult, error) {
+	files, err := epdFiles(dir, recursive)
 	if err != nil {
-		log.Fatal(err)
-	}
-	var list []string
-	for _, f := range files {
-		if filepath.Ext(f.Name()) == ".epd" {
-			list = append(list, f.Name())
-		}
+		return nil, err
 	}
 
-	// prepare
 	config.Settings.Search.UseBook = false
-	result := make(map[string]TestSuite, 10)
-	executedTests := 0
-	totalNodes := uint64(0)
-	var totalTime time.Duration
-	totalSuccess := 0
-	totalFailed := 0
-	totalSkipped := 0
-	totalNotTested := 0
-	totalTests := 0
+	result := &DirectoryResult{
+		Dir:   dir,
+		Files: make([]FileResult, 0, len(files)),
+	}
 
-	// run all test files
 	start := time.Now()
-	for _, t := range list {
-
-		// Run test
-		ts, _ := NewTestSuite(folder+t, searchTime, searchDepth)
+	for _, file := range files {
+		ts, err := NewTestSuite(filepath.Join(dir, file), searchTime, depth)
+		if err != nil {
+			return nil, err
+		}
+		// the time budget is for all files together
+		if Options.MaxTotalTime > 0 {
+			ts.deadline = start.Add(Options.MaxTotalTime)
+		}
 		ts.RunTests()
+		fileResult := FileResult{File: file}
+		// a file without any tests has no result
+		if ts.LastResult != nil {
+			fileResult.Result = *ts.LastResult
+		}
+		result.add(&fileResult.Result)
+		result.Files = append(result.Files, fileResult)
+	}
+	result.Duration = time.Since(start)
+
+	return result, nil
+}
 
-		// save result
-		executedTests += len(ts.Tests)
-		result[t] = *ts
+// epdFiles returns the sorted paths relative to dir of all test suite
+// files in the given directory and optionally in its sub directories.
+func epdFiles(dir string, recursive bool) ([]string, error) {
+	var list []string
+	err := filepath.Walk(dir, func(path string, info os.FileInfo, err error) error {
+		if err != nil {
+			return err
+		}
+		if info.IsDir() {
+			if !recursive && path != dir {
+				return filepath.SkipDir
+			}
+			return nil
+		}
+		name := strings.ToLower(info.Name())
+		if strings.HasSuffix(name, ".epd") || strings.HasSuffix(name, ".epd.gz") {
+			rel, err := filepath.Rel(dir, path)
+			if err != nil {
+				return err
+			}
+			list = append(list, rel)
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
 	}
-	duration := time.Since(start)
+	sort.Strings(list)
+	return list, nil
+}
 
-	// sort order
-	keys := make([]string, 0, len(result))
-	for name := range result {
-		keys = append(keys, name)
+// FeatureTests runs all epd tests in a folder and prints a report
+func FeatureTests(folder string, searchTime time.Duration, searchDepth int) string {
+
+	// run all test files in the folder
+	result, err := RunDirectory(folder, searchTime, searchDepth, false)
+	if err != nil {
+		log.Fatal(err)
 	}
-	sort.Strings(keys)
 
 	// print report
 	os := strings.Builder{}
 	os.WriteString(out.Sprintf("Feature Test Result Report\n"))
 	os.WriteString(out.Sprintf("==============================================================================\n"))
 	os.WriteString(out.Sprintf("Date                 : %s\n", time.Now()))
-	os.WriteString(out.Sprintf("Test took            : %s\n", duration))
+	os.WriteString(out.Sprintf("Test took            : %s\n", result.Duration))
 	os.WriteString(out.Sprintf("Test setup           : search time: %s max depth: %d\n", searchTime, searchDepth))
-	os.WriteString(out.Sprintf("Number of testsuites : %d\n", len(result)))
-	os.WriteString(out.Sprintf("Number of tests      : %d\n", executedTests))
+	os.WriteString(out.Sprintf("Number of testsuites : %d\n", len(result.Files)))
+	os.WriteString(out.Sprintf("Number of tests      : %d\n", result.Counter))
 	os.WriteString(out.Sprintln())
 	os.WriteString(out.Sprintf("===============================================================================================================================================\n"))
 	os.WriteString(out.Sprintf(" %-25s | %-12s | %-15s | %-10s | %-10s | %-10s | %-10s | %-6s | %s\n", "Test Suite", "Success Rate", "          Nodes", "Successful", "    Failed", "   Skipped", "       N/A", "  Tests", "File"))
 	os.WriteString(out.Sprintf("===============================================================================================================================================\n"))
-	for _, name := range keys {
-		r := result[name]
-		successRate := float64(r.LastResult.SuccessCounter) / float64(r.LastResult.Counter) * 100
-		totalNodes += r.LastResult.Nodes
-		totalTime += r.LastResult.Time
-		totalSuccess += r.LastResult.SuccessCounter
-		totalFailed += r.LastResult.FailedCounter
-		totalSkipped += r.LastResult.SkippedCounter
-		totalNotTested += r.LastResult.NotTestedCounter
-		totalTests += r.LastResult.Counter
+	for _, f := range result.Files {
+		r := f.Result
 		// os.WriteString(out.Sprintf(" %-25s |      %5.1f %% | %11d |   %8d |   %8d |   %8d |   %8d |  %6d | %s\n", name, 100.0, 999999999, 99999, 99999, 99999, 99999, 9999, folder+name))
-		os.WriteString(out.Sprintf(" %-25s |      %5.1f %% | %15d |   %8d |   %8d |   %8d |   %8d |  %6d | %s\n", name, successRate, r.LastResult.Nodes, r.LastResult.SuccessCounter, r.LastResult.FailedCounter, r.LastResult.SkippedCounter, r.LastResult.NotTestedCounter, len(r.Tests), folder+name))
+		os.WriteString(out.Sprintf(" %-25s |      %5.1f %% | %15d |   %8d |   %8d |   %8d |   %8d |  %6d | %s\n", f.File, r.SuccessRate(), r.Nodes, r.SuccessCounter, r.FailedCounter, r.SkippedCounter, r.NotTestedCounter, r.Counter, filepath.Join(folder, f.File)))
 	}
-	successRate := float64(totalSuccess) / float64(totalTests) * 100
 	os.WriteString(out.Sprintf("-----------------------------------------------------------------------------------------------------------------------------------------------\n"))
-	os.WriteString(out.Sprintf(" %-25s |      %5.1f %% | %15d |   %8d |   %8d |   %8d |   %8d |  %6d | %s\n", "TOTAL", successRate, totalNodes, totalSuccess, totalFailed, totalSkipped, totalNotTested, totalTests, ""))
+	os.WriteString(out.Sprintf(" %-25s |      %5.1f %% | %15d |   %8d |   %8d |   %8d |   %8d |  %6d | %s\n", "TOTAL", result.SuccessRate(), result.Nodes, result.SuccessCounter, result.FailedCounter, result.SkippedCounter, result.NotTestedCounter, result.Counter, ""))
 	os.WriteString(out.Sprintf("===============================================================================================================================================\n"))
 	os.WriteString(out.Sprintln())
-	os.WriteString(out.Sprintf("Total Time: %s\n", totalTime))
-	os.WriteString(out.Sprintf("Total NPS : %d\n", util.Nps(totalNodes, totalTime)))
+	os.WriteString(out.Sprintf("Total Time: %s\n", result.Time))
+	os.WriteString(out.Sprintf("Total NPS : %d\n", util.Nps(result.Nodes, result.Time)))
 	os.WriteString(out.Sprintln())
 	os.WriteString(out.Sprintf("Configuration: %s\n", config.Settings.String()))
 	os.WriteString(out.Sprintln())
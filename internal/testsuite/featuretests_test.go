@@ -27,12 +27,70 @@
 package testsuite
 
 import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
 	"testing"
 	"time"
 
+	"github.com/stretchr/testify/assert"
+
 	"github.com/frankkopp/FrankyGo/internal/config"
 )
 
+func TestRunDirectory(t *testing.T) {
+	dir, err := ioutil.TempDir("", "epds")
+	assert.NoError(t, err)
+	defer os.RemoveAll(dir)
+	assert.NoError(t, os.Mkdir(filepath.Join(dir, "sub"), 0755))
+	files := map[string]string{
+		"a.epd": "6k1/5ppp/8/8/8/8/8/R5K1 w - - bm Ra8; id \"a-1\";\n" +
+			"6k1/5ppp/8/8/8/8/8/R5K1 w - - bm Kh1; id \"a-2\";\n",
+		"b.epd":     "6k1/P7/8/8/8/8/8/3K4 w - - bm a8=Q; id \"b-1\";\n",
+		"notes.txt": "6k1/P7/8/8/8/8/8/3K4 w - - bm a8=Q; id \"n-1\";\n",
+		"sub/c.epd": "6k1/5ppp/8/8/8/8/8/R5K1 w - - dm 1; id \"c-1\";\n",
+	}
+	for name, content := range files {
+		assert.NoError(t, ioutil.WriteFile(filepath.Join(dir, name), []byte(content), 0644))
+	}
+
+	result, err := RunDirectory(dir, 0, 4, false)
+	assert.NoError(t, err)
+	assert.EqualValues(t, 2, len(result.Files))
+	assert.EqualValues(t, "a.epd", result.Files[0].File)
+	assert.EqualValues(t, 2, result.Files[0].Result.Counter)
+	assert.EqualValues(t, 1, result.Files[0].Result.SuccessCounter)
+	assert.EqualValues(t, 1, result.Files[0].Result.FailedCounter)
+	assert.EqualValues(t, "b.epd", result.Files[1].File)
+	assert.EqualValues(t, 1, result.Files[1].Result.SuccessCounter)
+	assert.EqualValues(t, 3, result.Counter)
+	assert.EqualValues(t, 2, result.SuccessCounter)
+	assert.EqualValues(t, 1, result.FailedCounter)
+	assert.EqualValues(t, result.Files[0].Result.Nodes+result.Files[1].Result.Nodes, result.Nodes)
+	assert.Greater(t, result.Nodes, uint64(0))
+	assert.Greater(t, result.Duration.Nanoseconds(), int64(0))
+
+	// including sub directories
+	result, err = RunDirectory(dir, 0, 4, true)
+	assert.NoError(t, err)
+	assert.EqualValues(t, 3, len(result.Files))
+	assert.EqualValues(t, filepath.Join("sub", "c.epd"), result.Files[2].File)
+	assert.EqualValues(t, 4, result.Counter)
+	assert.EqualValues(t, 3, result.SuccessCounter)
+	assert.EqualValues(t, 75, result.SuccessRate())
+
+	_, err = RunDirectory(filepath.Join(dir, "missing"), 0, 4, false)
+	assert.Error(t, err)
+
+	// the time budget is for all files
+	defer func() { Options.MaxTotalTime = 0 }()
+	Options.MaxTotalTime = time.Nanosecond
+	result, err = RunDirectory(dir, 0, 4, false)
+	assert.NoError(t, err)
+	assert.EqualValues(t, 3, result.Counter)
+	assert.EqualValues(t, 3, result.NotTestedCounter)
+}
+
 func TestFeatureTests(t *testing.T) {
 	if testing.Short() {
 		t.Skip("skipping test in short mode.")
@@ -46,7 +104,7 @@ func TestFeatureTests(t *testing.T) {
 	{
 		config.Settings.Search.UseQuiescence = true
 		config.Settings.Search.UseQSStandpat = true
-		config.Settings.Search.UseSEE = true
+		config.Settings.Search.UseQSSee = true
 		config.Settings.Search.UsePromNonQuiet = true
 
 		config.Settings.Search.UseTT = true
@@ -77,6 +135,7 @@ func TestFeatureTests(t *testing.T) {
 		config.Settings.Search.UseExtAddDepth = true
 		config.Settings.Search.UseCheckExt = true
 		config.Settings.Search.UseThreatExt = false
+		config.Settings.Search.UsePassedPawnExt = false
 
 		config.Settings.Search.UseRFP = true
 		config.Settings.Search.UseFP = true
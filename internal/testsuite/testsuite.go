@@ -31,11 +31,15 @@
 // https://www.chessprogramming.org/Extended_Position_Description
 // For the purpose of testing our chess engine only the opcodes "bm" (best move), "am"
 // (avoid move) and "dm" (direct mate) are implemented.
+// Test suite files with the extension .gz are decompressed transparently.
 package testsuite
 
 import (
 	"bufio"
+	"compress/gzip"
 	"fmt"
+	"io"
+	"math"
 	"os"
 	"path/filepath"
 	"regexp"
@@ -60,6 +64,17 @@ import (
 var out = message.NewPrinter(language.German)
 var log *logging.Logger
 
+// options are general options for running test suites.
+type options struct {
+	// Maximum time for a whole run of a test suite or of all test suites
+	// of a directory (0 = no limit). When the time is up all remaining
+	// tests are not run and reported as not tested.
+	MaxTotalTime time.Duration
+}
+
+// Options for running test suites.
+var Options = options{}
+
 // testType defines the data type for the implemented opcode for EPD tests
 // which are defined as constants below.
 type testType uint8
@@ -92,6 +107,52 @@ type SuiteResult struct {
 	NotTestedCounter int
 	Nodes            uint64
 	Time             time.Duration
+	BetaCuts         uint64
+	BetaCuts1st      uint64
+	EbfSum           float64
+	EbfCounter       int
+}
+
+// BetaCuts1stPercent returns the percentage of beta cuts which occurred
+// on the first move searched over all tests. This is a measure of the
+// quality of the move ordering.
+func (sr *SuiteResult) BetaCuts1stPercent() float64 {
+	if sr.BetaCuts == 0 {
+		return 0
+	}
+	return 100 * float64(sr.BetaCuts1st) / float64(sr.BetaCuts)
+}
+
+// SuccessRate returns the percentage of successful tests.
+func (sr *SuiteResult) SuccessRate() float64 {
+	if sr.Counter == 0 {
+		return 0
+	}
+	return 100 * float64(sr.SuccessCounter) / float64(sr.Counter)
+}
+
+// add adds the counters of the given result to this result.
+func (sr *SuiteResult) add(r *SuiteResult) {
+	sr.Counter += r.Counter
+	sr.SuccessCounter += r.SuccessCounter
+	sr.FailedCounter += r.FailedCounter
+	sr.SkippedCounter += r.SkippedCounter
+	sr.NotTestedCounter += r.NotTestedCounter
+	sr.Nodes += r.Nodes
+	sr.Time += r.Time
+	sr.BetaCuts += r.BetaCuts
+	sr.BetaCuts1st += r.BetaCuts1st
+	sr.EbfSum += r.EbfSum
+	sr.EbfCounter += r.EbfCounter
+}
+
+// AvgEbf returns the average effective branching factor of all tests
+// which have reached a search depth.
+func (sr *SuiteResult) AvgEbf() float64 {
+	if sr.EbfCounter == 0 {
+		return 0
+	}
+	return sr.EbfSum / float64(sr.EbfCounter)
 }
 
 // Test defines the data structure for a test after reading in the
@@ -112,6 +173,9 @@ type Test struct {
 	nodes       uint64
 	time        time.Duration
 	nps         uint64
+	betaCuts    uint64
+	betaCuts1st uint64
+	ebf         float64
 }
 
 // TestSuite is the data structure for the running a file of EPD tests.
@@ -121,10 +185,14 @@ type TestSuite struct {
 	Depth      int
 	FilePath   string
 	LastResult *SuiteResult
+	// end of the time budget when run as part of a directory
+	// with Options.MaxTotalTime
+	deadline time.Time
 }
 
 // NewTestSuite creates an instance of a TestSuite and reads in the given file
-// to create test cases which can be run with RunTests().
+// to create test cases which can be run with RunTests(). The file may be
+// gzip compressed (extension .gz).
 func NewTestSuite(filePath string, searchTime time.Duration, depth int) (*TestSuite, error) {
 	out.Println("Preparing Test Suite", filePath)
 
@@ -174,6 +242,10 @@ func (ts *TestSuite) RunTests() {
 	}
 
 	startTime := time.Now()
+	deadline := ts.deadline
+	if deadline.IsZero() && Options.MaxTotalTime > 0 {
+		deadline = startTime.Add(Options.MaxTotalTime)
+	}
 
 	// setup search
 	s := search.NewSearch()
@@ -196,6 +268,10 @@ func (ts *TestSuite) RunTests() {
 	// execute all tests and store results in the
 	// test instance
 	for i, t := range ts.Tests {
+		if !deadline.IsZero() && !time.Now().Before(deadline) {
+			out.Printf("Max total time of %s is up - %d tests not run\n\n", Options.MaxTotalTime, len(ts.Tests)-i)
+			break
+		}
 		out.Printf("Test %d of %d\nTest: %s -- Target Result %s\n", i+1, len(ts.Tests), t.line, t.targetMoves.StringUci())
 		startTime2 := time.Now()
 		runSingleTest(s, sl, t)
@@ -203,8 +279,11 @@ func (ts *TestSuite) RunTests() {
 		t.nodes = s.NodesVisited()
 		t.time = s.LastSearchResult().SearchTime
 		t.nps = util.Nps(s.NodesVisited(), s.LastSearchResult().SearchTime)
-		out.Printf("Test finished in %d ms with result %s (%s) - nps: %d\n\n",
-			elapsedTime.Milliseconds(), t.rType.String(), t.actual.StringUci(), t.nps)
+		t.betaCuts = s.Statistics().BetaCuts
+		t.betaCuts1st = s.Statistics().BetaCuts1st
+		t.ebf = ebf(s.NodesVisited(), s.LastSearchResult().SearchDepth)
+		out.Printf("Test finished in %d ms with result %s (%s) - nps: %d - beta cuts 1st: %.1f %% - ebf: %.2f\n\n",
+			elapsedTime.Milliseconds(), t.rType.String(), t.actual.StringUci(), t.nps, t.betaCuts1stPercent(), t.ebf)
 	}
 
 	// sum up result for report
@@ -223,6 +302,12 @@ func (ts *TestSuite) RunTests() {
 		}
 		tr.Nodes += t.nodes
 		tr.Time += t.time
+		tr.BetaCuts += t.betaCuts
+		tr.BetaCuts1st += t.betaCuts1st
+		if t.ebf > 0 {
+			tr.EbfSum += t.ebf
+			tr.EbfCounter++
+		}
 	}
 	ts.LastResult = tr
 
@@ -257,10 +342,29 @@ func (ts *TestSuite) RunTests() {
 	out.Printf("Failed:     %-3d (%d %%)\n", tr.FailedCounter, 100*tr.FailedCounter/tr.Counter)
 	out.Printf("Skipped:    %-3d (%d %%)\n", tr.SkippedCounter, 100*tr.SkippedCounter/tr.Counter)
 	out.Printf("Not tested: %-3d (%d %%)\n", tr.NotTestedCounter, 100*tr.NotTestedCounter/tr.Counter)
+	out.Printf("Beta cuts:  %d (1st move: %.1f %%)\n", tr.BetaCuts, tr.BetaCuts1stPercent())
+	out.Printf("Avg EBF:    %.2f\n", tr.AvgEbf())
 	out.Printf("Test time: %s\n", elapsed)
 	out.Printf("Configuration: %s\n", config.Settings.String())
 }
 
+// percentage of beta cuts on the first move for a single test
+func (t *Test) betaCuts1stPercent() float64 {
+	if t.betaCuts == 0 {
+		return 0
+	}
+	return 100 * float64(t.betaCuts1st) / float64(t.betaCuts)
+}
+
+// ebf calculates the effective branching factor as the
+// depth-th root of the number of nodes searched.
+func ebf(nodes uint64, depth int) float64 {
+	if nodes == 0 || depth <= 0 {
+		return 0
+	}
+	return math.Pow(float64(nodes), 1/float64(depth))
+}
+
 // determines which test type the test is and call the appropriate
 // test function.
 func runSingleTest(s *search.Search, sl *search.Limits, t *Test) {
@@ -484,8 +588,19 @@ func readFile(filePath string) (*[]string, error) {
 			log.Errorf("File \"%s\" could not be closed: %s\n", filePath, err)
 		}
 	}()
+	var r io.Reader = f
+	// gzip compressed files are decompressed transparently
+	if strings.HasSuffix(strings.ToLower(filePath), ".gz") {
+		gz, err := gzip.NewReader(f)
+		if err != nil {
+			log.Errorf("File \"%s\" could not be decompressed: %s\n", filePath, err)
+			return nil, err
+		}
+		defer gz.Close()
+		r = gz
+	}
 	var lines []string
-	s := bufio.NewScanner(f)
+	s := bufio.NewScanner(r)
 	for s.Scan() {
 		lines = append(lines, s.Text())
 	}
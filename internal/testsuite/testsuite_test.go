@@ -27,6 +27,7 @@
 package testsuite
 
 import (
+	"compress/gzip"
 	"io/ioutil"
 	"os"
 	"path"
@@ -102,6 +103,76 @@ func TestNewTestSuite(t *testing.T) {
 	assert.EqualValues(t, 13, len(ts.Tests))
 }
 
+func TestMaxTotalTime(t *testing.T) {
+	defer func() { Options.MaxTotalTime = 0 }()
+	Options.MaxTotalTime = 500 * time.Millisecond
+	ts, err := NewTestSuite("test/testdata/testsets/franky_tests.epd", 200*time.Millisecond, 0)
+	assert.NoError(t, err)
+	start := time.Now()
+	ts.RunTests()
+	assert.Less(t, time.Since(start).Milliseconds(), int64(2000))
+	r := ts.LastResult
+	assert.EqualValues(t, 13, r.Counter)
+	assert.Greater(t, r.SuccessCounter+r.FailedCounter+r.SkippedCounter, 0)
+	assert.Greater(t, r.NotTestedCounter, 0)
+	assert.EqualValues(t, r.Counter, r.SuccessCounter+r.FailedCounter+r.SkippedCounter+r.NotTestedCounter)
+	// the remaining tests have not been run
+	assert.EqualValues(t, NotTested, ts.Tests[len(ts.Tests)-1].rType)
+	assert.EqualValues(t, 0, ts.Tests[len(ts.Tests)-1].nodes)
+}
+
+func TestNewTestSuiteGzip(t *testing.T) {
+	data, err := ioutil.ReadFile("test/testdata/testsets/franky_tests.epd")
+	assert.NoError(t, err)
+	file, err := ioutil.TempFile("", "franky_tests*.epd.gz")
+	assert.NoError(t, err)
+	defer os.Remove(file.Name())
+	gz := gzip.NewWriter(file)
+	_, err = gz.Write(data)
+	assert.NoError(t, err)
+	assert.NoError(t, gz.Close())
+	assert.NoError(t, file.Close())
+
+	plain, err := NewTestSuite("test/testdata/testsets/franky_tests.epd", 2*time.Second, 0)
+	assert.NoError(t, err)
+	zipped, err := NewTestSuite(file.Name(), 2*time.Second, 0)
+	assert.NoError(t, err)
+	assert.EqualValues(t, len(plain.Tests), len(zipped.Tests))
+	for i, test := range plain.Tests {
+		assert.EqualValues(t, test.id, zipped.Tests[i].id)
+		assert.EqualValues(t, test.fen, zipped.Tests[i].fen)
+		assert.EqualValues(t, test.tType, zipped.Tests[i].tType)
+		assert.EqualValues(t, test.targetMoves.StringUci(), zipped.Tests[i].targetMoves.StringUci())
+	}
+}
+
+func TestMoveOrderingMetrics(t *testing.T) {
+	file, err := ioutil.TempFile("", "metrics*.epd")
+	assert.Nil(t, err)
+	defer os.Remove(file.Name())
+	_, err = file.WriteString("2b4k/8/8/8/8/3N3N/P4p2/1K6 w - - bm Nhxf2 Ndxf2; id \"metrics #1\";\n" +
+		"r3k2r/p1ppqpb1/bn2pnp1/3PN3/1p2P3/2N2Q1p/PPPBBPPP/R3K2R w KQkq - bm Qxf6; id \"metrics #2\";\n")
+	assert.Nil(t, err)
+	assert.Nil(t, file.Close())
+
+	ts, err := NewTestSuite(file.Name(), 0, 5)
+	assert.Nil(t, err)
+	assert.EqualValues(t, 2, len(ts.Tests))
+	ts.RunTests()
+
+	for _, test := range ts.Tests {
+		assert.True(t, test.betaCuts >= test.betaCuts1st)
+		assert.True(t, test.ebf > 1)
+	}
+	tr := ts.LastResult
+	assert.EqualValues(t, ts.Tests[0].betaCuts+ts.Tests[1].betaCuts, tr.BetaCuts)
+	assert.EqualValues(t, ts.Tests[0].betaCuts1st+ts.Tests[1].betaCuts1st, tr.BetaCuts1st)
+	assert.True(t, tr.BetaCuts > 0)
+	assert.True(t, tr.BetaCuts1stPercent() > 0 && tr.BetaCuts1stPercent() <= 100)
+	assert.EqualValues(t, 2, tr.EbfCounter)
+	assert.InDelta(t, (ts.Tests[0].ebf+ts.Tests[1].ebf)/2, tr.AvgEbf(), 0.0001)
+}
+
 // Summary:
 // EPD File:   test/testdata/testsets/franky_tests.epd
 // SearchTime: 3.000 ms
@@ -30,11 +30,19 @@
 // externally if used from multiple threads. Is especially relevant
 // for Resize and Clear which should not be called in parallel
 // while searching.
+// For searches with several threads ProbeConcurrent and PutConcurrent
+// can be called in parallel. The table is sharded into a number of
+// regions each guarded by its own mutex. An entry is therefore always
+// read and written as a whole and a torn entry can not be observed.
+// A probe might still see an entry which is replaced by another thread
+// right afterwards or miss an entry which is just being stored. Both
+// are benign for a search as the tt is only a cache.
 package transpositiontable
 
 import (
 	"math"
 	"sync"
+	"sync/atomic"
 	"time"
 	"unsafe"
 
@@ -67,6 +75,10 @@ const (
 
 	// MaxSizeInMB maximal memory usage of tt
 	MaxSizeInMB = 65_536
+
+	// number of mutexes guarding the regions of the tt for
+	// the concurrent probe and put (power of 2)
+	lockShards = 1024
 )
 
 // TtTable is the actual transposition table
@@ -80,6 +92,7 @@ type TtTable struct {
 	maxNumberOfEntries uint64
 	numberOfEntries    uint64
 	Stats              TtStats
+	locks              [lockShards]sync.Mutex
 }
 
 // TtStats holds statistical data on tt usage
@@ -160,19 +173,58 @@ func (tt *TtTable) GetEntry(key position.Key) *TtEntry {
 // or nil if it was not found. Decreases TtEntry.Age by 1
 func (tt *TtTable) Probe(key position.Key) *TtEntry {
 	tt.Stats.numberOfProbes++
+	if e := tt.probe(key); e != nil {
+		tt.Stats.numberOfHits++
+		return e
+	}
+	tt.Stats.numberOfMisses++
+	return nil
+}
+
+// ProbeConcurrent is the thread safe version of Probe. As the entry
+// might be changed by other threads at any time it returns a copy
+// of the entry and true if it was found. Otherwise false.
+func (tt *TtTable) ProbeConcurrent(key position.Key) (TtEntry, bool) {
+	if tt.maxNumberOfEntries == 0 {
+		return TtEntry{}, false
+	}
+	atomic.AddUint64(&tt.Stats.numberOfProbes, 1)
+	lock := tt.lock(key)
+	lock.Lock()
+	defer lock.Unlock()
+	if e := tt.probe(key); e != nil {
+		atomic.AddUint64(&tt.Stats.numberOfHits, 1)
+		return *e, true
+	}
+	atomic.AddUint64(&tt.Stats.numberOfMisses, 1)
+	return TtEntry{}, false
+}
+
+// probe returns a pointer to the corresponding tt entry or nil
+// if it was not found. Decreases TtEntry.Age by 1.
+// Does not change statistics.
+func (tt *TtTable) probe(key position.Key) *TtEntry {
 	e := &tt.data[tt.hash(key)]
 	if e.Key == key {
 		e.Age--
 		if e.Age < 0 {
 			e.Age = 0
 		}
-		tt.Stats.numberOfHits++
 		return e
 	}
-	tt.Stats.numberOfMisses++
 	return nil
 }
 
+// result of a put to count the statistics
+type putResult int
+
+const (
+	putNew putResult = iota
+	putCollision
+	putOverwrite
+	putUpdate
+)
+
 // Put an TtEntry into the tt. Encodes value into the move.
 func (tt *TtTable) Put(key position.Key, move Move, depth int8, value Value, valueType ValueType, mateThreat bool) {
 
@@ -182,6 +234,47 @@ func (tt *TtTable) Put(key position.Key, move Move, depth int8, value Value, val
 		return
 	}
 
+	tt.Stats.numberOfPuts++
+	switch tt.put(key, move, depth, value, valueType, mateThreat) {
+	case putNew:
+		tt.numberOfEntries++
+	case putCollision:
+		tt.Stats.numberOfCollisions++
+	case putOverwrite:
+		tt.Stats.numberOfCollisions++
+		tt.Stats.numberOfOverwrites++
+	case putUpdate:
+		tt.Stats.numberOfUpdates++
+	}
+}
+
+// PutConcurrent is the thread safe version of Put.
+func (tt *TtTable) PutConcurrent(key position.Key, move Move, depth int8, value Value, valueType ValueType, mateThreat bool) {
+	if tt.maxNumberOfEntries == 0 {
+		return
+	}
+	atomic.AddUint64(&tt.Stats.numberOfPuts, 1)
+	lock := tt.lock(key)
+	lock.Lock()
+	result := tt.put(key, move, depth, value, valueType, mateThreat)
+	lock.Unlock()
+	switch result {
+	case putNew:
+		atomic.AddUint64(&tt.numberOfEntries, 1)
+	case putCollision:
+		atomic.AddUint64(&tt.Stats.numberOfCollisions, 1)
+	case putOverwrite:
+		atomic.AddUint64(&tt.Stats.numberOfCollisions, 1)
+		atomic.AddUint64(&tt.Stats.numberOfOverwrites, 1)
+	case putUpdate:
+		atomic.AddUint64(&tt.Stats.numberOfUpdates, 1)
+	}
+}
+
+// put stores the entry and returns what has been done for
+// the statistics. Does not change statistics.
+func (tt *TtTable) put(key position.Key, move Move, depth int8, value Value, valueType ValueType, mateThreat bool) putResult {
+
 	// read the entries for this hash
 	entryDataPtr := &tt.data[tt.hash(key)]
 	// encode value into the move if it is a valid value (min < v < max)
@@ -191,53 +284,45 @@ func (tt *TtTable) Put(key position.Key, move Move, depth int8, value Value, val
 		tt.log.Warningf("TT Put: Tried to store an invalid Value into the TT %s (%d)", value.String(), int(value))
 	}
 
-	tt.Stats.numberOfPuts++
-
 	// NewTtTable entry
 	if entryDataPtr.Key == 0 {
-		tt.numberOfEntries++
 		entryDataPtr.Key = key
 		entryDataPtr.Move = move
 		entryDataPtr.Depth = depth
 		entryDataPtr.Age = 1
 		entryDataPtr.Type = valueType
 		entryDataPtr.MateThreat = mateThreat
-		return
+		return putNew
 	}
 
 	// Same hash but different position
 	if entryDataPtr.Key != key {
-		tt.Stats.numberOfCollisions++
 		// overwrite if
 		// - the new entry's depth is higher
 		// - the new entry's depth is same and the previous entry is old (is aged)
 		if depth > entryDataPtr.Depth ||
 			(depth == entryDataPtr.Depth && entryDataPtr.Age > 1) {
-			tt.Stats.numberOfOverwrites++
 			entryDataPtr.Key = key
 			entryDataPtr.Move = move
 			entryDataPtr.Depth = depth
 			entryDataPtr.Age = 1
 			entryDataPtr.Type = valueType
 			entryDataPtr.MateThreat = mateThreat
+			return putOverwrite
 		}
-		return
+		return putCollision
 	}
 
-	// Same hash and same position -> update entry?
-	if entryDataPtr.Key == key {
-		tt.Stats.numberOfUpdates++
-		// we always update as the stored moved can't be any good otherwise
-		// we would have found this during the search in a previous probe
-		// and we would not have come to store it again
-		entryDataPtr.Key = key
-		entryDataPtr.Move = move
-		entryDataPtr.Depth = depth
-		entryDataPtr.Age = 1
-		entryDataPtr.Type = valueType
-		entryDataPtr.MateThreat = mateThreat
-		return
-	}
+	// Same hash and same position -> update entry
+	// we always update as the stored moved can't be any good otherwise
+	// we would have found this during the search in a previous probe
+	// and we would not have come to store it again
+	entryDataPtr.Move = move
+	entryDataPtr.Depth = depth
+	entryDataPtr.Age = 1
+	entryDataPtr.Type = valueType
+	entryDataPtr.MateThreat = mateThreat
+	return putUpdate
 }
 
 // Clear clears all entries of the tt
@@ -252,6 +337,15 @@ func (tt *TtTable) Clear() {
 	tt.Stats = TtStats{}
 }
 
+// WarmUp touches all entries of the tt so the operating system has
+// to provide the memory before the tt is used by a search. Otherwise
+// the first search pays for the page faults which distorts timings.
+func (tt *TtTable) WarmUp() {
+	for i := range tt.data {
+		tt.data[i] = TtEntry{}
+	}
+}
+
 // Hashfull returns how full the transposition table is in permill as per UCI
 func (tt *TtTable) Hashfull() int {
 	if tt.maxNumberOfEntries == 0 {
@@ -315,3 +409,9 @@ func (tt *TtTable) AgeEntries() {
 func (tt *TtTable) hash(key position.Key) uint64 {
 	return uint64(key) & tt.hashKeyMask
 }
+
+// lock returns the mutex guarding the region of the data
+// array the given key is stored in
+func (tt *TtTable) lock(key position.Key) *sync.Mutex {
+	return &tt.locks[tt.hash(key)&(lockShards-1)]
+}
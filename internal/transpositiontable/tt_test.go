@@ -27,10 +27,14 @@
 package transpositiontable
 
 import (
+	"bytes"
+	"encoding/binary"
+	"io/ioutil"
 	"math/rand"
 	"os"
 	"path"
 	"runtime"
+	"sync"
 	"testing"
 	"time"
 	"unsafe"
@@ -184,6 +188,12 @@ func TestClear(t *testing.T) {
 	e = tt.Probe(pos.ZobristKey())
 	assert.Nil(t, e)
 	assert.EqualValues(t, 0, tt.numberOfEntries)
+
+	// warm up keeps the tt empty
+	tt.WarmUp()
+	assert.Nil(t, tt.Probe(pos.ZobristKey()))
+	assert.EqualValues(t, 0, tt.Len())
+	assert.EqualValues(t, tt.maxNumberOfEntries, len(tt.data))
 }
 
 func TestAge(t *testing.T) {
@@ -289,6 +299,141 @@ func TestPut(t *testing.T) {
 	assert.EqualValues(t, false, e.MateThreat)
 }
 
+// run with "go test -race" to detect data races
+func TestConcurrentProbePut(t *testing.T) {
+	tt := NewTtTable(1)
+	move := CreateMove(SqE2, SqE4, Normal, PtNone)
+
+	// all data of an entry is derived from its key so that
+	// a torn entry (mixed from two puts) can be detected
+	depthOf := func(key position.Key) int8 { return int8(key % 64) }
+	valueOf := func(key position.Key) Value { return Value(key % 1_000) }
+	mateThreatOf := func(key position.Key) bool { return key%2 == 0 }
+
+	const goroutines = 8
+	const iterations = 50_000
+	var wg sync.WaitGroup
+	wg.Add(goroutines)
+	for g := 0; g < goroutines; g++ {
+		go func(seed int64) {
+			defer wg.Done()
+			r := rand.New(rand.NewSource(seed))
+			for i := 0; i < iterations; i++ {
+				// more keys than entries to provoke collisions
+				key := position.Key(r.Uint64()%(4*tt.maxNumberOfEntries) + 1)
+				if i%2 == 0 {
+					tt.PutConcurrent(key, move, depthOf(key), valueOf(key), EXACT, mateThreatOf(key))
+					continue
+				}
+				if e, found := tt.ProbeConcurrent(key); found {
+					assert.EqualValues(t, key, e.Key)
+					assert.EqualValues(t, move, e.Move.MoveOf())
+					assert.EqualValues(t, valueOf(key), e.Move.ValueOf())
+					assert.EqualValues(t, depthOf(key), e.Depth)
+					assert.EqualValues(t, mateThreatOf(key), e.MateThreat)
+				}
+			}
+		}(int64(g))
+	}
+	wg.Wait()
+
+	assert.EqualValues(t, goroutines*iterations/2, tt.Stats.numberOfPuts)
+	assert.EqualValues(t, goroutines*iterations/2, tt.Stats.numberOfProbes)
+	assert.EqualValues(t, tt.Stats.numberOfProbes, tt.Stats.numberOfHits+tt.Stats.numberOfMisses)
+	assert.Greater(t, tt.Len(), uint64(0))
+	assert.LessOrEqual(t, tt.Len(), tt.maxNumberOfEntries)
+
+	// an empty tt does not store anything
+	tt = NewTtTable(0)
+	tt.PutConcurrent(111, move, 4, Value(111), ALPHA, false)
+	_, found := tt.ProbeConcurrent(111)
+	assert.False(t, found)
+}
+
+func TestSaveAndLoad(t *testing.T) {
+	dir, err := ioutil.TempDir("", "tt")
+	assert.NoError(t, err)
+	defer os.RemoveAll(dir)
+	file := path.Join(dir, "tt.bin")
+
+	// fill the tt with random entries
+	tt := NewTtTable(2)
+	rnd := rand.New(rand.NewSource(4711))
+	keys := make([]position.Key, 0, 10_000)
+	for i := 0; i < 10_000; i++ {
+		key := position.Key(rnd.Uint64())
+		keys = append(keys, key)
+		move := CreateMove(Square(rnd.Intn(64)), Square(rnd.Intn(64)), Normal, PtNone)
+		tt.Put(key, move, int8(rnd.Intn(20)), Value(rnd.Intn(1000)), ValueType(1+rnd.Intn(3)), rnd.Intn(2) == 0)
+	}
+	stored := make(map[position.Key]TtEntry, len(keys))
+	for _, key := range keys {
+		if e := tt.GetEntry(key); e != nil {
+			stored[key] = *e
+		}
+	}
+	numberOfEntries := tt.Len()
+	assert.NoError(t, tt.Save(file))
+
+	// load into a cleared tt of a different size
+	tt2 := NewTtTable(1)
+	assert.NoError(t, tt2.Load(file))
+	assert.Equal(t, tt.maxNumberOfEntries, tt2.maxNumberOfEntries)
+	assert.Equal(t, tt.hashKeyMask, tt2.hashKeyMask)
+	assert.Equal(t, numberOfEntries, tt2.Len())
+	for key, entry := range stored {
+		e := tt2.GetEntry(key)
+		if assert.NotNil(t, e) {
+			assert.Equal(t, entry, *e)
+		}
+	}
+	tt.Clear()
+	assert.EqualValues(t, 0, tt.Len())
+	assert.NoError(t, tt.Load(file))
+	assert.Equal(t, numberOfEntries, tt.Len())
+
+	// a file created with a different zobrist seed is rejected
+	position.SetZobristSeed(12345)
+	err = tt2.Load(file)
+	position.SetZobristSeed(0)
+	assert.Error(t, err)
+	assert.Contains(t, err.Error(), "zobrist")
+	assert.Equal(t, numberOfEntries, tt2.Len())
+
+	// the entries are stored in little endian byte order
+	raw, err := ioutil.ReadFile(file)
+	assert.NoError(t, err)
+	headerSize := binary.Size(ttFileHeader{})
+	assert.Equal(t, headerSize+int(tt.maxNumberOfEntries)*ttFileEntrySize, len(raw))
+	for i := range tt.data {
+		if tt.data[i].Key != 0 {
+			offset := headerSize + i*ttFileEntrySize
+			assert.EqualValues(t, tt.data[i].Key, binary.LittleEndian.Uint64(raw[offset:]))
+			assert.EqualValues(t, tt.data[i].Move, binary.LittleEndian.Uint32(raw[offset+8:]))
+			break
+		}
+	}
+
+	// a header without entries or with a size not matching the file is rejected
+	header := ttFileHeader{
+		Magic:         ttFileMagic,
+		Version:       ttFileVersion,
+		ZobristMarker: zobristMarker(),
+		EntrySize:     ttFileEntrySize,
+	}
+	var headerOnly bytes.Buffer
+	assert.NoError(t, binary.Write(&headerOnly, binary.LittleEndian, &header))
+	assert.NoError(t, ioutil.WriteFile(file, headerOnly.Bytes(), 0644))
+	assert.Error(t, tt2.Load(file))
+	assert.NoError(t, ioutil.WriteFile(file, raw[:len(raw)-ttFileEntrySize], 0644))
+	assert.Error(t, tt2.Load(file))
+	assert.Equal(t, numberOfEntries, tt2.Len())
+
+	// no tt file
+	assert.Error(t, tt2.Load("test/config.toml"))
+	assert.Error(t, tt2.Load(path.Join(dir, "missing.bin")))
+}
+
 func TestTimingTTe(t *testing.T) {
 
 	if testing.Short() {
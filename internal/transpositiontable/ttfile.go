@@ -0,0 +1,193 @@
+//
+// FrankyGo - UCI chess engine in GO for learning purposes
+//
+// MIT License
+//
+// Copyright (c) 2018-2020 Frank Kopp
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in all
+// copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE
+// SOFTWARE.
+//
+
+package transpositiontable
+
+import (
+	"bufio"
+	"encoding/binary"
+	"fmt"
+	"io"
+	"os"
+
+	"github.com/frankkopp/FrankyGo/internal/position"
+	. "github.com/frankkopp/FrankyGo/internal/types"
+)
+
+// ttFileMagic and ttFileVersion identify a file written by Save.
+// The version must be increased when the zobrist keys change in a
+// way the zobrist marker does not detect.
+// Version 2: keys of positions without castling rights or with an
+// en passant square set up from a fen changed.
+const (
+	ttFileMagic   uint32 = 0x46475454 // "FGTT"
+	ttFileVersion uint32 = 2
+)
+
+// ttFileEntrySize is the size of an entry in the file. Entries are
+// written field by field in little endian byte order so files can be
+// exchanged between machines: key (8 bytes), move (4), depth, age,
+// type and mate threat (1 each).
+const ttFileEntrySize = 16
+
+// ttFileHeader is written in front of the entries of a saved tt.
+// The zobrist marker is the key of the start position which changes
+// with the zobrist seed. Entries of a file with a different marker
+// would never match any position.
+type ttFileHeader struct {
+	Magic           uint32
+	Version         uint32
+	ZobristMarker   uint64
+	EntrySize       uint64
+	MaxEntries      uint64
+	NumberOfEntries uint64
+}
+
+// Save writes all entries of the tt into the given file so that a long
+// analysis can be resumed later with Load.
+// The TtTable class is not thread safe and must not be saved while
+// searching.
+func (tt *TtTable) Save(path string) error {
+	f, err := os.Create(path)
+	if err != nil {
+		return err
+	}
+	w := bufio.NewWriter(f)
+	header := ttFileHeader{
+		Magic:           ttFileMagic,
+		Version:         ttFileVersion,
+		ZobristMarker:   zobristMarker(),
+		EntrySize:       ttFileEntrySize,
+		MaxEntries:      tt.maxNumberOfEntries,
+		NumberOfEntries: tt.numberOfEntries,
+	}
+	if err = binary.Write(w, binary.LittleEndian, &header); err != nil {
+		_ = f.Close()
+		return err
+	}
+	var buf [ttFileEntrySize]byte
+	for i := range tt.data {
+		encodeEntry(&tt.data[i], buf[:])
+		if _, err = w.Write(buf[:]); err != nil {
+			_ = f.Close()
+			return err
+		}
+	}
+	if err = w.Flush(); err != nil {
+		_ = f.Close()
+		return err
+	}
+	tt.log.Debug(out.Sprintf("TT saved with %d entries to %s", tt.numberOfEntries, path))
+	return f.Close()
+}
+
+// Load replaces the tt with the entries from a file written by Save. The
+// tt is resized to the size of the saved tt. Files from a different
+// version or created with a different zobrist seed are rejected and the
+// tt is left unchanged.
+// The TtTable class is not thread safe and must not be loaded while
+// searching.
+func (tt *TtTable) Load(path string) error {
+	f, err := os.Open(path)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+	info, err := f.Stat()
+	if err != nil {
+		return err
+	}
+	r := bufio.NewReader(f)
+
+	var header ttFileHeader
+	if err = binary.Read(r, binary.LittleEndian, &header); err != nil {
+		return err
+	}
+	switch {
+	case header.Magic != ttFileMagic:
+		return fmt.Errorf("not a transposition table file: %s", path)
+	case header.Version != ttFileVersion:
+		return fmt.Errorf("transposition table file version %d not supported: %s", header.Version, path)
+	case header.EntrySize != ttFileEntrySize:
+		return fmt.Errorf("transposition table file entry size %d not supported: %s", header.EntrySize, path)
+	case header.ZobristMarker != zobristMarker():
+		return fmt.Errorf("transposition table file was created with a different zobrist seed: %s", path)
+	case header.MaxEntries == 0 ||
+		header.MaxEntries&(header.MaxEntries-1) != 0 ||
+		header.MaxEntries*TtEntrySize > MaxSizeInMB*MB ||
+		header.NumberOfEntries > header.MaxEntries ||
+		uint64(info.Size()) != uint64(binary.Size(header))+header.MaxEntries*ttFileEntrySize:
+		return fmt.Errorf("transposition table file size %d invalid: %s", header.MaxEntries, path)
+	}
+
+	data := make([]TtEntry, header.MaxEntries)
+	var buf [ttFileEntrySize]byte
+	for i := range data {
+		if _, err = io.ReadFull(r, buf[:]); err != nil {
+			return err
+		}
+		decodeEntry(buf[:], &data[i])
+	}
+
+	tt.data = data
+	tt.maxNumberOfEntries = header.MaxEntries
+	tt.hashKeyMask = tt.maxNumberOfEntries - 1
+	tt.sizeInByte = tt.maxNumberOfEntries * TtEntrySize
+	tt.numberOfEntries = header.NumberOfEntries
+	tt.Stats = TtStats{}
+	tt.log.Debug(out.Sprintf("TT loaded with %d entries from %s", tt.numberOfEntries, path))
+	return nil
+}
+
+// zobristMarker returns the key of the start position to identify the
+// zobrist seed used to create the keys of the entries.
+func zobristMarker() uint64 {
+	return uint64(position.NewPosition().ZobristKey())
+}
+
+// encodeEntry writes the entry into the given buffer of ttFileEntrySize
+// bytes in little endian byte order.
+func encodeEntry(e *TtEntry, buf []byte) {
+	binary.LittleEndian.PutUint64(buf[0:], uint64(e.Key))
+	binary.LittleEndian.PutUint32(buf[8:], uint32(e.Move))
+	buf[12] = byte(e.Depth)
+	buf[13] = byte(e.Age)
+	buf[14] = byte(e.Type)
+	buf[15] = 0
+	if e.MateThreat {
+		buf[15] = 1
+	}
+}
+
+// decodeEntry reads an entry written by encodeEntry from the buffer.
+func decodeEntry(buf []byte, e *TtEntry) {
+	e.Key = position.Key(binary.LittleEndian.Uint64(buf[0:]))
+	e.Move = Move(binary.LittleEndian.Uint32(buf[8:]))
+	e.Depth = int8(buf[12])
+	e.Age = int8(buf[13])
+	e.Type = ValueType(buf[14])
+	e.MateThreat = buf[15] != 0
+}
@@ -35,6 +35,8 @@ import (
 
 // Move is a 32bit unsigned int type for encoding chess moves as a primitive data type
 // 16 bits for move encoding - 16 bits for sort value
+// Comparing moves with == compares the full packed value including the
+// sort value. Use Equals to compare moves without their sort values.
 type Move uint32
 
 const (
@@ -111,6 +113,12 @@ func (m Move) MoveOf() Move {
 	return m & moveMask
 }
 
+// Equals returns true if both moves have the same from and to square,
+// move type and promotion type. The sort values are ignored.
+func (m Move) Equals(other Move) bool {
+	return m.MoveOf() == other.MoveOf()
+}
+
 // ValueOf returns the sort value for the move used in the move generator
 func (m Move) ValueOf() Value {
 	return Value((m&valueMask)>>valueShift) + ValueNA
@@ -98,8 +98,24 @@ func TestMove_SetValue(t *testing.T) {
 	assert.Equal(t, ValueMax, m.ValueOf())
 }
 
+func TestMove_Equals(t *testing.T) {
+	m1 := CreateMoveValue(SqE2, SqE4, Normal, PtNone, 100)
+	m2 := CreateMoveValue(SqE2, SqE4, Normal, PtNone, -200)
+	assert.NotEqual(t, m1, m2)
+	assert.True(t, m1.Equals(m2))
+	assert.True(t, m2.Equals(CreateMove(SqE2, SqE4, Normal, PtNone)))
+	assert.False(t, m1.Equals(CreateMoveValue(SqE2, SqE3, Normal, PtNone, 100)))
+	assert.False(t, m1.Equals(MoveNone))
+
+	// promotion type is part of the move
+	q := CreateMoveValue(SqA7, SqA8, Promotion, Queen, 100)
+	n := CreateMoveValue(SqA7, SqA8, Promotion, Knight, 100)
+	assert.False(t, q.Equals(n))
+}
+
 func Test_Str(t *testing.T) {
 	assert.Equal(t, "e2e4", CreateMove(SqE2, SqE4, Normal, PtNone).StringUci())
 	assert.Equal(t, "e7e5", CreateMove(SqE7, SqE5, Normal, PtNone).StringUci())
 	assert.Equal(t, "a2a1Q", CreateMove(SqA2, SqA1, Promotion, Queen).StringUci())
+	assert.Equal(t, "e1g1", CreateMove(SqE1, SqG1, Castling, PtNone).StringUci())
 }
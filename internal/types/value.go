@@ -33,7 +33,10 @@ import (
 	"github.com/frankkopp/FrankyGo/internal/util"
 )
 
-// Value represents the positional value of a chess position
+// Value represents the positional value of a chess position.
+// Values are given in centipawns from the view of the side to move
+// with a pawn being worth 100 (see PieceType.ValueOf()). Use
+// ToCentipawns() to report values to the outside world.
 type Value int16
 
 // Constants for values
@@ -54,12 +57,33 @@ func (v Value) IsValid() bool {
 	return v >= ValueMin && v <= ValueMax
 }
 
+// ToCentipawns returns the value in centipawns as expected by the UCI
+// protocol. This is the single conversion point in case the internal
+// value of a pawn differs from 100.
+func (v Value) ToCentipawns() int {
+	return int(v) * 100 / int(Pawn.ValueOf())
+}
+
 // IsCheckMateValue returns true if value is above the check mate threshold
 // which typically is set to check mate value minus the maximum search depth
 func (v Value) IsCheckMateValue() bool {
 	return util.Abs(int(v)) > int(ValueCheckMateThreshold) && util.Abs(int(v)) <= int(ValueCheckMate)
 }
 
+// MateIn returns the number of moves to mate for check mate values
+// which is negative when the side to move is getting mated.
+// For all other values 0 is returned.
+func (v Value) MateIn() int {
+	if !v.IsCheckMateValue() {
+		return 0
+	}
+	n := (int(ValueCheckMate) - util.Abs(int(v)) + 1) / 2
+	if v < ValueZero {
+		return -n
+	}
+	return n
+}
+
 // Min returns the smaller of the given values
 func Min(x, y Value) Value {
 	if x < y {
@@ -90,7 +114,7 @@ func (v Value) String() string {
 		os.WriteString("N/A")
 	} else {
 		os.WriteString("cp ")
-		os.WriteString(strconv.Itoa(int(v)))
+		os.WriteString(strconv.Itoa(v.ToCentipawns()))
 	}
 	return os.String()
 }
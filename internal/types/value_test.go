@@ -38,3 +38,19 @@ func TestString(t *testing.T) {
 	fmt.Println(s.String())
 	assert.EqualValues(t, "mate 2", s.String())
 }
+
+func TestToCentipawns(t *testing.T) {
+	assert.EqualValues(t, 100, Pawn.ValueOf().ToCentipawns())
+	assert.EqualValues(t, -250, Value(-250).ToCentipawns())
+	assert.EqualValues(t, 0, ValueDraw.ToCentipawns())
+	assert.EqualValues(t, "cp 100", Pawn.ValueOf().String())
+	assert.EqualValues(t, "cp -320", (-Knight.ValueOf()).String())
+}
+
+func TestMateIn(t *testing.T) {
+	assert.EqualValues(t, 1, (ValueCheckMate - 1).MateIn())
+	assert.EqualValues(t, 2, (ValueCheckMate - 3).MateIn())
+	assert.EqualValues(t, -1, (-ValueCheckMate + 2).MateIn())
+	assert.EqualValues(t, 0, Value(250).MateIn())
+	assert.EqualValues(t, 0, ValueDraw.MateIn())
+}
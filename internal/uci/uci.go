@@ -46,6 +46,7 @@ import (
 	"golang.org/x/text/message"
 
 	"github.com/frankkopp/FrankyGo/internal/config"
+	"github.com/frankkopp/FrankyGo/internal/evaluator"
 	myLogging "github.com/frankkopp/FrankyGo/internal/logging"
 	"github.com/frankkopp/FrankyGo/internal/movegen"
 	"github.com/frankkopp/FrankyGo/internal/moveslice"
@@ -133,8 +134,8 @@ func (u *UciHandler) SendInfoString(info string) {
 
 // SendIterationEndInfo sends information about the last search depth iteration to the UCI ui
 func (u *UciHandler) SendIterationEndInfo(depth int, seldepth int, value Value, nodes uint64, nps uint64, time time.Duration, pv moveslice.MoveSlice) {
-	u.send(fmt.Sprintf("info depth %d seldepth %d multipv 1 score %s nodes %d nps %d time %d pv %s",
-		depth, seldepth, value.String(), nodes, nps, time.Milliseconds(), pv.StringUci()))
+	u.send(fmt.Sprintf("info depth %d seldepth %d multipv 1 score %s%s nodes %d nps %d time %d pv %s",
+		depth, seldepth, value.String(), u.wdl(value), nodes, nps, time.Milliseconds(), pv.StringUci()))
 }
 
 // SendSearchUpdate sends a periodically update about search stats to the UCI ui
@@ -145,8 +146,18 @@ func (u *UciHandler) SendSearchUpdate(depth int, seldepth int, nodes uint64, nps
 
 // SendAspirationResearchInfo sends information about Aspiration researches to the UCI ui
 func (u *UciHandler) SendAspirationResearchInfo(depth int, seldepth int, value Value, bound string, nodes uint64, nps uint64, time time.Duration, pv moveslice.MoveSlice) {
-	u.send(fmt.Sprintf("info depth %d seldepth %d %s multipv 1 score %s nodes %d nps %d time %d pv %s",
-		depth, seldepth, value.String(), bound, nodes, nps, time.Milliseconds(), pv.StringUci()))
+	u.send(fmt.Sprintf("info depth %d seldepth %d multipv 1 score %s %s%s nodes %d nps %d time %d pv %s",
+		depth, seldepth, value.String(), bound, u.wdl(value), nodes, nps, time.Milliseconds(), pv.StringUci()))
+}
+
+// wdl returns the win/draw/loss probabilities for the given value for
+// the uci info string when ShowWDL is set and an empty string otherwise.
+func (u *UciHandler) wdl(value Value) string {
+	if !config.Settings.Search.ShowWDL || value == ValueNA {
+		return ""
+	}
+	win, draw, loss := search.WinDrawLoss(value, u.myPosition.GamePhaseFactor())
+	return fmt.Sprintf(" wdl %d %d %d", win, draw, loss)
 }
 
 // SendCurrentRootMove sends the currently searched root move to the UCI ui
@@ -159,10 +170,28 @@ func (u *UciHandler) SendCurrentLine(moveList moveslice.MoveSlice) {
 	u.send(fmt.Sprintf("info currline %s", moveList.StringUci()))
 }
 
+// SendRefutation sends the line refuting the given root move to the UCI ui.
+// As the UCI refutation info has no score the value of the root move is
+// sent as an additional info string together with its bound (e.g.
+// upperbound) if the value is not exact.
+func (u *UciHandler) SendRefutation(move Move, value Value, bound string, refutation moveslice.MoveSlice) {
+	u.send(fmt.Sprintf("info refutation %s %s", move.StringUci(), refutation.StringUci()))
+	if bound != "" {
+		bound = " " + bound
+	}
+	u.send(fmt.Sprintf("info string refutation %s score %s%s", move.StringUci(), value.String(), bound))
+}
+
 // SendResult send the search result to the UCI ui after the search has ended are has been stopped
 func (u *UciHandler) SendResult(bestMove Move, ponderMove Move) {
 	var resultStr strings.Builder
 	resultStr.WriteString("bestmove ")
+	// no legal move in the position (checkmate or stalemate)
+	if bestMove == MoveNone {
+		resultStr.WriteString("(none)")
+		u.send(resultStr.String())
+		return
+	}
 	resultStr.WriteString(bestMove.StringUci())
 	if ponderMove != MoveNone {
 		resultStr.WriteString(" ponder ")
@@ -171,28 +200,31 @@ func (u *UciHandler) SendResult(bestMove Move, ponderMove Move) {
 	u.send(resultStr.String())
 }
 
+
 // ///////////////////////////////////////////////////////////
 // Private
 // ///////////////////////////////////////////////////////////
 
 func (u *UciHandler) loop() {
-	// infinite loop until "quit" command is received
-	for {
-		log.Debugf("Waiting for command:")
-		// read from stdin or other in stream
-		for u.InIo.Scan() {
-			if u.handleReceivedCommand(u.InIo.Text()) {
-				// quit command received
-				return
-			}
-			log.Debugf("Waiting for command:")
+	// loop until "quit" command is received or the in stream is closed
+	log.Debugf("Waiting for command:")
+	// read from stdin or other in stream
+	for u.InIo.Scan() {
+		if u.handleReceivedCommand(u.InIo.Text()) {
+			// quit command received
+			return
 		}
+		log.Debugf("Waiting for command:")
 	}
+	// in stream closed - treat like quit
+	log.Debugf("Input stream closed: %v", u.InIo.Err())
+	u.stopCommand()
 }
 
 var regexWhiteSpace = regexp.MustCompile("\\s+")
 
 func (u *UciHandler) handleReceivedCommand(cmd string) bool {
+	cmd = strings.TrimSpace(cmd)
 	if len(cmd) == 0 {
 		return false
 	}
@@ -200,9 +232,10 @@ func (u *UciHandler) handleReceivedCommand(cmd string) bool {
 	u.uciLog.Infof("<< %s", cmd)
 	// find command and execute by calling command function
 	tokens := regexWhiteSpace.Split(cmd, -1)
-	strings.TrimSpace(tokens[0])
 	switch tokens[0] {
 	case "quit":
+		// stop any running search or perft before exiting
+		u.stopCommand()
 		return true
 	case "uci":
 		u.uciCommand()
@@ -226,9 +259,15 @@ func (u *UciHandler) handleReceivedCommand(cmd string) bool {
 		u.debugCommand()
 	case "perft":
 		u.perftCommand(tokens)
+	case "mirror":
+		u.mirrorCommand()
+	case "d":
+		u.displayCommand()
 	case "noop":
 	default:
-		log.Warningf("Error: Unknown command: %s", cmd)
+		msg := out.Sprintf("Unknown command: %s", cmd)
+		u.SendInfoString(msg)
+		log.Warning(msg)
 	}
 	log.Debugf("Processed command: %s", cmd)
 	return false
@@ -320,6 +359,33 @@ func (u *UciHandler) perftCommand(tokens []string) {
 	go u.myPerft.StartPerftMulti(position.StartFen, depth, depth2, true)
 }
 
+// debug command (not part of UCI) which replaces the current position
+// with its vertical mirror and sends both fens and both static evals
+// from White's view. With a symmetric evaluation the evals are negatives
+// of each other.
+func (u *UciHandler) mirrorCommand() {
+	e := evaluator.NewEvaluator()
+	mirror := u.myPosition.MirrorVertical()
+	value := e.Evaluate(u.myPosition) * Value(u.myPosition.NextPlayer().Direction())
+	mirrorValue := e.Evaluate(mirror) * Value(mirror.NextPlayer().Direction())
+	u.SendInfoString(out.Sprintf("position %s eval %s", u.myPosition.StringFen(), value.String()))
+	u.SendInfoString(out.Sprintf("mirror   %s eval %s", mirror.StringFen(), mirrorValue.String()))
+	u.myPosition = mirror
+}
+
+// debug command (not part of UCI) which displays the current position
+// with its zobrist key, the static eval from the view of the next player
+// and the legal moves.
+func (u *UciHandler) displayCommand() {
+	var display strings.Builder
+	display.WriteString(u.myPosition.String())
+	display.WriteString(fmt.Sprintf("Zobrist Key    : %016x\n", uint64(u.myPosition.ZobristKey())))
+	display.WriteString(fmt.Sprintf("Static Eval    : %s\n", evaluator.NewEvaluator().Evaluate(u.myPosition).String()))
+	legalMoves := movegen.NewMoveGen().GenerateLegalMoves(u.myPosition, movegen.GenAll)
+	display.WriteString(fmt.Sprintf("Legal Moves    : (%d) %s", legalMoves.Len(), legalMoves.StringUci()))
+	u.send(display.String())
+}
+
 // starts a search after reading in the search limits provided
 func (u *UciHandler) goCommand(tokens []string) {
 	searchLimits, err := u.readSearchLimits(tokens)
@@ -335,6 +401,12 @@ func (u *UciHandler) positionCommand(tokens []string) {
 	// build initial position
 	fen := position.StartFen
 	i := 1
+	if len(tokens) < 2 {
+		msg := out.Sprintf("Command 'position' malformed. %s", tokens)
+		u.SendInfoString(msg)
+		log.Warning(msg)
+		return
+	}
 	switch tokens[i] {
 	case "startpos":
 		i++
@@ -358,7 +430,14 @@ func (u *UciHandler) positionCommand(tokens []string) {
 		log.Warning(msg)
 		return
 	}
-	u.myPosition, _ = position.NewPositionFen(fen)
+	newPosition, err := position.NewPositionFen(fen)
+	if err != nil {
+		msg := out.Sprintf("Command 'position' malformed. %s", err)
+		u.SendInfoString(msg)
+		log.Warning(msg)
+		return
+	}
+	u.myPosition = newPosition
 
 	// check for moves to make
 	if i < len(tokens) {
@@ -401,143 +480,133 @@ func (u *UciHandler) debugCommand() {
 	log.Warning(msg)
 }
 
-// will not be implemented
+// FrankyGo does not need any registration so the register
+// command is ignored as allowed by the UCI protocol
 func (u *UciHandler) registerCommand() {
-	msg := "Command 'register' not implemented"
-	u.SendInfoString(msg)
-	log.Warning(msg)
+	log.Debug("Command 'register' ignored - no registration required")
 }
 
+// readSearchLimits parses the go command tokens for the current position
+// and reports malformed commands to the UCI user interface.
 func (u *UciHandler) readSearchLimits(tokens []string) (*search.Limits, bool) {
+	searchLimits, err := ParseGo(u.myPosition, tokens)
+	if err != nil {
+		msg := out.Sprintf("UCI command go malformed. %s", err)
+		u.SendInfoString(msg)
+		log.Warning(msg)
+		return nil, true
+	}
+	return searchLimits, false
+}
+
+// ParseGo reads the tokens of an UCI "go" command and returns the
+// corresponding search limits. The leading "go" token is optional.
+// Moves given after "searchmoves" (or "moves") are read as long as
+// they are legal moves on the given position.
+// Returns an error with a description of the problem if the command
+// is malformed or does not result in any effective search limit.
+func ParseGo(p *position.Position, tokens []string) (*search.Limits, error) {
 	searchLimits := search.NewSearchLimits()
-	i := 1
+	// times of 0 or less are valid (time overstepped) but must be sent
+	whiteTimeSent, blackTimeSent := false, false
+	i := 0
+	if len(tokens) > 0 && tokens[0] == "go" {
+		i++
+	}
 	for i < len(tokens) {
-		var err error = nil
-		switch tokens[i] {
-		case "moves":
-			i++
+		token := tokens[i]
+		i++
+		switch token {
+		case "":
+			// skip empty tokens from additional white space
+		case "searchmoves", "moves":
+			mg := movegen.NewMoveGen()
 			for i < len(tokens) {
-				move := u.myMoveGen.GetMoveFromUci(u.myPosition, tokens[i])
-				if move.IsValid() {
-					searchLimits.Moves.PushBack(move)
-					i++
-				} else {
+				move := mg.GetMoveFromUci(p, tokens[i])
+				if !move.IsValid() {
 					break
 				}
+				searchLimits.Moves.PushBack(move)
+				i++
+			}
+			if searchLimits.Moves.Len() == 0 {
+				return nil, fmt.Errorf("%s without any valid move", token)
 			}
 		case "infinite":
-			i++
 			searchLimits.Infinite = true
 		case "ponder":
-			i++
 			searchLimits.Ponder = true
 		case "depth":
-			i++
-			searchLimits.Depth, err = strconv.Atoi(tokens[i])
+			value, err := readGoValue(tokens, i, token)
 			if err != nil {
-				msg := out.Sprintf("UCI command go malformed. Depth value not an number: %s", tokens[i])
-				u.SendInfoString(msg)
-				log.Warning(msg)
-				return nil, true
+				return nil, err
 			}
+			searchLimits.Depth = int(value)
 			i++
 		case "nodes":
-			i++
-			parseInt, err := strconv.ParseInt(tokens[i], 10, 64)
+			value, err := readGoValue(tokens, i, token)
 			if err != nil {
-				msg := out.Sprintf("UCI command go malformed. Nodes value not an number: %s", tokens[i])
-				u.SendInfoString(msg)
-				log.Warning(msg)
-				return nil, true
+				return nil, err
 			}
-			searchLimits.Nodes = uint64(parseInt)
+			searchLimits.Nodes = uint64(value)
 			i++
 		case "mate":
-			i++
-			searchLimits.Mate, err = strconv.Atoi(tokens[i])
+			value, err := readGoValue(tokens, i, token)
 			if err != nil {
-				msg := out.Sprintf("UCI command go malformed. Mate value not an number: %s", tokens[i])
-				u.SendInfoString(msg)
-				log.Warning(msg)
-				return nil, true
+				return nil, err
 			}
+			searchLimits.Mate = int(value)
 			i++
-		case "movetime":
-			// UCI wants moveTime but STS test suite uses movetime - this catches this
-			fallthrough
-		case "moveTime":
-			i++
-			parseInt, err := strconv.ParseInt(tokens[i], 10, 64)
+		// UCI wants movetime but STS test suite uses moveTime - this catches this
+		case "movetime", "moveTime":
+			value, err := readGoValue(tokens, i, token)
 			if err != nil {
-				msg := out.Sprintf("UCI command go malformed. MoveTime value not an number: %s", tokens[i])
-				u.SendInfoString(msg)
-				log.Warning(msg)
-				return nil, true
+				return nil, err
 			}
-			searchLimits.MoveTime = time.Duration(parseInt * 1_000_000)
+			searchLimits.MoveTime = time.Duration(value) * time.Millisecond
 			searchLimits.TimeControl = true
 			i++
 		case "wtime":
-			i++
-			parseInt, err := strconv.ParseInt(tokens[i], 10, 64)
+			value, err := readGoValue(tokens, i, token)
 			if err != nil {
-				msg := out.Sprintf("UCI command go malformed. WhiteTime value not an number: %s", tokens[i])
-				u.SendInfoString(msg)
-				log.Warning(msg)
-				return nil, true
+				return nil, err
 			}
-			searchLimits.WhiteTime = time.Duration(parseInt * 1_000_000)
+			searchLimits.WhiteTime = time.Duration(value) * time.Millisecond
 			searchLimits.TimeControl = true
+			whiteTimeSent = true
 			i++
 		case "btime":
-			i++
-			parseInt, err := strconv.ParseInt(tokens[i], 10, 64)
+			value, err := readGoValue(tokens, i, token)
 			if err != nil {
-				msg := out.Sprintf("UCI command go malformed. Black value not an number: %s", tokens[i])
-				u.SendInfoString(msg)
-				log.Warning(msg)
-				return nil, true
+				return nil, err
 			}
-			searchLimits.BlackTime = time.Duration(parseInt * 1_000_000)
+			searchLimits.BlackTime = time.Duration(value) * time.Millisecond
 			searchLimits.TimeControl = true
+			blackTimeSent = true
 			i++
 		case "winc":
-			i++
-			parseInt, err := strconv.ParseInt(tokens[i], 10, 64)
+			value, err := readGoValue(tokens, i, token)
 			if err != nil {
-				msg := out.Sprintf("UCI command go malformed. WhiteInc value not an number: %s", tokens[i])
-				u.SendInfoString(msg)
-				log.Warning(msg)
-				return nil, true
+				return nil, err
 			}
-			searchLimits.WhiteInc = time.Duration(parseInt * 1_000_000)
+			searchLimits.WhiteInc = time.Duration(value) * time.Millisecond
 			i++
 		case "binc":
-			i++
-			parseInt, err := strconv.ParseInt(tokens[i], 10, 64)
+			value, err := readGoValue(tokens, i, token)
 			if err != nil {
-				msg := out.Sprintf("UCI command go malformed. BlackInc value not an number: %s", tokens[i])
-				u.SendInfoString(msg)
-				log.Warning(msg)
-				return nil, true
+				return nil, err
 			}
-			searchLimits.BlackInc = time.Duration(parseInt * 1_000_000)
+			searchLimits.BlackInc = time.Duration(value) * time.Millisecond
 			i++
 		case "movestogo":
-			i++
-			searchLimits.MovesToGo, err = strconv.Atoi(tokens[i])
+			value, err := readGoValue(tokens, i, token)
 			if err != nil {
-				msg := out.Sprintf("UCI command go malformed. Movestogo value not an number: %s", tokens[i])
-				u.SendInfoString(msg)
-				log.Warning(msg)
-				return nil, true
+				return nil, err
 			}
+			searchLimits.MovesToGo = int(value)
 			i++
 		default:
-			msg := out.Sprintf("UCI command go malformed. Invalid subcommand: %s", tokens[i])
-			u.SendInfoString(msg)
-			log.Warning(msg)
-			return nil, true
+			return nil, fmt.Errorf("invalid subcommand: %s", token)
 		}
 	}
 	// sanity check / minimum settings
@@ -547,27 +616,35 @@ func (u *UciHandler) readSearchLimits(tokens []string) (*search.Limits, bool) {
 		searchLimits.Nodes > 0 ||
 		searchLimits.Mate > 0 ||
 		searchLimits.TimeControl) {
-
-		msg := out.Sprintf("UCI command go malformed. No effective limits set %s", tokens)
-		u.SendInfoString(msg)
-		log.Warning(msg)
-		return nil, true
+		return nil, fmt.Errorf("no effective limits set %s", tokens)
 	}
 	// sanity check time control
 	if searchLimits.TimeControl && searchLimits.MoveTime == 0 {
-		if u.myPosition.NextPlayer() == White && searchLimits.WhiteTime == 0 {
-			msg := out.Sprintf("UCI command go invalid. White to move but time for white is zero! %s", tokens)
-			u.SendInfoString(msg)
-			log.Warning(msg)
-			return nil, true
-		} else if u.myPosition.NextPlayer() == Black && searchLimits.BlackTime == 0 {
-			msg := out.Sprintf("UCI command go invalid. Black to move but time for white is zero! %s", tokens)
-			u.SendInfoString(msg)
-			log.Warning(msg)
-			return nil, true
+		if p.NextPlayer() == White && !whiteTimeSent {
+			return nil, fmt.Errorf("white to move but time for white is missing %s", tokens)
+		} else if p.NextPlayer() == Black && !blackTimeSent {
+			return nil, fmt.Errorf("black to move but time for black is missing %s", tokens)
 		}
 	}
-	return searchLimits, false
+	return searchLimits, nil
+}
+
+// readGoValue reads the number at tokens[i] which
+// is the value for the given "go" sub command. Negative values are
+// returned as 0.
+func readGoValue(tokens []string, i int, name string) (int64, error) {
+	if i >= len(tokens) {
+		return 0, fmt.Errorf("%s value missing", name)
+	}
+	value, err := strconv.ParseInt(tokens[i], 10, 64)
+	if err != nil {
+		return 0, fmt.Errorf("%s value not a number: %s", name, tokens[i])
+	}
+	// GUIs send negative times when a side has overstepped its time
+	if value < 0 {
+		value = 0
+	}
+	return value, nil
 }
 
 // getUciLog returns an instance of a special Logger preconfigured for
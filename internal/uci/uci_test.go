@@ -29,9 +29,12 @@ package uci
 import (
 	"bufio"
 	"bytes"
+	"fmt"
 	"os"
 	"path"
+	"regexp"
 	"runtime"
+	"strconv"
 	"strings"
 	"testing"
 	"time"
@@ -41,7 +44,10 @@ import (
 
 	"github.com/frankkopp/FrankyGo/internal/config"
 	"github.com/frankkopp/FrankyGo/internal/logging"
+	"github.com/frankkopp/FrankyGo/internal/movegen"
+	"github.com/frankkopp/FrankyGo/internal/moveslice"
 	"github.com/frankkopp/FrankyGo/internal/position"
+	. "github.com/frankkopp/FrankyGo/internal/types"
 )
 
 var logTest *logging2.Logger
@@ -80,6 +86,28 @@ func TestUciHandler_Loop(t *testing.T) {
 	assert.Contains(t, result, "uciok")
 }
 
+func TestUciHandler_LoopRobustness(t *testing.T) {
+	uh := NewUciHandler()
+	uh.InIo = bufio.NewScanner(strings.NewReader(
+		"foo bar\nregister later\nregister name Frank code 1234\nposition\nposition fen xyz\n  isready\n" +
+			"position startpos moves e2e4\ngo depth 2\nquit\nisready\n"))
+	buffer := new(bytes.Buffer)
+	uh.OutIo = bufio.NewWriter(buffer)
+	uh.Loop()
+	result := buffer.String()
+	assert.Contains(t, result, "info string Unknown command: foo bar")
+	assert.NotContains(t, result, "register")
+	assert.Contains(t, result, "Command 'position' malformed")
+	assert.Equal(t, 1, strings.Count(result, "readyok"))
+	assert.Contains(t, result, "bestmove")
+	assert.False(t, uh.mySearch.IsSearching())
+
+	// loop ends when the in stream is closed without quit
+	uh.InIo = bufio.NewScanner(strings.NewReader("isready\n"))
+	uh.Loop()
+	assert.Equal(t, 2, strings.Count(buffer.String(), "readyok"))
+}
+
 func TestUciCommand(t *testing.T) {
 	uh := NewUciHandler()
 	result := uh.Command("uci")
@@ -110,6 +138,73 @@ func TestResizeHash(t *testing.T) {
 	assert.Contains(t, result, "Hash resized")
 }
 
+func TestThreadsOption(t *testing.T) {
+	defer func() { config.Settings.Search.Threads = 1 }()
+	uh := NewUciHandler()
+	result := uh.Command("uci")
+	assert.Contains(t, result, "option name Threads type spin default 1 min 1 max 1\n")
+
+	uh.Command("setoption name Threads value 1")
+	assert.EqualValues(t, 1, config.Settings.Search.Threads)
+
+	result = uh.Command("setoption name Threads value 0")
+	assert.Contains(t, result, "Threads value '0' invalid")
+	assert.EqualValues(t, 1, config.Settings.Search.Threads)
+
+	result = uh.Command("setoption name Threads value 4")
+	assert.Contains(t, result, "Threads value '4' invalid")
+	assert.EqualValues(t, 1, config.Settings.Search.Threads)
+	assert.EqualValues(t, "1", uciOptions["Threads"].CurrentValue)
+
+	uh.Command("setoption name Threads value abc")
+	assert.EqualValues(t, 1, config.Settings.Search.Threads)
+}
+
+func TestContemptOption(t *testing.T) {
+	defer func() { config.Settings.Search.ContemptMax = 0 }()
+	uh := NewUciHandler()
+	result := uh.Command("uci")
+	assert.Contains(t, result, "option name Contempt type spin default 0 min -100 max 100")
+
+	// every move is a draw by the 50-moves rule
+	uh.Command("setoption name Use_Book value false")
+	uh.Command("position fen 7k/8/8/8/8/8/8/R6K w - - 99 80")
+	drawValue := func() Value {
+		uh.Command("go depth 3")
+		uh.mySearch.WaitWhileSearching()
+		return uh.mySearch.LastSearchResult().BestValue
+	}
+	assert.EqualValues(t, ValueDraw, drawValue())
+
+	uh.Command("setoption name Contempt value 20")
+	assert.EqualValues(t, 20, config.Settings.Search.ContemptMax)
+	assert.EqualValues(t, ValueDraw-20, drawValue())
+
+	uh.Command("setoption name Contempt value -30")
+	assert.EqualValues(t, ValueDraw+30, drawValue())
+
+	result = uh.Command("setoption name Contempt value 500")
+	assert.Contains(t, result, "Contempt value '500' invalid")
+	assert.EqualValues(t, 100, config.Settings.Search.ContemptMax)
+}
+
+func TestEvalModeOption(t *testing.T) {
+	defer func() { config.Settings.Eval.Mode = config.EvalClassical }()
+	uh := NewUciHandler()
+	result := uh.Command("uci")
+	assert.Contains(t, result, "option name EvalMode type combo default classical var classical var material")
+
+	uh.Command("setoption name EvalMode value material")
+	assert.Equal(t, config.EvalMaterial, config.Settings.Eval.Mode)
+
+	result = uh.Command("setoption name EvalMode value fancy")
+	assert.Contains(t, result, "EvalMode value 'fancy' invalid")
+	assert.Equal(t, config.EvalMaterial, config.Settings.Eval.Mode)
+
+	uh.Command("setoption name EvalMode value classical")
+	assert.Equal(t, config.EvalClassical, config.Settings.Eval.Mode)
+}
+
 func TestPositionCmd(t *testing.T) {
 	uh := NewUciHandler()
 	result := uh.Command("position startpos")
@@ -132,6 +227,32 @@ func TestPositionCmd(t *testing.T) {
 
 }
 
+func TestGoWithoutLegalMoves(t *testing.T) {
+	tests := []struct {
+		fen   string
+		value Value
+		info  string
+	}{
+		{"8/8/8/8/8/5K2/8/R4k2 b - -", -ValueCheckMate, "Search called on a mate position"},
+		{"6R1/8/8/8/8/5K2/R7/7k b - -", ValueDraw, "Search called on a stalemate position"},
+	}
+	for _, test := range tests {
+		uh := NewUciHandler()
+		buffer := new(bytes.Buffer)
+		uh.OutIo = bufio.NewWriter(buffer)
+		uh.Command("position fen " + test.fen)
+		result := uh.Command("go movetime 1000")
+		uh.mySearch.WaitWhileSearching()
+		_ = uh.OutIo.Flush()
+		result += buffer.String()
+		assert.Contains(t, result, test.info, test.fen)
+		assert.Contains(t, result, "bestmove (none)", test.fen)
+		assert.NotContains(t, result, "NoMove", test.fen)
+		assert.EqualValues(t, MoveNone, uh.mySearch.LastSearchResult().BestMove, test.fen)
+		assert.EqualValues(t, test.value, uh.mySearch.LastSearchResult().BestValue, test.fen)
+	}
+}
+
 func TestReadSearchLimits(t *testing.T) {
 	var cmd string
 	var tokens []string
@@ -289,6 +410,216 @@ func TestReadSearchLimits(t *testing.T) {
 	assert.True(t, err)
 }
 
+func TestParseGo(t *testing.T) {
+	p := position.NewPosition()
+
+	sl, err := ParseGo(p, strings.Fields("go wtime 300000 btime 295000 winc 2000 binc 2000 movestogo 38"))
+	assert.NoError(t, err)
+	assert.EqualValues(t, 300_000, sl.WhiteTime.Milliseconds())
+	assert.EqualValues(t, 295_000, sl.BlackTime.Milliseconds())
+	assert.EqualValues(t, 2_000, sl.WhiteInc.Milliseconds())
+	assert.EqualValues(t, 2_000, sl.BlackInc.Milliseconds())
+	assert.EqualValues(t, 38, sl.MovesToGo)
+	assert.True(t, sl.TimeControl)
+
+	// without leading "go" and with searchmoves at the end
+	sl, err = ParseGo(p, strings.Fields("ponder wtime 1000 btime 1000 searchmoves e2e4 g1f3"))
+	assert.NoError(t, err)
+	assert.True(t, sl.Ponder)
+	assert.EqualValues(t, "e2e4 g1f3", sl.Moves.StringUci())
+
+	sl, err = ParseGo(p, strings.Fields("go searchmoves d2d4 movetime 1500 depth 12 nodes 500000 mate 3"))
+	assert.NoError(t, err)
+	assert.EqualValues(t, "d2d4", sl.Moves.StringUci())
+	assert.EqualValues(t, 1_500, sl.MoveTime.Milliseconds())
+	assert.EqualValues(t, 12, sl.Depth)
+	assert.EqualValues(t, 500_000, sl.Nodes)
+	assert.EqualValues(t, 3, sl.Mate)
+
+	// an overstepped time is sent as negative value
+	sl, err = ParseGo(p, strings.Fields("go wtime -100 btime 100"))
+	assert.NoError(t, err)
+	assert.EqualValues(t, 0, sl.WhiteTime)
+	assert.EqualValues(t, 100, sl.BlackTime.Milliseconds())
+
+	// malformed commands
+	_, err = ParseGo(p, strings.Fields("go depth"))
+	assert.EqualError(t, err, "depth value missing")
+	_, err = ParseGo(p, strings.Fields("go nodes 10k"))
+	assert.EqualError(t, err, "nodes value not a number: 10k")
+	_, err = ParseGo(p, strings.Fields("go infinite searchmoves e7e5"))
+	assert.EqualError(t, err, "searchmoves without any valid move")
+	_, err = ParseGo(p, strings.Fields("go infinite fast"))
+	assert.EqualError(t, err, "invalid subcommand: fast")
+	_, err = ParseGo(p, strings.Fields("go btime 1000"))
+	assert.Error(t, err)
+	_, err = ParseGo(p, strings.Fields("go"))
+	assert.Error(t, err)
+}
+
+func TestAspirationBoundInfo(t *testing.T) {
+	defer func() { config.Settings.Search.UseAspiration = false }()
+	uh := NewUciHandler()
+	uh.Command("setoption name Use_Book value false")
+	uh.Command("setoption name Use_ASP value true")
+	// the winning queen sacrifice is found during the search which
+	// makes the aspiration window fail high
+	uh.Command("position fen 2rr3k/pp3pp1/1nnqbN1p/3pN3/2pP4/2P3Q1/PPB4P/R4RK1 w - -")
+	buffer := new(bytes.Buffer)
+	uh.OutIo = bufio.NewWriter(buffer)
+	uh.Command("go depth 8")
+	uh.mySearch.WaitWhileSearching()
+	assert.Regexp(t, "info depth \\d+ seldepth \\d+ multipv 1 score cp \\d+ lowerbound nodes", buffer.String())
+	assert.Contains(t, buffer.String(), "bestmove g3g6")
+}
+
+func TestInfoInterval(t *testing.T) {
+	defer func() { config.Settings.Search.InfoInterval = 1000 }()
+	search := func(interval int) string {
+		config.Settings.Search.InfoInterval = interval
+		uh := NewUciHandler()
+		uh.Command("setoption name Use_Book value false")
+		uh.Command("position startpos")
+		buffer := new(bytes.Buffer)
+		uh.OutIo = bufio.NewWriter(buffer)
+		// Command() would only capture the output until the search
+		// has been started
+		uh.handleReceivedCommand("go depth 5")
+		uh.mySearch.WaitWhileSearching()
+		return buffer.String()
+	}
+
+	// each iteration is reported but no periodic updates
+	output := search(3_600_000)
+	assert.Equal(t, 5, strings.Count(output, "multipv 1 score"))
+	assert.NotContains(t, output, "currmove")
+
+	// without an interval periodic updates are sent during the iterations
+	output = search(0)
+	assert.Equal(t, 5, strings.Count(output, "multipv 1 score"))
+	assert.Contains(t, output, "currmove")
+}
+
+func TestScoreInCentipawns(t *testing.T) {
+	uh := NewUciHandler()
+	buffer := new(bytes.Buffer)
+	uh.OutIo = bufio.NewWriter(buffer)
+	pv := moveslice.NewMoveSlice(2)
+	pv.PushBack(CreateMove(SqE2, SqE4, Normal, PtNone))
+	// a pawn advantage
+	uh.SendIterationEndInfo(5, 8, Pawn.ValueOf(), 1000, 10000, 100*time.Millisecond, *pv)
+	assert.Contains(t, buffer.String(), "score cp 100 nodes")
+	buffer.Reset()
+	uh.SendAspirationResearchInfo(5, 8, -Pawn.ValueOf(), "upperbound", 1000, 10000, 100*time.Millisecond, *pv)
+	assert.Contains(t, buffer.String(), "score cp -100 upperbound")
+}
+
+func TestShowWDL(t *testing.T) {
+	defer func() { config.Settings.Search.ShowWDL = false }()
+	uh := NewUciHandler()
+	assert.Contains(t, uh.Command("uci"), "option name UCI_ShowWDL type check default false")
+	buffer := new(bytes.Buffer)
+	uh.OutIo = bufio.NewWriter(buffer)
+	pv := moveslice.NewMoveSlice(2)
+	pv.PushBack(CreateMove(SqE2, SqE4, Normal, PtNone))
+
+	// no wdl without the option
+	uh.SendIterationEndInfo(5, 8, Pawn.ValueOf(), 1000, 10000, 100*time.Millisecond, *pv)
+	assert.NotContains(t, buffer.String(), "wdl")
+
+	uh.Command("setoption name UCI_ShowWDL value true")
+	assert.True(t, config.Settings.Search.ShowWDL)
+	buffer.Reset()
+	uh.SendIterationEndInfo(5, 8, Pawn.ValueOf(), 1000, 10000, 100*time.Millisecond, *pv)
+	assert.Regexp(t, "score cp 100 wdl \\d+ \\d+ \\d+ nodes", buffer.String())
+	buffer.Reset()
+	uh.SendAspirationResearchInfo(5, 8, -Pawn.ValueOf(), "upperbound", 1000, 10000, 100*time.Millisecond, *pv)
+	assert.Regexp(t, "score cp -100 upperbound wdl \\d+ \\d+ \\d+ nodes", buffer.String())
+}
+
+func TestShowRefutations(t *testing.T) {
+	defer func() {
+		config.Settings.Search.ShowRefutations = false
+		config.Settings.Search.UseBook = true
+	}()
+	config.Settings.Search.UseBook = false
+	uh := NewUciHandler()
+	assert.Contains(t, uh.Command("uci"), "option name UCI_ShowRefutations type check default false")
+	buffer := new(bytes.Buffer)
+	uh.OutIo = bufio.NewWriter(buffer)
+	refutation := moveslice.NewMoveSlice(2)
+	refutation.PushBack(CreateMove(SqD8, SqH4, Normal, PtNone))
+	uh.SendRefutation(CreateMove(SqG2, SqG4, Normal, PtNone), -ValueCheckMate+1, "", *refutation)
+	assert.Contains(t, buffer.String(), "info refutation g2g4 d8h4")
+	assert.Contains(t, buffer.String(), "info string refutation g2g4 score mate -1\n")
+	buffer.Reset()
+	uh.SendRefutation(CreateMove(SqG2, SqG4, Normal, PtNone), -Pawn.ValueOf(), "upperbound", *refutation)
+	assert.Contains(t, buffer.String(), "info string refutation g2g4 score cp -100 upperbound")
+
+	// no refutations without the option
+	// (search output after the go command returned goes to the buffer)
+	uh.Command("position fen r1bqkbnr/pppp1ppp/2n5/4p3/4P3/5N2/PPPP1PPP/RNBQKB1R w KQkq -")
+	buffer.Reset()
+	result := uh.Command("go depth 5")
+	uh.mySearch.WaitWhileSearching()
+	assert.NotContains(t, result+buffer.String(), "info refutation")
+
+	// refutation lines for the root moves
+	uh.Command("setoption name UCI_ShowRefutations value true")
+	assert.True(t, config.Settings.Search.ShowRefutations)
+	buffer.Reset()
+	result = uh.Command("go depth 5")
+	uh.mySearch.WaitWhileSearching()
+	legalMoves := movegen.NewMoveGen().GenerateLegalMoves(uh.myPosition, movegen.GenAll)
+	refutations := regexp.MustCompile("info refutation (\\S+) \\S+").FindAllStringSubmatch(result+buffer.String(), -1)
+	assert.NotEmpty(t, refutations)
+	assert.LessOrEqual(t, len(refutations), legalMoves.Len())
+	for _, r := range refutations {
+		assert.Contains(t, legalMoves.StringUci(), r[1])
+		assert.Regexp(t, "info string refutation "+r[1]+" score (cp|mate) -?\\d+", result+buffer.String())
+	}
+	// only the best move has an exact score
+	bestMove := uh.mySearch.LastSearchResult().BestMove.StringUci()
+	for _, r := range refutations {
+		if r[1] == bestMove {
+			assert.Regexp(t, "info string refutation "+r[1]+" score (cp|mate) -?\\d+\n", result+buffer.String())
+		} else {
+			assert.Regexp(t, "info string refutation "+r[1]+" score (cp|mate) -?\\d+ upperbound", result+buffer.String())
+		}
+	}
+}
+
+func TestMirrorCmd(t *testing.T) {
+	// the tempo bonus is added for White only
+	tempo := config.Settings.Eval.Tempo
+	defer func() { config.Settings.Eval.Tempo = tempo }()
+	config.Settings.Eval.Tempo = 0
+	uh := NewUciHandler()
+	uh.Command("position fen r1bq1rk1/pppp1pp1/2n2n1p/1B2p3/1b2P3/2N2N1P/PPPP1PP1/R1BQ1RK1 w - - 0 1 moves f3e5")
+	result := uh.Command("mirror")
+	assert.EqualValues(t, "r1bq1rk1/pppp1pp1/2n4p/1B2p3/1b2n3/2N2N1P/PPPP1PP1/R1BQ1RK1 w - - 0 1", uh.myPosition.StringFen())
+	matches := regexp.MustCompile("eval cp (-?\\d+)").FindAllStringSubmatch(result, -1)
+	assert.Len(t, matches, 2)
+	value, _ := strconv.Atoi(matches[0][1])
+	mirrorValue, _ := strconv.Atoi(matches[1][1])
+	assert.NotZero(t, value)
+	assert.EqualValues(t, value, -mirrorValue)
+}
+
+func TestDisplayCmd(t *testing.T) {
+	uh := NewUciHandler()
+	uh.Command("position startpos")
+	result := uh.Command("d")
+	assert.Contains(t, result, position.StartFen)
+	assert.Contains(t, result, "| r | n | b | q | k | b | n | r |")
+	assert.Contains(t, result, "Game Phase     : 24")
+	assert.Contains(t, result, fmt.Sprintf("Zobrist Key    : %016x", uint64(uh.myPosition.ZobristKey())))
+	assert.Contains(t, result, "Static Eval    : cp ")
+	assert.Contains(t, result, "Legal Moves    : (20) ")
+	assert.Contains(t, result, "e2e4")
+	assert.Contains(t, result, "g1f3")
+}
+
 func TestFullSearchProcess(t *testing.T) {
 	uh := NewUciHandler()
 
@@ -316,6 +647,47 @@ func TestFullSearchProcess(t *testing.T) {
 	result = uh.Command("quit")
 }
 
+func TestOwnBookOption(t *testing.T) {
+	defer func() { config.Settings.Search.UseBook = true }()
+	uh := NewUciHandler()
+	result := uh.Command("uci")
+	assert.Contains(t, result, "option name OwnBook type check default true")
+
+	uh.Command("position startpos moves e2e4 e7e5")
+
+	// off - the book is not consulted
+	uh.Command("setoption name OwnBook value false")
+	assert.False(t, config.Settings.Search.UseBook)
+	assert.EqualValues(t, "false", uciOptions["Use_Book"].CurrentValue)
+	uh.Command("go movetime 200")
+	uh.mySearch.WaitWhileSearching()
+	assert.False(t, uh.mySearch.LastSearchResult().BookMove)
+
+	// on again
+	uh.Command("setoption name OwnBook value true")
+	assert.True(t, config.Settings.Search.UseBook)
+	assert.EqualValues(t, "true", uciOptions["Use_Book"].CurrentValue)
+	uh.Command("go movetime 200")
+	uh.mySearch.WaitWhileSearching()
+	assert.True(t, uh.mySearch.LastSearchResult().BookMove)
+}
+
+func TestUseSeeAlias(t *testing.T) {
+	defer func() { config.Settings.Search.UseQSSee = true }()
+	uh := NewUciHandler()
+	result := uh.Command("uci")
+	assert.Contains(t, result, "option name Use_QSSee type check default true")
+	assert.Contains(t, result, "option name Use_SEE type check default true")
+
+	uh.Command("setoption name Use_SEE value false")
+	assert.False(t, config.Settings.Search.UseQSSee)
+	assert.EqualValues(t, "false", uciOptions["Use_QSSee"].CurrentValue)
+
+	uh.Command("setoption name Use_QSSee value true")
+	assert.True(t, config.Settings.Search.UseQSSee)
+	assert.EqualValues(t, "true", uciOptions["Use_SEE"].CurrentValue)
+}
+
 func TestBookMove(t *testing.T) {
 	uh := NewUciHandler()
 
@@ -35,21 +35,35 @@ import (
 	. "github.com/frankkopp/FrankyGo/internal/config"
 )
 
+// maxThreads is the maximum number of search threads offered to the
+// UCI ui. The search is single threaded so far.
+const maxThreads = 1
+
 // init will define all available uci options and store them into the uciOption map
 func init() {
 	uciOptions = map[string]*uciOption{
-		"Print Config": {NameID: "Print Config", HandlerFunc: printConfig, OptionType: Button},
-		"Clear Hash":   {NameID: "Clear Hash", HandlerFunc: clearCache, OptionType: Button},
-		"Use_Hash":     {NameID: "Use_Hash", HandlerFunc: useCache, OptionType: Check, DefaultValue: strconv.FormatBool(Settings.Search.UseTT), CurrentValue: strconv.FormatBool(Settings.Search.UseTT)},
-		"Hash":         {NameID: "Hash", HandlerFunc: cacheSize, OptionType: Spin, DefaultValue: strconv.Itoa(Settings.Search.TTSize), CurrentValue: strconv.Itoa(Settings.Search.TTSize), MinValue: "0", MaxValue: "65000"},
+		"Print Config":  {NameID: "Print Config", HandlerFunc: printConfig, OptionType: Button},
+		"Clear Hash":    {NameID: "Clear Hash", HandlerFunc: clearCache, OptionType: Button},
+		"Clear History": {NameID: "Clear History", HandlerFunc: clearHistory, OptionType: Button},
+		"Use_Hash":      {NameID: "Use_Hash", HandlerFunc: useCache, OptionType: Check, DefaultValue: strconv.FormatBool(Settings.Search.UseTT), CurrentValue: strconv.FormatBool(Settings.Search.UseTT)},
+		"Hash":          {NameID: "Hash", HandlerFunc: cacheSize, OptionType: Spin, DefaultValue: strconv.Itoa(Settings.Search.TTSize), CurrentValue: strconv.Itoa(Settings.Search.TTSize), MinValue: "0", MaxValue: "65000"},
 
+		"OwnBook":  {NameID: "OwnBook", HandlerFunc: useBook, OptionType: Check, DefaultValue: strconv.FormatBool(Settings.Search.UseBook), CurrentValue: strconv.FormatBool(Settings.Search.UseBook)},
 		"Use_Book": {NameID: "Use_Book", HandlerFunc: useBook, OptionType: Check, DefaultValue: strconv.FormatBool(Settings.Search.UseBook), CurrentValue: strconv.FormatBool(Settings.Search.UseBook)},
 
 		"Ponder": {NameID: "Ponder", HandlerFunc: usePonder, OptionType: Check, DefaultValue: strconv.FormatBool(Settings.Search.UsePonder), CurrentValue: strconv.FormatBool(Settings.Search.UsePonder)},
 
+		"Threads": {NameID: "Threads", HandlerFunc: threads, OptionType: Spin, DefaultValue: strconv.Itoa(Settings.Search.Threads), CurrentValue: strconv.Itoa(Settings.Search.Threads), MinValue: "1", MaxValue: strconv.Itoa(maxThreads)},
+
+		"UCI_ShowWDL":         {NameID: "UCI_ShowWDL", HandlerFunc: showWdl, OptionType: Check, DefaultValue: strconv.FormatBool(Settings.Search.ShowWDL), CurrentValue: strconv.FormatBool(Settings.Search.ShowWDL)},
+		"UCI_ShowRefutations": {NameID: "UCI_ShowRefutations", HandlerFunc: showRefutations, OptionType: Check, DefaultValue: strconv.FormatBool(Settings.Search.ShowRefutations), CurrentValue: strconv.FormatBool(Settings.Search.ShowRefutations)},
+
+		"Contempt": {NameID: "Contempt", HandlerFunc: contempt, OptionType: Spin, DefaultValue: strconv.Itoa(Settings.Search.ContemptMax), CurrentValue: strconv.Itoa(Settings.Search.ContemptMax), MinValue: "-100", MaxValue: "100"},
+
 		"Quiescence":       {NameID: "Quiescence", HandlerFunc: useQuiescence, OptionType: Check, DefaultValue: strconv.FormatBool(Settings.Search.UseQuiescence), CurrentValue: strconv.FormatBool(Settings.Search.UseQuiescence)},
 		"Use_QHash":        {NameID: "Use_QHash", HandlerFunc: useQSHash, OptionType: Check, DefaultValue: strconv.FormatBool(Settings.Search.UseQSTT), CurrentValue: strconv.FormatBool(Settings.Search.UseQSTT)},
-		"Use_SEE":          {NameID: "Use_SEE", HandlerFunc: useSee, OptionType: Check, DefaultValue: strconv.FormatBool(Settings.Search.UseSEE), CurrentValue: strconv.FormatBool(Settings.Search.UseSEE)},
+		"Use_QSSee":        {NameID: "Use_QSSee", HandlerFunc: useQSSee, OptionType: Check, DefaultValue: strconv.FormatBool(Settings.Search.UseQSSee), CurrentValue: strconv.FormatBool(Settings.Search.UseQSSee)},
+		"Use_SEE":          {NameID: "Use_SEE", HandlerFunc: useQSSee, OptionType: Check, DefaultValue: strconv.FormatBool(Settings.Search.UseQSSee), CurrentValue: strconv.FormatBool(Settings.Search.UseQSSee)},
 		"Use_PromNonQuiet": {NameID: "Use_PromNonQuiet", HandlerFunc: usePromNonQuiet, OptionType: Check, DefaultValue: strconv.FormatBool(Settings.Search.UsePromNonQuiet), CurrentValue: strconv.FormatBool(Settings.Search.UsePromNonQuiet)},
 
 		"Use_PVS":  {NameID: "Use_PVS", HandlerFunc: usePvs, OptionType: Check, DefaultValue: strconv.FormatBool(Settings.Search.UsePVS), CurrentValue: strconv.FormatBool(Settings.Search.UsePVS)},
@@ -61,17 +75,21 @@ func init() {
 		"Use_HistCount":   {NameID: "Use_HistCount", HandlerFunc: useHC, OptionType: Check, DefaultValue: strconv.FormatBool(Settings.Search.UseHistoryCounter), CurrentValue: strconv.FormatBool(Settings.Search.UseHistoryCounter)},
 		"Use_CounterMove": {NameID: "Use_CounterMove", HandlerFunc: useCM, OptionType: Check, DefaultValue: strconv.FormatBool(Settings.Search.UseCounterMoves), CurrentValue: strconv.FormatBool(Settings.Search.UseCounterMoves)},
 
-		"Use_Rfp":      {NameID: "Use_Rfp", HandlerFunc: useRfp, OptionType: Check, DefaultValue: strconv.FormatBool(Settings.Search.UseRFP), CurrentValue: strconv.FormatBool(Settings.Search.UseRFP)},
-		"Use_NullMove": {NameID: "Use_NullMove", HandlerFunc: useNullMove, OptionType: Check, DefaultValue: strconv.FormatBool(Settings.Search.UseNullMove), CurrentValue: strconv.FormatBool(Settings.Search.UseNullMove)},
-		"Use_Mdp":      {NameID: "Use_Mdp", HandlerFunc: useMdp, OptionType: Check, DefaultValue: strconv.FormatBool(Settings.Search.UseMDP), CurrentValue: strconv.FormatBool(Settings.Search.UseMDP)},
-		"Use_Fp":       {NameID: "Use_Fp", HandlerFunc: useFp, OptionType: Check, DefaultValue: strconv.FormatBool(Settings.Search.UseFP), CurrentValue: strconv.FormatBool(Settings.Search.UseFP)},
-		"Use_Lmr":      {NameID: "Use_Lmr", HandlerFunc: useLmr, OptionType: Check, DefaultValue: strconv.FormatBool(Settings.Search.UseLmr), CurrentValue: strconv.FormatBool(Settings.Search.UseLmr)},
-		"Use_Lmp":      {NameID: "Use_Lmp", HandlerFunc: useLmp, OptionType: Check, DefaultValue: strconv.FormatBool(Settings.Search.UseLmp), CurrentValue: strconv.FormatBool(Settings.Search.UseLmp)},
+		"Use_Rfp":        {NameID: "Use_Rfp", HandlerFunc: useRfp, OptionType: Check, DefaultValue: strconv.FormatBool(Settings.Search.UseRFP), CurrentValue: strconv.FormatBool(Settings.Search.UseRFP)},
+		"Use_NullMove":   {NameID: "Use_NullMove", HandlerFunc: useNullMove, OptionType: Check, DefaultValue: strconv.FormatBool(Settings.Search.UseNullMove), CurrentValue: strconv.FormatBool(Settings.Search.UseNullMove)},
+		"Use_Mdp":        {NameID: "Use_Mdp", HandlerFunc: useMdp, OptionType: Check, DefaultValue: strconv.FormatBool(Settings.Search.UseMDP), CurrentValue: strconv.FormatBool(Settings.Search.UseMDP)},
+		"Use_Fp":         {NameID: "Use_Fp", HandlerFunc: useFp, OptionType: Check, DefaultValue: strconv.FormatBool(Settings.Search.UseFP), CurrentValue: strconv.FormatBool(Settings.Search.UseFP)},
+		"Use_Lmr":        {NameID: "Use_Lmr", HandlerFunc: useLmr, OptionType: Check, DefaultValue: strconv.FormatBool(Settings.Search.UseLmr), CurrentValue: strconv.FormatBool(Settings.Search.UseLmr)},
+		"Use_Lmp":        {NameID: "Use_Lmp", HandlerFunc: useLmp, OptionType: Check, DefaultValue: strconv.FormatBool(Settings.Search.UseLmp), CurrentValue: strconv.FormatBool(Settings.Search.UseLmp)},
+		"Use_SeePruning": {NameID: "Use_SeePruning", HandlerFunc: useSeePruning, OptionType: Check, DefaultValue: strconv.FormatBool(Settings.Search.UseSEEPruning), CurrentValue: strconv.FormatBool(Settings.Search.UseSEEPruning)},
 
-		"Use_Ext":         {NameID: "Use_Ext", HandlerFunc: useExt, OptionType: Check, DefaultValue: strconv.FormatBool(Settings.Search.UseExt), CurrentValue: strconv.FormatBool(Settings.Search.UseExt)},
-		"Use_ExtAddDepth": {NameID: "Use_ExtAddDepth", HandlerFunc: useExtAddDepth, OptionType: Check, DefaultValue: strconv.FormatBool(Settings.Search.UseExtAddDepth), CurrentValue: strconv.FormatBool(Settings.Search.UseExtAddDepth)},
-		"Use_CheckExt":    {NameID: "Use_CheckExt", HandlerFunc: useCheckExt, OptionType: Check, DefaultValue: strconv.FormatBool(Settings.Search.UseCheckExt), CurrentValue: strconv.FormatBool(Settings.Search.UseCheckExt)},
-		"Use_ThreatExt":   {NameID: "Use_ThreatExt", HandlerFunc: useThreatExt, OptionType: Check, DefaultValue: strconv.FormatBool(Settings.Search.UseThreatExt), CurrentValue: strconv.FormatBool(Settings.Search.UseThreatExt)},
+		"Use_Ext":           {NameID: "Use_Ext", HandlerFunc: useExt, OptionType: Check, DefaultValue: strconv.FormatBool(Settings.Search.UseExt), CurrentValue: strconv.FormatBool(Settings.Search.UseExt)},
+		"Use_ExtAddDepth":   {NameID: "Use_ExtAddDepth", HandlerFunc: useExtAddDepth, OptionType: Check, DefaultValue: strconv.FormatBool(Settings.Search.UseExtAddDepth), CurrentValue: strconv.FormatBool(Settings.Search.UseExtAddDepth)},
+		"Use_CheckExt":      {NameID: "Use_CheckExt", HandlerFunc: useCheckExt, OptionType: Check, DefaultValue: strconv.FormatBool(Settings.Search.UseCheckExt), CurrentValue: strconv.FormatBool(Settings.Search.UseCheckExt)},
+		"Use_ThreatExt":     {NameID: "Use_ThreatExt", HandlerFunc: useThreatExt, OptionType: Check, DefaultValue: strconv.FormatBool(Settings.Search.UseThreatExt), CurrentValue: strconv.FormatBool(Settings.Search.UseThreatExt)},
+		"Use_PassedPawnExt": {NameID: "Use_PassedPawnExt", HandlerFunc: usePassedPawnExt, OptionType: Check, DefaultValue: strconv.FormatBool(Settings.Search.UsePassedPawnExt), CurrentValue: strconv.FormatBool(Settings.Search.UsePassedPawnExt)},
+
+		"EvalMode": {NameID: "EvalMode", HandlerFunc: evalMode, OptionType: Combo, DefaultValue: Settings.Eval.Mode.String(), CurrentValue: Settings.Eval.Mode.String(), VarValue: "classical var material"},
 
 		"Eval_Lazy":     {NameID: "Eval_Lazy", HandlerFunc: evalLazy, OptionType: Check, DefaultValue: strconv.FormatBool(Settings.Eval.UseLazyEval), CurrentValue: strconv.FormatBool(Settings.Eval.UseLazyEval)},
 		"Eval_Mobility": {NameID: "Eval_Mobility", HandlerFunc: evalMob, OptionType: Check, DefaultValue: strconv.FormatBool(Settings.Eval.UseMobility), CurrentValue: strconv.FormatBool(Settings.Eval.UseMobility)},
@@ -83,13 +101,20 @@ func init() {
 	sortOrderUciOptions = []string{
 		"Print Config",
 		"Clear Hash",
+		"Clear History",
 		"Use_Hash",
 		"Hash",
+		"OwnBook",
 		"Use_Book",
 		"Ponder",
+		"Threads",
+		"UCI_ShowWDL",
+		"UCI_ShowRefutations",
+		"Contempt",
 
 		"Quiescence",
 		"Use_QHash",
+		"Use_QSSee",
 		"Use_SEE",
 		"Use_PromNonQuiet",
 
@@ -107,12 +132,15 @@ func init() {
 		"Use_Fp",
 		"Use_Lmr",
 		"Use_Lmp",
+		"Use_SeePruning",
 
 		"Use_Ext",
 		"Use_ExtAddDepth",
 		"Use_CheckExt",
 		"Use_ThreatExt",
+		"Use_PassedPawnExt",
 
+		"EvalMode",
 		"Eval_Mobility",
 		"Eval_AdvPiece",
 	}
@@ -234,6 +262,11 @@ func clearCache(u *UciHandler, o *uciOption) {
 	log.Debug("Cleared Cache")
 }
 
+func clearHistory(u *UciHandler, o *uciOption) {
+	u.mySearch.ClearHistory()
+	log.Debug("Cleared History")
+}
+
 func useCache(u *UciHandler, o *uciOption) {
 	v, _ := strconv.ParseBool(o.CurrentValue)
 	Settings.Search.UseTT = v
@@ -246,9 +279,55 @@ func cacheSize(u *UciHandler, o *uciOption) {
 	u.mySearch.ResizeCache()
 }
 
+func threads(u *UciHandler, o *uciOption) {
+	v, err := strconv.Atoi(o.CurrentValue)
+	if err != nil {
+		v = Settings.Search.Threads
+	}
+	// clamp to valid range
+	switch {
+	case v < 1:
+		v = 1
+	case v > maxThreads:
+		v = maxThreads
+	}
+	if strconv.Itoa(v) != o.CurrentValue {
+		u.SendInfoString(out.Sprintf("Threads value '%s' invalid. Using %d", o.CurrentValue, v))
+		o.CurrentValue = strconv.Itoa(v)
+	}
+	Settings.Search.Threads = v
+	log.Debugf("Set Threads to %d", Settings.Search.Threads)
+}
+
+// contempt sets the contempt in centipawns by which the engine values
+// a draw below ValueDraw
+func contempt(u *UciHandler, o *uciOption) {
+	v, err := strconv.Atoi(o.CurrentValue)
+	if err != nil {
+		v = Settings.Search.ContemptMax
+	}
+	// clamp to valid range
+	switch {
+	case v < -100:
+		v = -100
+	case v > 100:
+		v = 100
+	}
+	if strconv.Itoa(v) != o.CurrentValue {
+		u.SendInfoString(out.Sprintf("Contempt value '%s' invalid. Using %d", o.CurrentValue, v))
+		o.CurrentValue = strconv.Itoa(v)
+	}
+	Settings.Search.ContemptMax = v
+	log.Debugf("Set Contempt to %d", Settings.Search.ContemptMax)
+}
+
+// useBook is the handler for the standard UCI option OwnBook and
+// its alias Use_Book which are kept in sync
 func useBook(u *UciHandler, o *uciOption) {
 	v, _ := strconv.ParseBool(o.CurrentValue)
 	Settings.Search.UseBook = v
+	uciOptions["OwnBook"].CurrentValue = strconv.FormatBool(v)
+	uciOptions["Use_Book"].CurrentValue = strconv.FormatBool(v)
 	log.Debugf("Set Use Book to %v", Settings.Search.UseBook)
 }
 
@@ -258,6 +337,18 @@ func usePonder(u *UciHandler, o *uciOption) {
 	log.Debugf("Set Use Ponder to %v", Settings.Search.UsePonder)
 }
 
+func showWdl(u *UciHandler, o *uciOption) {
+	v, _ := strconv.ParseBool(o.CurrentValue)
+	Settings.Search.ShowWDL = v
+	log.Debugf("Set Show WDL to %v", Settings.Search.ShowWDL)
+}
+
+func showRefutations(u *UciHandler, o *uciOption) {
+	v, _ := strconv.ParseBool(o.CurrentValue)
+	Settings.Search.ShowRefutations = v
+	log.Debugf("Set Show Refutations to %v", Settings.Search.ShowRefutations)
+}
+
 func useQuiescence(u *UciHandler, o *uciOption) {
 	v, _ := strconv.ParseBool(o.CurrentValue)
 	Settings.Search.UseQuiescence = v
@@ -336,10 +427,20 @@ func useLmp(u *UciHandler, o *uciOption) {
 	log.Debugf("Set use Late Move Pruning to %v", Settings.Search.UseLmp)
 }
 
-func useSee(u *UciHandler, o *uciOption) {
+// useQSSee is the handler for Use_QSSee and its older name Use_SEE
+// which are kept in sync
+func useQSSee(u *UciHandler, o *uciOption) {
+	v, _ := strconv.ParseBool(o.CurrentValue)
+	Settings.Search.UseQSSee = v
+	uciOptions["Use_QSSee"].CurrentValue = strconv.FormatBool(v)
+	uciOptions["Use_SEE"].CurrentValue = strconv.FormatBool(v)
+	log.Debugf("Set use SEE in quiescence search to %v", Settings.Search.UseQSSee)
+}
+
+func useSeePruning(u *UciHandler, o *uciOption) {
 	v, _ := strconv.ParseBool(o.CurrentValue)
-	Settings.Search.UseSEE = v
-	log.Debugf("Set use SEE to %v", Settings.Search.UseSEE)
+	Settings.Search.UseSEEPruning = v
+	log.Debugf("Set use SEE Pruning to %v", Settings.Search.UseSEEPruning)
 }
 
 func usePromNonQuiet(u *UciHandler, o *uciOption) {
@@ -372,6 +473,12 @@ func useThreatExt(u *UciHandler, o *uciOption) {
 	log.Debugf("Set use Threat Extension to %v", Settings.Search.UseThreatExt)
 }
 
+func usePassedPawnExt(u *UciHandler, o *uciOption) {
+	v, _ := strconv.ParseBool(o.CurrentValue)
+	Settings.Search.UsePassedPawnExt = v
+	log.Debugf("Set use Passed Pawn Extension to %v", Settings.Search.UsePassedPawnExt)
+}
+
 func useRfp(u *UciHandler, o *uciOption) {
 	v, _ := strconv.ParseBool(o.CurrentValue)
 	Settings.Search.UseRFP = v
@@ -384,6 +491,18 @@ func useFp(u *UciHandler, o *uciOption) {
 	log.Debugf("Set use Futility Pruning (FP) to %v", Settings.Search.UseFP)
 }
 
+// evalMode selects the evaluation function - "classical" with all
+// configured terms or the fast "material" only evaluation
+func evalMode(u *UciHandler, o *uciOption) {
+	if mode, err := ParseEvalMode(o.CurrentValue); err == nil {
+		Settings.Eval.Mode = mode
+	} else {
+		u.SendInfoString(out.Sprintf("EvalMode value '%s' invalid. Using %s", o.CurrentValue, Settings.Eval.Mode))
+		o.CurrentValue = Settings.Eval.Mode.String()
+	}
+	log.Debugf("Set Eval Mode to %s", Settings.Eval.Mode)
+}
+
 func evalLazy(u *UciHandler, o *uciOption) {
 	v, _ := strconv.ParseBool(o.CurrentValue)
 	Settings.Eval.UseLazyEval = v
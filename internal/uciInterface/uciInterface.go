@@ -49,5 +49,6 @@ type UciDriver interface{
 	SendCurrentRootMove(currMove types.Move, moveNumber int)
 	SendSearchUpdate(depth int, seldepth int, nodes uint64, nps uint64, time time.Duration, hashfull int)
 	SendCurrentLine(moveList moveslice.MoveSlice)
+	SendRefutation(move types.Move, value types.Value, bound string, refutation moveslice.MoveSlice)
 	SendResult(bestMove types.Move, ponderMove types.Move)
 }
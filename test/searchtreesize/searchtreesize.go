@@ -156,7 +156,7 @@ func featureTest(depth int, movetime time.Duration, fen string) result {
 	Settings.Search.UseCounterMoves = true
 
 	// SEE for qsearch
-	Settings.Search.UseSEE = true
+	Settings.Search.UseQSSee = true
 	// r.Tests = append(r.Tests, measure(s, sl, p, "SEE"))
 
 	// Reverse Futility
@@ -177,6 +177,9 @@ func featureTest(depth int, movetime time.Duration, fen string) result {
 	// Settings.Search.UseThreatExt = true
 	// r.Tests = append(r.Tests, measure(s, sl, p, "THREAT"))
 
+	// Settings.Search.UsePassedPawnExt = true
+	// r.Tests = append(r.Tests, measure(s, sl, p, "PASSED"))
+
 	// Futility
 	Settings.Search.UseFP = true
 	// r.Tests = append(r.Tests, measure(s, sl, p, "FP"))
@@ -331,7 +334,7 @@ func turnOffFeatures() {
 	Settings.Search.UsePonder = false
 	Settings.Search.UseQuiescence = false
 	Settings.Search.UseQSStandpat = false
-	Settings.Search.UseSEE = false
+	Settings.Search.UseQSSee = false
 	Settings.Search.UsePromNonQuiet = false
 	Settings.Search.UseTT = false
 	Settings.Search.UseTTMove = false
@@ -349,6 +352,7 @@ func turnOffFeatures() {
 	Settings.Search.UseExtAddDepth = false
 	Settings.Search.UseCheckExt = false
 	Settings.Search.UseThreatExt = false
+	Settings.Search.UsePassedPawnExt = false
 	Settings.Search.UseRFP = false
 	Settings.Search.UseFP = false
 	Settings.Search.UseQFP = false